@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"portfolio/storage"
+
+	"gorm.io/gorm"
+)
+
+// runCommand выполняет служебную команду вместо запуска сервера
+func runCommand(db *gorm.DB, args []string) error {
+	switch args[0] {
+	case "reconcile-storage":
+		return reconcileStorage(db, args[1:])
+	case "encrypt-storage":
+		return encryptStorage(db)
+	case "rotate-keys":
+		return rotateKeys(db)
+	default:
+		return fmt.Errorf("неизвестная команда: %s", args[0])
+	}
+}
+
+// reconcileStorage пересчитывает StorageUsed и выводит отчёт о расхождениях.
+// Использование: reconcile-storage [-fix]
+func reconcileStorage(db *gorm.DB, args []string) error {
+	fs := flag.NewFlagSet("reconcile-storage", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "записать пересчитанный storage_used в базу")
+	fs.Parse(args)
+
+	report, err := storage.Reconcile(db, *fix)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range report.Users {
+		status := "✅"
+		if u.StorageUsed != u.FilesTotal || len(u.Missing) > 0 || len(u.SizeMismatch) > 0 {
+			status = "⚠️"
+		}
+		log.Printf("%s %s (id=%d): storage_used=%d, по записям=%d, на диске=%d, отсутствует=%d, размер не совпадает=%d",
+			status, u.Username, u.UserID, u.StorageUsed, u.FilesTotal, u.DiskTotal, len(u.Missing), len(u.SizeMismatch))
+	}
+	log.Printf("🗑️ Файлов-сирот на диске: %d", len(report.Orphans))
+
+	return printJSON(report)
+}
+
+// encryptStorage шифрует файлы, загруженные до включения шифрования.
+// Использование: encrypt-storage (нужен STORAGE_MASTER_KEY)
+func encryptStorage(db *gorm.DB) error {
+	report, err := storage.EncryptExisting(db)
+	if err != nil {
+		return err
+	}
+	log.Printf("🔐 Зашифровано файлов: %d, версий: %d, завершено прерванных: %d, пропущено: %d, ошибок: %d",
+		report.Files, report.Versions, report.Resumed, report.Skipped, len(report.Errors))
+	return printJSON(report)
+}
+
+// rotateKeys перешифровывает ключи данных текущим мастер-ключом.
+// Использование: задать новый STORAGE_MASTER_KEY, прежний перенести
+// в STORAGE_OLD_MASTER_KEYS и выполнить rotate-keys. После этого прежний
+// ключ можно убрать из конфигурации.
+func rotateKeys(db *gorm.DB) error {
+	report, err := storage.RotateKeys(db)
+	if err != nil {
+		return err
+	}
+	log.Printf("🔑 Перешифровано ключей файлов: %d, версий: %d, ошибок: %d",
+		report.Files, report.Versions, len(report.Errors))
+	return printJSON(report)
+}
+
+// printJSON выводит отчёт команды в stdout
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
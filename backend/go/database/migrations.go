@@ -0,0 +1,238 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"portfolio/models"
+	"portfolio/planner"
+	"portfolio/storage"
+
+	"gorm.io/gorm"
+)
+
+// migrateFileFolders переносит строковые папки файлов в таблицу file_folders
+func migrateFileFolders(db *gorm.DB) error {
+	var rows []struct {
+		UserID uint
+		Folder string
+	}
+	if err := db.Unscoped().Model(&models.File{}).
+		Where("folder_id IS NULL").
+		Distinct("user_id", "folder").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		path, err := storage.NormalizeFolderPath(row.Folder)
+		if err != nil {
+			log.Printf("⚠️ Пропускаю папку '%s' пользователя %d: %v", row.Folder, row.UserID, err)
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			folder, err := storage.EnsureFolderPath(tx, row.UserID, path)
+			if err != nil {
+				return err
+			}
+			return tx.Unscoped().Model(&models.File{}).
+				Where("user_id = ? AND folder = ? AND folder_id IS NULL", row.UserID, row.Folder).
+				UpdateColumns(map[string]interface{}{"folder": folder.Path, "folder_id": folder.ID}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(rows) > 0 {
+		log.Printf("📁 Перенесено папок файлов: %d", len(rows))
+	}
+	return nil
+}
+
+// migrateTaskFolders создаёт папки задач из строковых имён Task.Folder
+func migrateTaskFolders(db *gorm.DB) error {
+	var rows []struct {
+		UserID uint
+		Folder string
+	}
+	if err := db.Unscoped().Model(&models.Task{}).
+		Where("folder_id IS NULL").
+		Distinct("user_id", "folder").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		name := strings.TrimSpace(row.Folder)
+		if name == "" {
+			name = models.DefaultTaskFolder
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			folder, err := planner.EnsureFolder(tx, row.UserID, name)
+			if err != nil {
+				return err
+			}
+			return tx.Unscoped().Model(&models.Task{}).
+				Where("user_id = ? AND COALESCE(folder, '') = ? AND folder_id IS NULL", row.UserID, row.Folder).
+				UpdateColumns(map[string]interface{}{"folder": folder.Name, "folder_id": folder.ID}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(rows) > 0 {
+		log.Printf("📁 Перенесено папок задач: %d", len(rows))
+	}
+	return nil
+}
+
+// legacyDeadlineLayouts - форматы строковых дедлайнов, встречавшиеся до
+// перехода на timestamptz (помимо форматов planner.ParseDeadline)
+var legacyDeadlineLayouts = []struct {
+	layout  string
+	hasTime bool
+}{
+	{"02.01.2006", false},
+	{"2006/01/02", false},
+	{"2006-01-02 15:04", true},
+	{"2006-01-02 15:04:05", true},
+}
+
+// migrateTaskDeadlines переводит tasks.deadline и tasks.series_start из
+// строк (или DATE из schema.sql) в timestamptz и приводит приоритеты к
+// перечню models.Priority*. Выполняется до AutoMigrate: Postgres не умеет
+// сам преобразовать varchar в timestamptz, и AutoMigrate прервался бы.
+// Даты без времени считаются сроком на весь день в UTC - часовой пояс
+// пользователям до этой миграции не задавался.
+func migrateTaskDeadlines(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.Task{}) {
+		return nil
+	}
+
+	for _, column := range []string{"deadline", "series_start"} {
+		var dataType string
+		if err := db.Raw("SELECT data_type FROM information_schema.columns WHERE table_name = 'tasks' AND column_name = ?", column).
+			Scan(&dataType).Error; err != nil {
+			return err
+		}
+
+		switch dataType {
+		case "date":
+			if err := db.Exec(fmt.Sprintf("ALTER TABLE tasks ALTER COLUMN %[1]s TYPE timestamptz USING %[1]s::timestamp AT TIME ZONE 'UTC'", column)).Error; err != nil {
+				return err
+			}
+			log.Printf("🕒 Колонка tasks.%s переведена из DATE в timestamptz", column)
+		case "character varying", "text":
+			if err := convertDeadlineStrings(db, column); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Регистр и пробелы приводятся к норме, остальное становится medium,
+	// иначе не создастся ограничение chk_tasks_priority
+	result := db.Exec("UPDATE tasks SET priority = lower(trim(priority)) WHERE priority <> lower(trim(priority))")
+	if result.Error != nil {
+		return result.Error
+	}
+	result = db.Exec("UPDATE tasks SET priority = ? WHERE priority IS NULL OR priority NOT IN ?",
+		models.PriorityMedium, []string{models.PriorityLow, models.PriorityMedium, models.PriorityHigh})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🏷️ Неизвестный приоритет заменён на medium у задач: %d", result.RowsAffected)
+	}
+	return nil
+}
+
+// convertDeadlineStrings разбирает строковые значения колонки построчно и
+// заменяет колонку на timestamptz. Неразборчивые значения сбрасываются
+// в NULL с записью в лог.
+func convertDeadlineStrings(db *gorm.DB, column string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		converted := column + "_converted"
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE tasks ADD COLUMN %s timestamptz", converted)).Error; err != nil {
+			return err
+		}
+		if column == "deadline" {
+			if err := tx.Exec("ALTER TABLE tasks ADD COLUMN IF NOT EXISTS has_time boolean DEFAULT false").Error; err != nil {
+				return err
+			}
+		}
+
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		if err := tx.Raw(fmt.Sprintf("SELECT id, %[1]s AS value FROM tasks WHERE %[1]s IS NOT NULL AND trim(%[1]s) <> ''", column)).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		invalid := 0
+		for _, row := range rows {
+			t, hasTime, err := parseLegacyDeadline(strings.TrimSpace(row.Value))
+			if err != nil {
+				invalid++
+				log.Printf("⚠️ Задача %d: не удалось разобрать %s '%s', срок сброшен", row.ID, column, row.Value)
+				continue
+			}
+			updates := map[string]interface{}{converted: t}
+			if column == "deadline" {
+				updates["has_time"] = hasTime
+			}
+			if err := tx.Table("tasks").Where("id = ?", row.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE tasks DROP COLUMN %s", column)).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE tasks RENAME COLUMN %s TO %s", converted, column)).Error; err != nil {
+			return err
+		}
+
+		log.Printf("🕒 tasks.%s переведена в timestamptz: значений %d, сброшено %d", column, len(rows)-invalid, invalid)
+		return nil
+	})
+}
+
+// parseLegacyDeadline разбирает строковый дедлайн в UTC
+func parseLegacyDeadline(s string) (time.Time, bool, error) {
+	if t, hasTime, err := planner.ParseDeadline(s, time.UTC); err == nil {
+		return t, hasTime, nil
+	}
+	for _, legacy := range legacyDeadlineLayouts {
+		if t, err := time.Parse(legacy.layout, s); err == nil {
+			return t, legacy.hasTime, nil
+		}
+	}
+	return time.Time{}, false, planner.ErrInvalidDeadline
+}
+
+// migrateTaskStatuses раскладывает существующие задачи по колонкам доски:
+// выполненные попадают в planner.StatusDone, остальные остаются в
+// planner.StatusTodo. Порядок внутри колонки - по дате создания.
+func migrateTaskStatuses(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("UPDATE tasks SET status = ? WHERE completed", planner.StatusDone)
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := tx.Exec(`UPDATE tasks SET position = ranked.n - 1
+			FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, status ORDER BY created_at, id) AS n FROM tasks) ranked
+			WHERE tasks.id = ranked.id`).Error; err != nil {
+			return err
+		}
+		log.Printf("📋 Задачи разложены по колонкам доски (выполненных: %d)", result.RowsAffected)
+		return nil
+	})
+}
@@ -52,7 +52,7 @@ func Migrate(db *gorm.DB) error {
 	log.Println("🔧 Проверяю структуру базы данных...")
 
 	// Проверяем существование таблиц
-	tables := []string{"users", "tasks", "files", "scripts", "shadowrun_entries"}
+	tables := []string{"users", "tasks", "task_folders", "task_folder_members", "tags", "task_tags", "saved_filters", "board_columns", "checklist_items", "task_dependencies", "task_reminders", "calendar_feeds", "task_activities", "task_comments", "files", "file_folders", "file_shares", "file_versions", "file_contents", "notifications", "notification_preferences", "personal_tokens", "scripts", "shadowrun_entries"}
 
 	for _, table := range tables {
 		var exists bool
@@ -65,12 +65,38 @@ func Migrate(db *gorm.DB) error {
 		}
 	}
 
+	// Типы колонок, которые AutoMigrate не умеет преобразовать сам
+	if err := migrateTaskDeadlines(db); err != nil {
+		log.Printf("⚠️ Ошибка перевода дедлайнов задач: %v", err)
+	}
+
+	// Статусы доски появились позже completed: их нужно заполнить после AutoMigrate
+	backfillStatuses := db.Migrator().HasTable(&models.Task{}) && !db.Migrator().HasColumn(&models.Task{}, "status")
+
 	// Безопасный AutoMigrate - только для недостающих таблиц
 	log.Println("📝 Выполняю безопасную миграцию...")
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Task{},
+		&models.TaskFolder{},
+		&models.TaskFolderMember{},
+		&models.Tag{},
+		&models.SavedFilter{},
+		&models.BoardColumn{},
+		&models.ChecklistItem{},
+		&models.TaskDependency{},
+		&models.TaskReminder{},
+		&models.CalendarFeed{},
+		&models.TaskActivity{},
+		&models.TaskComment{},
 		&models.File{},
+		&models.FileFolder{},
+		&models.FileShare{},
+		&models.FileVersion{},
+		&models.FileContent{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.PersonalToken{},
 		&models.Script{},
 		&models.ShadowrunEntry{},
 	)
@@ -80,6 +106,19 @@ func Migrate(db *gorm.DB) error {
 		// НЕ завершаем с ошибкой - продолжаем работу
 	}
 
+	// Переносим данные из старых форматов
+	if err := migrateFileFolders(db); err != nil {
+		log.Printf("⚠️ Ошибка переноса папок файлов: %v", err)
+	}
+	if err := migrateTaskFolders(db); err != nil {
+		log.Printf("⚠️ Ошибка переноса папок задач: %v", err)
+	}
+	if backfillStatuses {
+		if err := migrateTaskStatuses(db); err != nil {
+			log.Printf("⚠️ Ошибка заполнения статусов задач: %v", err)
+		}
+	}
+
 	// Проверяем наличие пользователей
 	var userCount int64
 	db.Model(&models.User{}).Count(&userCount)
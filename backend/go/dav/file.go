@@ -0,0 +1,160 @@
+package dav
+
+import (
+	"context"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"golang.org/x/net/webdav"
+)
+
+// fileInfo - сведения о файле хранилища
+type fileInfo struct {
+	file *models.File
+}
+
+func (fi fileInfo) Name() string       { return path.Base(fi.file.OriginalFilename) }
+func (fi fileInfo) Size() int64        { return fi.file.FileSize }
+func (fi fileInfo) Mode() os.FileMode  { return 0644 }
+func (fi fileInfo) ModTime() time.Time { return fi.file.UploadedAt }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() interface{}   { return fi.file }
+
+// ETag совпадает с ETag скачивания через REST
+func (fi fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.file.ContentHash == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.file.ContentHash + `"`, nil
+}
+
+// ContentType берётся из метаданных, чтобы не читать файл для определения типа
+func (fi fileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.file.MimeType != "" {
+		return fi.file.MimeType, nil
+	}
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(fi.file.OriginalFilename))); t != "" {
+		return t, nil
+	}
+	return "", webdav.ErrNotImplemented
+}
+
+// dirInfo - сведения о папке (folder == nil для корня)
+type dirInfo struct {
+	name   string
+	folder *models.FileFolder
+}
+
+func (di dirInfo) Name() string      { return di.name }
+func (di dirInfo) Size() int64       { return 0 }
+func (di dirInfo) Mode() os.FileMode { return os.ModeDir | 0755 }
+func (di dirInfo) IsDir() bool       { return true }
+func (di dirInfo) Sys() interface{}  { return di.folder }
+
+func (di dirInfo) ModTime() time.Time {
+	if di.folder == nil {
+		return time.Time{}
+	}
+	return di.folder.UpdatedAt
+}
+
+// readFile - файл, открытый на чтение
+type readFile struct {
+	*storage.StoredFile
+	info fileInfo
+}
+
+func (f *readFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// dirFile - открытая папка
+type dirFile struct {
+	fs    *FileSystem
+	entry *entry
+	name  string
+
+	infos  []os.FileInfo
+	loaded bool
+	pos    int
+}
+
+func (d *dirFile) Close() error                                 { return nil }
+func (d *dirFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *dirFile) Write(p []byte) (int, error)                  { return 0, os.ErrPermission }
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+
+func (d *dirFile) Stat() (os.FileInfo, error) {
+	return dirInfo{name: d.name, folder: d.entry.folder}, nil
+}
+
+// Readdir следует контракту os.File.Readdir
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.loaded {
+		infos, err := d.fs.readdir(d.entry)
+		if err != nil {
+			return nil, err
+		}
+		d.infos, d.loaded = infos, true
+	}
+
+	rest := d.infos[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.infos)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.pos += count
+	return rest[:count], nil
+}
+
+// writeFile передаёт записываемые байты в storage.SaveUpload через канал;
+// файл появляется в хранилище при закрытии
+type writeFile struct {
+	pw   *io.PipeWriter
+	done chan error
+	info fileInfo
+	err  error
+	shut bool
+}
+
+func (w *writeFile) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+func (w *writeFile) Close() error {
+	if !w.shut {
+		w.shut = true
+		w.pw.Close()
+		w.err = <-w.done
+	}
+	return w.err
+}
+
+func (w *writeFile) Stat() (os.FileInfo, error) {
+	if w.info.file == nil {
+		return nil, os.ErrNotExist
+	}
+	return w.info, nil
+}
+
+func (w *writeFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (w *writeFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (w *writeFile) Readdir(count int) ([]os.FileInfo, error)     { return nil, os.ErrInvalid }
@@ -0,0 +1,346 @@
+// Package dav отображает файлы и папки пользователя на файловую систему
+// WebDAV. Все изменения проходят через те же операции пакета storage, что
+// и REST-обработчики: квота, корзина, папки, индексация и миниатюры.
+package dav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"golang.org/x/net/webdav"
+	"gorm.io/gorm"
+)
+
+// ErrIsDir - операция с файлом применена к папке
+var ErrIsDir = errors.New("is a directory")
+
+// FileSystem - файловое хранилище одного пользователя.
+// Корень содержит только папки: файлы вне папок не хранятся.
+type FileSystem struct {
+	db     *gorm.DB
+	userID uint
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// NewFileSystem создаёт файловую систему пользователя
+func NewFileSystem(db *gorm.DB, userID uint) *FileSystem {
+	return &FileSystem{db: db, userID: userID}
+}
+
+// entry - найденный по пути объект: папка, файл или корень
+type entry struct {
+	root   bool
+	folder *models.FileFolder
+	file   *models.File
+}
+
+// cleanPath превращает путь WebDAV в путь хранилища без ведущего слэша
+func cleanPath(name string) (string, error) {
+	p := strings.Trim(path.Clean("/"+name), "/")
+	if p == "" {
+		return "", nil
+	}
+	normalized, err := storage.NormalizeFolderPath(p)
+	if err != nil {
+		return "", os.ErrInvalid
+	}
+	return normalized, nil
+}
+
+// resolve ищет папку или файл по пути
+func (fs *FileSystem) resolve(p string) (*entry, error) {
+	if p == "" {
+		return &entry{root: true}, nil
+	}
+
+	folder, err := storage.FindFolder(fs.db, fs.userID, p)
+	if err == nil {
+		return &entry{folder: folder}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	parent := storage.ParentPath(p)
+	if parent == "" {
+		return nil, os.ErrNotExist
+	}
+
+	// При одинаковых именах в папке виден самый старый файл
+	var file models.File
+	err = fs.db.Where("user_id = ? AND folder = ? AND original_filename = ?", fs.userID, parent, path.Base(p)).
+		Order("id ASC").
+		First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry{file: &file}, nil
+}
+
+// parentFolder проверяет, что родитель пути существует и может хранить
+// объекты. Для корня возвращается nil.
+func (fs *FileSystem) parentFolder(p string) (*models.FileFolder, error) {
+	parent := storage.ParentPath(p)
+	if parent == "" {
+		return nil, nil
+	}
+	folder, err := storage.FindFolder(fs.db, fs.userID, parent)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, os.ErrNotExist
+	}
+	return folder, err
+}
+
+// Mkdir создаёт папку. Родительская папка должна существовать.
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p, err := cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.resolve(p); err == nil {
+		return os.ErrExist
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if _, err := fs.parentFolder(p); err != nil {
+		return err
+	}
+
+	_, err = storage.EnsureFolderPath(fs.db, fs.userID, p)
+	return err
+}
+
+// OpenFile открывает файл или папку на чтение, а при флагах записи -
+// файл для загрузки нового содержимого (используется при COPY)
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p, err := cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return fs.openWriter(p, flag)
+	}
+
+	e, err := fs.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if e.file == nil {
+		return &dirFile{fs: fs, entry: e, name: path.Base("/" + p)}, nil
+	}
+
+	f, err := storage.Open(e.file)
+	if err != nil {
+		return nil, err
+	}
+	return &readFile{StoredFile: f, info: fileInfo{file: e.file}}, nil
+}
+
+// openWriter готовит потоковую запись файла через storage.SaveUpload
+func (fs *FileSystem) openWriter(p string, flag int) (webdav.File, error) {
+	e, err := fs.resolve(p)
+	switch {
+	case err == nil && e.file == nil:
+		return nil, ErrIsDir
+	case err == nil && flag&os.O_EXCL != 0:
+		return nil, os.ErrExist
+	case errors.Is(err, os.ErrNotExist) && flag&os.O_CREATE == 0:
+		return nil, os.ErrNotExist
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	folder, err := fs.parentFolder(p)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil || !storage.AllowedFile(p) {
+		return nil, os.ErrPermission
+	}
+
+	pr, pw := io.Pipe()
+	w := &writeFile{pw: pw, done: make(chan error, 1)}
+	go func() {
+		file, err := fs.Put(p, pr, "")
+		pr.CloseWithError(err)
+		if err == nil {
+			w.info = fileInfo{file: file}
+		}
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// Put сохраняет содержимое файла по пути, заменяя существующий файл
+// с тем же именем. Возвращает сохранённый файл.
+func (fs *FileSystem) Put(name string, r io.Reader, mimeType string) (*models.File, error) {
+	p, err := cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if e, err := fs.resolve(p); err == nil && e.file == nil {
+		return nil, ErrIsDir
+	}
+
+	folder, err := fs.parentFolder(p)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		return nil, os.ErrPermission
+	}
+
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(p)))
+	}
+
+	return storage.SaveUpload(fs.db, r, storage.Upload{
+		UserID:   fs.userID,
+		Folder:   folder.Path,
+		Name:     path.Base(p),
+		MimeType: mimeType,
+		Replace:  true,
+	})
+}
+
+// RemoveAll перемещает файл или папку со всем содержимым в корзину
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	p, err := cleanPath(name)
+	if err != nil {
+		return err
+	}
+	e, err := fs.resolve(p)
+	if err != nil {
+		return err
+	}
+
+	return fs.db.Transaction(func(tx *gorm.DB) error {
+		switch {
+		case e.root:
+			return os.ErrPermission
+		case e.folder != nil:
+			_, err := storage.TrashFolder(tx, e.folder)
+			return err
+		default:
+			return storage.TrashFiles(tx, fs.userID, []models.File{*e.file})
+		}
+	})
+}
+
+// Rename переименовывает или перемещает файл либо папку
+func (fs *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldPath, err := cleanPath(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := cleanPath(newName)
+	if err != nil {
+		return err
+	}
+
+	e, err := fs.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	if e.root || newPath == "" {
+		return os.ErrPermission
+	}
+	if _, err := fs.resolve(newPath); err == nil {
+		return os.ErrExist
+	}
+
+	parent, err := fs.parentFolder(newPath)
+	if err != nil {
+		return err
+	}
+
+	if e.file != nil {
+		if parent == nil {
+			return os.ErrPermission
+		}
+		return fs.db.Transaction(func(tx *gorm.DB) error {
+			return storage.MoveFile(tx, e.file, parent.Path, path.Base(newPath))
+		})
+	}
+
+	// Папку нельзя переместить внутрь её самой
+	if newPath == e.folder.Path || strings.HasPrefix(newPath, e.folder.Path+"/") {
+		return os.ErrInvalid
+	}
+
+	var parentID *uint
+	if parent != nil {
+		parentID = &parent.ID
+	}
+	return storage.MoveFolder(fs.db, e.folder, parentID, path.Base(newPath), newPath)
+}
+
+// Stat возвращает сведения о файле или папке
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p, err := cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	e, err := fs.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if e.file != nil {
+		return fileInfo{file: e.file}, nil
+	}
+	return dirInfo{name: path.Base("/" + p), folder: e.folder}, nil
+}
+
+// readdir возвращает вложенные папки и файлы папки (или корня)
+func (fs *FileSystem) readdir(e *entry) ([]os.FileInfo, error) {
+	var folders []models.FileFolder
+	query := fs.db.Where("user_id = ?", fs.userID)
+	if e.root {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", e.folder.ID)
+	}
+	if err := query.Order("name ASC").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(folders))
+	seen := make(map[string]bool)
+	for i := range folders {
+		seen[folders[i].Name] = true
+		infos = append(infos, dirInfo{name: folders[i].Name, folder: &folders[i]})
+	}
+	if e.root {
+		return infos, nil
+	}
+
+	var files []models.File
+	if err := fs.db.Where("user_id = ? AND folder = ?", fs.userID, e.folder.Path).
+		Order("id ASC").
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+	for i := range files {
+		// Одноимённые файлы недоступны по пути, показываем только первый
+		if seen[files[i].OriginalFilename] {
+			continue
+		}
+		seen[files[i].OriginalFilename] = true
+		infos = append(infos, fileInfo{file: &files[i]})
+	}
+	return infos, nil
+}
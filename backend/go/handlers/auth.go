@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"portfolio/models"
+	"portfolio/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
@@ -140,7 +141,11 @@ func createAndReturnToken(c *gin.Context, user models.User) {
 			"email":         user.Email,
 			"storage_used":  user.StorageUsed,
 			"storage_quota": user.StorageQuota,
-			"created_at":    user.CreatedAt.Format(time.RFC3339),
+			// Достигнутый порог заполнения квоты в процентах (0 - всё в порядке)
+			"storage_warning":            storage.WarningLevel(user.StorageUsed, user.StorageQuota),
+			"storage_warning_thresholds": storage.WarningThresholds(),
+			"timezone":                   user.Location().String(),
+			"created_at":                 user.CreatedAt.Format(time.RFC3339),
 		},
 	})
 }
@@ -216,11 +221,48 @@ func GetUser(c *gin.Context) {
 			"email":         user.Email,
 			"storage_used":  user.StorageUsed,
 			"storage_quota": user.StorageQuota,
-			"created_at":    user.CreatedAt.Format(time.RFC3339),
+			// Достигнутый порог заполнения квоты в процентах (0 - всё в порядке)
+			"storage_warning":            storage.WarningLevel(user.StorageUsed, user.StorageQuota),
+			"storage_warning_thresholds": storage.WarningThresholds(),
+			"timezone":                   user.Location().String(),
+			"created_at":                 user.CreatedAt.Format(time.RFC3339),
 		},
 	})
 }
 
+// UpdateUserTimezone - смена часового пояса пользователя (имя IANA).
+// По нему считаются сроки задач "на сегодня" и просроченные.
+func UpdateUserTimezone(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Timezone string `json:"timezone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
+		return
+	}
+
+	// "Local" зависит от настроек сервера, поэтому не принимается
+	loc, err := time.LoadLocation(input.Timezone)
+	if err != nil || input.Timezone == "Local" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный часовой пояс"})
+		return
+	}
+
+	if err := db.Model(&models.User{}).Where("id = ?", userID).
+		Update("timezone", loc.String()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения часового пояса"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Часовой пояс обновлён",
+		"timezone": loc.String(),
+	})
+}
+
 // Logout - выход пользователя
 func Logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
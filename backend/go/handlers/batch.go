@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchRequest - список файлов для пакетной операции
+type batchRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=1000"`
+}
+
+// BatchDeleteFiles пакетное удаление файлов в корзину одной транзакцией
+func BatchDeleteFiles(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request batchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	var files []models.File
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Блокировка пропускает файлы, которые параллельный запрос уже
+		// переместил в корзину; они попадают в результаты как not_found
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND id IN ?", userID, request.IDs).
+			Find(&files).Error; err != nil {
+			return err
+		}
+		return storage.TrashFiles(tx, userID, files)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления файлов"})
+		return
+	}
+
+	used, quota, _ := storage.Usage(db, userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       fmt.Sprintf("В корзину перемещено файлов: %d", len(files)),
+		"results":       batchResults(request.IDs, files),
+		"storage_used":  used,
+		"storage_quota": quota,
+	})
+}
+
+// BatchMoveFiles пакетное перемещение файлов в папку одной транзакцией
+func BatchMoveFiles(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		batchRequest
+		Folder string `json:"folder" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	folderPath, err := storage.NormalizeFolderPath(request.Folder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+		return
+	}
+
+	var files []models.File
+	var folder *models.FileFolder
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND id IN ?", userID, request.IDs).Find(&files).Error; err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		var err error
+		if folder, err = storage.EnsureFolderPath(tx, userID, folderPath); err != nil {
+			return err
+		}
+
+		var ids []uint
+		for _, file := range files {
+			ids = append(ids, file.ID)
+		}
+		return tx.Model(&models.File{}).
+			Where("user_id = ? AND id IN ?", userID, ids).
+			UpdateColumns(map[string]interface{}{"folder": folder.Path, "folder_id": folder.ID}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка перемещения файлов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Перемещено файлов в '%s': %d", folderPath, len(files)),
+		"results": batchResults(request.IDs, files),
+	})
+}
+
+// DownloadArchive потоковая выдача zip-архива выбранных файлов или папки.
+// Архив пишется прямо в ответ, файлы целиком в память не загружаются.
+func DownloadArchive(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		IDs    []uint `json:"ids" binding:"max=1000"`
+		Folder string `json:"folder"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+	if len(request.IDs) == 0 && request.Folder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите файлы или папку"})
+		return
+	}
+
+	query := db.Where("user_id = ?", userID)
+	archiveName := "files"
+	baseFolder := ""
+
+	if request.Folder != "" {
+		folderPath, err := storage.NormalizeFolderPath(request.Folder)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+			return
+		}
+		query = query.Scopes(storage.SubtreeScope("folder", folderPath))
+		baseFolder = folderPath
+		archiveName = path.Base(folderPath)
+	}
+	if len(request.IDs) > 0 {
+		query = query.Where("id IN ?", request.IDs)
+	}
+
+	var files []models.File
+	if err := query.Order("folder ASC, original_filename ASC").Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения файлов"})
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файлы не найдены"})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveName + ".zip"}))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	used := make(map[string]bool)
+
+	for _, file := range files {
+		name := archiveEntryName(&file, baseFolder, used)
+		if err := writeArchiveEntry(zw, &file, name); err != nil {
+			// Заголовки уже отправлены, поэтому просто обрываем архив
+			log.Printf("⚠️ Ошибка архивации файла %d: %v", file.ID, err)
+			c.Abort()
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		log.Printf("⚠️ Ошибка завершения архива: %v", err)
+	}
+}
+
+// writeArchiveEntry копирует файл с диска в архив
+func writeArchiveEntry(zw *zip.Writer, file *models.File, name string) error {
+	src, err := storage.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Уже сжатые форматы сохраняем без повторного сжатия
+	method := zip.Deflate
+	switch strings.ToLower(filepath.Ext(file.OriginalFilename)) {
+	case ".jpg", ".jpeg", ".png", ".zip", ".docx":
+		method = zip.Store
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: file.UploadedAt.In(time.Local),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// archiveEntryName строит путь файла внутри архива относительно baseFolder
+// и добавляет суффикс, пока имя совпадает с уже записанным
+func archiveEntryName(file *models.File, baseFolder string, used map[string]bool) string {
+	dir := file.Folder
+	if baseFolder != "" {
+		dir = strings.TrimPrefix(strings.TrimPrefix(dir, baseFolder), "/")
+	}
+
+	base := path.Base(strings.ReplaceAll(file.OriginalFilename, "\\", "/"))
+	switch base {
+	case ".", "..", "/":
+		// Такие имена выводят запись за пределы папки архива
+		base = fmt.Sprintf("file_%d", file.ID)
+	}
+
+	name := path.Join(dir, base)
+	stem, ext := strings.TrimSuffix(name, path.Ext(name)), path.Ext(name)
+	for n := 1; used[name]; n++ {
+		name = fmt.Sprintf("%s (%d)%s", stem, n, ext)
+	}
+	used[name] = true
+	return name
+}
+
+// batchResults сопоставляет запрошенные ID с обработанными файлами
+func batchResults(ids []uint, files []models.File) []gin.H {
+	found := make(map[uint]bool, len(files))
+	for _, file := range files {
+		found[file.ID] = true
+	}
+
+	results := make([]gin.H, len(ids))
+	for i, id := range ids {
+		if found[id] {
+			results[i] = gin.H{"id": id, "status": "ok"}
+		} else {
+			results[i] = gin.H{"id": id, "status": "not_found", "error": "Файл не найден"}
+		}
+	}
+	return results
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// boardColumn - колонка доски вместе с её задачами по порядку
+type boardColumn struct {
+	models.BoardColumn
+	Tasks []models.Task `json:"tasks"`
+}
+
+// respondBoardError переводит ошибку изменения доски в HTTP-ответ
+func respondBoardError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, planner.ErrColumnNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Board column not found"})
+	case errors.Is(err, planner.ErrColumnExists), errors.Is(err, planner.ErrColumnNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, planner.ErrColumnKey),
+		errors.Is(err, planner.ErrColumnRequired),
+		errors.Is(err, planner.ErrColumnOrder),
+		errors.Is(err, planner.ErrColumnTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// userColumn загружает колонку :id пользователя
+func userColumn(tx *gorm.DB, c *gin.Context, userID uint) (*models.BoardColumn, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, planner.ErrColumnNotFound
+	}
+	var column models.BoardColumn
+	if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&column).Error; err != nil {
+		return nil, err
+	}
+	return &column, nil
+}
+
+// GetBoard - канбан-доска: колонки по порядку и задачи в каждой из них.
+// Параметры folder и parent фильтруют задачи так же, как в GetTasks.
+// Задачи общих папок показываются в колонках пользователя; если колонки
+// с их статусом у него нет или она другого вида - в первой колонке по
+// признаку выполнения. MoveTask переводит колонку обратно через OwnerColumn.
+func GetBoard(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	columns, err := planner.Columns(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	query := db.Scopes(planner.VisibleTo(userID))
+	if folder := c.Query("folder"); folder != "" && folder != "all" {
+		query = query.Where("folder = ?", folder)
+	}
+	switch parent := c.Query("parent"); parent {
+	case "":
+	case "none":
+		query = query.Where("parent_id IS NULL")
+	default:
+		parentID, err := strconv.Atoi(parent)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+			return
+		}
+		query = query.Where("parent_id = ?", parentID)
+	}
+
+	var tasks []models.Task
+	if err := query.Order("position ASC, id ASC").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	board := make([]boardColumn, len(columns))
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		board[i] = boardColumn{BoardColumn: column, Tasks: []models.Task{}}
+		index[column.Key] = i
+	}
+	for _, task := range tasks {
+		i, ok := index[task.Status]
+		if task.UserID != userID && ok && columns[i].IsDone != task.Completed {
+			ok = false
+		}
+		if !ok {
+			column, err := planner.CompletionColumn(columns, task.Completed)
+			if err != nil || task.UserID == userID {
+				continue
+			}
+			i = index[column.Key]
+		}
+		planner.Localize(&task, loc)
+		board[i].Tasks = append(board[i].Tasks, task)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"columns": board,
+		"count":   len(tasks),
+	})
+}
+
+// GetBoardColumns - колонки доски по порядку
+func GetBoardColumns(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	columns, err := planner.Columns(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"columns": columns,
+		"count":   len(columns),
+	})
+}
+
+// CreateBoardColumn - новая колонка (position - необязательная позиция с 0)
+func CreateBoardColumn(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Key      string `json:"key" binding:"required"`
+		Name     string `json:"name" binding:"required,max=100"`
+		IsDone   bool   `json:"is_done"`
+		Position *int   `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	position := -1
+	if input.Position != nil {
+		position = *input.Position
+	}
+	column := models.BoardColumn{Key: input.Key, Name: input.Name, IsDone: input.IsDone}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return planner.AddColumn(tx, userID, &column, position)
+	})
+	if err != nil {
+		respondBoardError(c, err, "Failed to create board column")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Board column created",
+		"column":  column,
+	})
+}
+
+// UpdateBoardColumn - переименование колонки и смена признака is_done
+// (только у пустой колонки)
+func UpdateBoardColumn(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name   string `json:"name" binding:"max=100"`
+		IsDone *bool  `json:"is_done"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var column *models.BoardColumn
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if column, err = userColumn(tx, c, userID); err != nil {
+			return err
+		}
+		return planner.UpdateColumn(tx, column, input.Name, input.IsDone)
+	})
+	if err != nil {
+		respondBoardError(c, err, "Failed to update board column")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Board column updated",
+		"column":  column,
+	})
+}
+
+// ReorderBoardColumns - новый порядок колонок: column_ids перечисляет все колонки
+func ReorderBoardColumns(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		ColumnIDs []uint `json:"column_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return planner.ReorderColumns(tx, userID, input.ColumnIDs)
+	})
+	if err != nil {
+		respondBoardError(c, err, "Failed to reorder board columns")
+		return
+	}
+
+	GetBoardColumns(c)
+}
+
+// DeleteBoardColumn - удаление колонки; её задачи переносятся в конец
+// колонки move_to с тем же is_done
+func DeleteBoardColumn(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	target := c.Query("move_to")
+	if target == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "move_to is required"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		column, err := userColumn(tx, c, userID)
+		if err != nil {
+			return err
+		}
+		return planner.DeleteColumn(tx, column, target)
+	})
+	if err != nil {
+		respondBoardError(c, err, "Failed to delete board column")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Board column deleted"})
+}
+
+// MoveTask - перенос задачи в колонку status на позицию position (с 0,
+// по умолчанию в конец) с перенумерацией обеих колонок. Для задачи общей
+// папки status - ключ колонки доски пользователя, как в GetBoard. Перенос
+// в колонку выполненных задач проверяет блокирующие задачи и, как
+// UpdateTaskStatus, записывается в журнал задачи.
+func MoveTask(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		Status   string `json:"status" binding:"required"`
+		Position *int   `json:"position"`
+		Force    bool   `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	position := -1
+	if input.Position != nil {
+		position = *input.Position
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var task models.Task
+	var next *models.Task
+	var blockers []models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		before := planner.TakeSnapshot(&task, loc)
+		// Задача общей папки перемещается по доске её владельца, а колонка
+		// участника, в которую её перетащили, сопоставляется колонке владельца
+		columns, err := planner.Columns(tx, task.UserID)
+		if err != nil {
+			return err
+		}
+		var column *models.BoardColumn
+		if task.UserID == userID {
+			column, err = planner.FindColumn(columns, input.Status)
+		} else {
+			var own []models.BoardColumn
+			if own, err = planner.Columns(tx, userID); err != nil {
+				return err
+			}
+			column, err = planner.OwnerColumn(own, columns, input.Status)
+		}
+		if err != nil {
+			return err
+		}
+
+		if column.IsDone && !task.Completed {
+			if blockers, err = checkBlockers(tx, &task, input.Force); err != nil {
+				return err
+			}
+		}
+		wasCompleted := task.Completed
+		if err := planner.PlaceTask(tx, &task, column, position); err != nil {
+			return err
+		}
+		if next, err = afterStatusChange(tx, &task, wasCompleted); err != nil {
+			return err
+		}
+		return planner.LogChanges(tx, userID, models.ActivityStatus, &task, before, loc)
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to move task")
+		return
+	}
+	localizeTaskPair(&task, next, loc)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Task moved",
+		"task":          task,
+		"next_task":     next,
+		"open_blockers": blockers,
+	})
+}
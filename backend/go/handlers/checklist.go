@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errItemNotFound - пункт чек-листа не найден в задаче
+var errItemNotFound = errors.New("checklist item not found")
+
+// checklistItem загружает пункт :item задачи; задача уже проверена lockTask
+func checklistItem(tx *gorm.DB, c *gin.Context, taskID uint) (*models.ChecklistItem, error) {
+	itemID, err := strconv.Atoi(c.Param("item"))
+	if err != nil {
+		return nil, errItemNotFound
+	}
+	var item models.ChecklistItem
+	err = tx.Where("id = ? AND task_id = ?", itemID, taskID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errItemNotFound
+	}
+	return &item, err
+}
+
+// respondChecklistError переводит ошибку изменения чек-листа в HTTP-ответ
+func respondChecklistError(c *gin.Context, err error, message string) {
+	if errors.Is(err, errItemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Checklist item not found"})
+		return
+	}
+	respondTaskError(c, err, message)
+}
+
+// checklistResponse возвращает чек-лист задачи и её пересчитанный прогресс
+func checklistResponse(c *gin.Context, db *gorm.DB, taskID uint, status int, extra gin.H) {
+	var items []models.ChecklistItem
+	if err := db.Where("task_id = ?", taskID).Order("position ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	var progress int
+	if err := db.Model(&models.Task{}).Where("id = ?", taskID).Pluck("progress", &progress).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	response := gin.H{
+		"checklist": items,
+		"count":     len(items),
+		"progress":  progress,
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(status, response)
+}
+
+// GetChecklist - пункты чек-листа задачи по порядку
+func GetChecklist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	if err := db.Select("id").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&task).Error; err != nil {
+		respondTaskError(c, err, "Database error")
+		return
+	}
+
+	checklistResponse(c, db, task.ID, http.StatusOK, nil)
+}
+
+// AddChecklistItem - добавление пункта (position - необязательная позиция с 0)
+func AddChecklistItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		Title    string `json:"title" binding:"required,max=255"`
+		Position *int   `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var item *models.ChecklistItem
+	var task models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		item, err = planner.AddChecklistItem(tx, task.ID, input.Title, input.Position)
+		return err
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to add checklist item")
+		return
+	}
+
+	checklistResponse(c, db, task.ID, http.StatusCreated, gin.H{"item": item})
+}
+
+// UpdateChecklistItem - изменение текста и/или отметки пункта
+func UpdateChecklistItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		Title *string `json:"title" binding:"omitempty,min=1,max=255"`
+		Done  *bool   `json:"done"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var task models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		item, err := checklistItem(tx, c, task.ID)
+		if err != nil {
+			return err
+		}
+
+		if input.Title != nil {
+			item.Title = *input.Title
+		}
+		if input.Done != nil {
+			item.Done = *input.Done
+		}
+		if err := tx.Save(item).Error; err != nil {
+			return err
+		}
+		return planner.RecalcProgress(tx, task.ID)
+	})
+	if err != nil {
+		respondChecklistError(c, err, "Failed to update checklist item")
+		return
+	}
+
+	checklistResponse(c, db, task.ID, http.StatusOK, nil)
+}
+
+// ToggleChecklistItem - переключение отметки пункта
+func ToggleChecklistItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		item, err := checklistItem(tx, c, task.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(item).Update("done", !item.Done).Error; err != nil {
+			return err
+		}
+		return planner.RecalcProgress(tx, task.ID)
+	})
+	if err != nil {
+		respondChecklistError(c, err, "Failed to toggle checklist item")
+		return
+	}
+
+	checklistResponse(c, db, task.ID, http.StatusOK, nil)
+}
+
+// ReorderChecklist - новый порядок пунктов: item_ids перечисляет все пункты
+func ReorderChecklist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		ItemIDs []uint `json:"item_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var task models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		return planner.ReorderChecklist(tx, task.ID, input.ItemIDs)
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to reorder checklist")
+		return
+	}
+
+	checklistResponse(c, db, task.ID, http.StatusOK, nil)
+}
+
+// DeleteChecklistItem - удаление пункта
+func DeleteChecklistItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		item, err := checklistItem(tx, c, task.ID)
+		if err != nil {
+			return err
+		}
+		return planner.DeleteChecklistItem(tx, item)
+	})
+	if err != nil {
+		respondChecklistError(c, err, "Failed to delete checklist item")
+		return
+	}
+
+	checklistResponse(c, db, task.ID, http.StatusOK, nil)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateFileFolder создание папки для файлов.
+// Имя может быть путём ("docs/2024"), недостающие родительские папки создаются.
+func CreateFileFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		Name       string `json:"name" binding:"required"`
+		ParentPath string `json:"parent_path"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	// Проверяем имя папки
+	if strings.TrimSpace(request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Имя папки не может быть пустым"})
+		return
+	}
+
+	path, err := storage.NormalizeFolderPath(storage.JoinFolderPath(request.ParentPath, request.Name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+		return
+	}
+
+	// Проверяем, нет ли уже такой папки
+	_, err = storage.FindFolder(db, userID, path)
+	exists := err == nil
+
+	folder, err := storage.EnsureFolderPath(db, userID, path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания папки"})
+		return
+	}
+
+	var fileCount int64
+	db.Model(&models.File{}).Where("user_id = ? AND folder_id = ?", userID, folder.ID).Count(&fileCount)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Папка создана",
+		"folder": gin.H{
+			"id":         folder.ID,
+			"parent_id":  folder.ParentID,
+			"name":       folder.Name,
+			"path":       folder.Path,
+			"file_count": fileCount,
+			"exists":     exists,
+		},
+	})
+}
+
+// GetFileFolders - получение списка папок пользователя
+func GetFileFolders(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var folders []models.FileFolder
+	if err := db.Where("user_id = ?", userID).Order("path ASC").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
+		return
+	}
+
+	// Добавляем папку "all" для фильтрации
+	paths := []string{"all"}
+	for _, folder := range folders {
+		paths = append(paths, folder.Path)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"folders": paths,
+		"items":   folders,
+		"count":   len(paths),
+	})
+}
+
+// RenameFileFolder переименование папки вместе со всем поддеревом
+func RenameFileFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if normalized, err := storage.NormalizeFolderPath(name); err != nil || normalized != name {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+		return
+	}
+
+	folder, ok := findUserFolder(c, db, userID)
+	if !ok {
+		return
+	}
+
+	newPath := storage.JoinFolderPath(storage.ParentPath(folder.Path), name)
+	if !relocateFolder(c, db, folder, folder.ParentID, name, newPath) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Папка переименована",
+		"folder":  folder,
+	})
+}
+
+// MoveFileFolder перемещение папки вместе со всем поддеревом.
+// parent_id = null переносит папку в корень.
+func MoveFileFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		ParentID *uint `json:"parent_id"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	folder, ok := findUserFolder(c, db, userID)
+	if !ok {
+		return
+	}
+
+	parentPath := ""
+	if request.ParentID != nil {
+		var parent models.FileFolder
+		if err := db.Where("id = ? AND user_id = ?", *request.ParentID, userID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Папка назначения не найдена"})
+			return
+		}
+		if parent.Path == folder.Path || strings.HasPrefix(parent.Path, folder.Path+"/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя переместить папку в саму себя"})
+			return
+		}
+		parentPath = parent.Path
+	}
+
+	newPath := storage.JoinFolderPath(parentPath, folder.Name)
+	if !relocateFolder(c, db, folder, request.ParentID, folder.Name, newPath) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Папка перемещена",
+		"folder":  folder,
+	})
+}
+
+// DeleteFileFolder удаление папки со всеми вложенными папками.
+// Файлы из поддерева перемещаются в корзину.
+func DeleteFileFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	folder, ok := findUserFolder(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var files []models.File
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		files, err = storage.TrashFolder(tx, folder)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления папки"})
+		return
+	}
+
+	used, quota, _ := storage.Usage(db, userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       fmt.Sprintf("Папка '%s' удалена", folder.Path),
+		"trashed_files": len(files),
+		"storage_used":  used,
+		"storage_quota": quota,
+	})
+}
+
+// findUserFolder загружает папку по :id и проверяет владельца
+func findUserFolder(c *gin.Context, db *gorm.DB, userID uint) (*models.FileFolder, bool) {
+	var folder models.FileFolder
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&folder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Папка не найдена"})
+		return nil, false
+	}
+	return &folder, true
+}
+
+// relocateFolder переносит папку на новый путь вместе с поддеревом
+func relocateFolder(c *gin.Context, db *gorm.DB, folder *models.FileFolder, parentID *uint, name, newPath string) bool {
+	if err := storage.MoveFolder(db, folder, parentID, name, newPath); err != nil {
+		if errors.Is(err, storage.ErrFolderExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Папка с таким именем уже существует"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления папки"})
+		return false
+	}
+	return true
+}
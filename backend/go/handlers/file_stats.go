@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetFileStats аналитика хранилища: разбивка по папкам, типам и месяцам,
+// самые большие файлы и рост занятого объёма
+func GetFileStats(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	top, err := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if err != nil || top <= 0 || top > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр top должен быть от 1 до 50"})
+		return
+	}
+
+	stats, err := storage.Stats(db, userID, top)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения статистики"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -1,18 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
 	"os"
-	"path/filepath"
 	"portfolio/models"
+	"portfolio/storage"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -30,23 +30,13 @@ func UploadFile(c *gin.Context) {
 	defer file.Close()
 
 	// Проверяем размер файла (макс 50MB)
-	maxSize := int64(50 * 1024 * 1024) // 50MB
-	if header.Size > maxSize {
+	if header.Size > storage.MaxFileSize {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл слишком большой (макс. 50MB)"})
 		return
 	}
 
 	// Проверяем расширение файла
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	allowedExts := []string{".pdf", ".jpg", ".jpeg", ".png", ".doc", ".docx", ".txt", ".go", ".zip"}
-	allowed := false
-	for _, a := range allowedExts {
-		if ext == a {
-			allowed = true
-			break
-		}
-	}
-	if !allowed {
+	if !storage.AllowedFile(header.Filename) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Тип файла не разрешён"})
 		return
 	}
@@ -67,58 +57,39 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Создаём уникальное имя файла
-	fileID := uuid.New().String()
-	newFilename := fileID + ext
-	uploadPath := filepath.Join("uploads", strconv.Itoa(int(userID)))
-
-	// Создаём директорию если её нет
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания директории"})
-		return
-	}
-
-	// Сохраняем файл
-	filePath := filepath.Join(uploadPath, newFilename)
-	dst, err := os.Create(filePath)
+	// Получаем папку из формы
+	folder, err := storage.NormalizeFolderPath(c.PostForm("folder"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения файла"})
-		return
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка копирования файла"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
 		return
 	}
 
-	// Получаем папку из формы
-	folder := c.PostForm("folder")
-	if folder == "" {
-		folder = "general"
-	}
-
-	// Создаём запись в базе данных
-	fileRecord := models.File{
-		UserID:           userID,
-		Filename:         newFilename,
-		OriginalFilename: header.Filename,
-		FilePath:         filePath,
-		FileSize:         header.Size,
-		MimeType:         header.Header.Get("Content-Type"),
-		Folder:           folder,
-		UploadedAt:       time.Now(),
-	}
-
-	if err := db.Create(&fileRecord).Error; err != nil {
-		os.Remove(filePath) // Удаляем файл если не удалось сохранить запись
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения в базу данных"})
+	// Резервируем место и создаём запись; режим версий включается
+	// полем формы versioning=true
+	fileRecord, err := storage.SaveUpload(db, file, storage.Upload{
+		UserID:     userID,
+		Folder:     folder,
+		Name:       header.Filename,
+		MimeType:   header.Header.Get("Content-Type"),
+		Versioning: c.PostForm("versioning") == "true" || c.PostForm("versioning") == "1",
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrQuotaExceeded):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Недостаточно места в хранилище. Использовано: %s/%s",
+					formatBytes(user.StorageUsed), formatBytes(user.StorageQuota)),
+			})
+		case errors.Is(err, storage.ErrFileTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Файл слишком большой (макс. 50MB)"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения файла"})
+		}
 		return
 	}
 
-	// Обновляем использованное место
-	user.StorageUsed += header.Size
-	db.Save(&user)
+	// Получаем актуальное использованное место
+	user.StorageUsed, user.StorageQuota, _ = storage.Usage(db, userID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Файл успешно загружен",
@@ -128,6 +99,7 @@ func UploadFile(c *gin.Context) {
 			"size":        fileRecord.FileSize,
 			"size_human":  fileRecord.GetSizeHuman(),
 			"folder":      fileRecord.Folder,
+			"version":     fileRecord.Version,
 			"uploaded_at": fileRecord.UploadedAt.Format("2006-01-02 15:04:05"),
 		},
 		"storage_used":  user.StorageUsed,
@@ -135,20 +107,81 @@ func UploadFile(c *gin.Context) {
 	})
 }
 
-// GetFiles получение файлов пользователя
+// fileListSpec - сортировка списка файлов
+var fileListSpec = listSpec{
+	SortFields: map[string]string{
+		"uploaded_at": "uploaded_at",
+		"filename":    "original_filename",
+		"size":        "file_size",
+	},
+	DefaultSort:  "uploaded_at",
+	DefaultOrder: "desc",
+}
+
+// GetFiles получение файлов пользователя.
+// Поддерживает общие параметры списков, фильтры folder, mime_type, uploaded_from/uploaded_to.
 func GetFiles(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
 	folder := c.Query("folder")
 
+	list, err := parseListQuery(c, fileListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var files []models.File
-	query := db.Where("user_id = ?", userID)
+	query := db.Model(&models.File{}).Where("user_id = ?", userID)
+
+	// Фильтр по MIME-типу: точное значение или префикс ("image/" или "image/*")
+	if mimeType := c.Query("mime_type"); mimeType != "" {
+		if prefix, ok := strings.CutSuffix(mimeType, "*"); ok || strings.HasSuffix(mimeType, "/") {
+			query = query.Where("mime_type LIKE ?", strings.TrimSuffix(prefix, "*")+"%")
+		} else {
+			query = query.Where("mime_type = ?", mimeType)
+		}
+	}
+	if query, err = dateRangeFilter(c, query, "uploaded", "uploaded_at"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Вложенные папки и "хлебные крошки" текущей папки
+	subfolders := []models.FileFolder{}
+	breadcrumbs := []models.FileFolder{}
 
 	if folder != "" && folder != "all" {
-		query = query.Where("folder = ?", folder)
-	}
+		path, err := storage.NormalizeFolderPath(folder)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+			return
+		}
+		query = query.Where("folder = ?", path)
 
-	if err := query.Find(&files).Error; err != nil {
+		if breadcrumbs, err = storage.Breadcrumbs(db, userID, path); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
+			return
+		}
+		if n := len(breadcrumbs); n > 0 && breadcrumbs[n-1].Path == path {
+			db.Where("user_id = ? AND parent_id = ?", userID, breadcrumbs[n-1].ID).
+				Order("name ASC").Find(&subfolders)
+		}
+	} else {
+		db.Where("user_id = ? AND parent_id IS NULL", userID).Order("name ASC").Find(&subfolders)
+	}
+
+	total, nextCursor, err := paginate(query, list, &files, func(f *models.File) (interface{}, uint) {
+		switch list.Sort {
+		case "filename":
+			return f.OriginalFilename, f.ID
+		case "size":
+			return f.FileSize, f.ID
+		default:
+			return f.UploadedAt, f.ID
+		}
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения файлов"})
 		return
 	}
@@ -166,12 +199,21 @@ func GetFiles(c *gin.Context) {
 			"size_human":  file.GetSizeHuman(),
 			"mime_type":   file.MimeType,
 			"folder":      file.Folder,
+			"folder_id":   file.FolderID,
+			"version":     file.Version,
 			"uploaded_at": file.UploadedAt.Format("2006-01-02 15:04:05"),
+			"has_thumb":   storage.SupportsThumbnail(&file),
+			"has_preview": storage.SupportsPreview(&file),
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"files":         response,
+		"count":         len(response),
+		"total":         total,
+		"next_cursor":   nextCursor,
+		"subfolders":    subfolders,
+		"breadcrumbs":   breadcrumbs,
 		"storage_used":  user.StorageUsed,
 		"storage_quota": user.StorageQuota, // Используем из модели
 	})
@@ -198,7 +240,11 @@ func GetFile(c *gin.Context) {
 			"size_human":  file.GetSizeHuman(),
 			"mime_type":   file.MimeType,
 			"folder":      file.Folder,
+			"folder_id":   file.FolderID,
+			"version":     file.Version,
 			"uploaded_at": file.UploadedAt.Format("2006-01-02 15:04:05"),
+			"has_thumb":   storage.SupportsThumbnail(&file),
+			"has_preview": storage.SupportsPreview(&file),
 		},
 	})
 }
@@ -215,17 +261,114 @@ func DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// Проверяем существует ли файл
-	if _, err := os.Stat(file.FilePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Файл на диске не найден"})
+	serveFile(c, db, &file)
+}
+
+// GetSignedDownloadURL выдача временной подписанной ссылки на скачивание.
+// Ссылка работает без заголовка Authorization, например в <img> или <video>.
+func GetSignedDownloadURL(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	ttl := 15 * time.Minute
+	if raw := c.Query("ttl"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds <= 0 || seconds > 7*24*3600 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный срок действия ссылки"})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	signature, err := storage.SignDownload(file.ID, expires)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Подписанные ссылки отключены: не задан SIGNED_URL_SECRET"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"url": fmt.Sprintf("/api/files/signed/%d?expires=%d&signature=%s",
+			file.ID, expires.Unix(), signature),
+		"expires_at": expires.Format(time.RFC3339),
+	})
+}
+
+// DownloadSignedFile скачивание файла по подписанной ссылке (без аутентификации)
+func DownloadSignedFile(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	fileID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || !storage.VerifyDownload(uint(fileID), c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Ссылка недействительна или истекла"})
+		return
+	}
+
+	var file models.File
+	if err := db.First(&file, fileID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	serveFile(c, db, &file)
+}
+
+// serveFile отправляет содержимое файла клиенту.
+// Используется и авторизованным скачиванием, и публичными ссылками.
+// Поддерживает Range, ETag (хеш содержимого), If-None-Match и If-Modified-Since.
+func serveFile(c *gin.Context, db *gorm.DB, file *models.File) {
+	f, ok := openServedFile(c, db, file)
+	if !ok {
 		return
 	}
+	defer f.Close()
+	sendFile(c, file, f)
+}
+
+// openServedFile открывает содержимое файла для отправки; при ошибке
+// отвечает сам. Файлы, загруженные до появления хеша, хешируются.
+func openServedFile(c *gin.Context, db *gorm.DB, file *models.File) (*storage.StoredFile, bool) {
+	f, err := storage.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Файл на диске не найден"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения файла"})
+		return nil, false
+	}
+
+	// Файлы, загруженные до появления хеша, хешируем при первом скачивании
+	if file.ContentHash == "" {
+		if hash, err := storage.HashFile(file); err == nil {
+			file.ContentHash = hash
+			db.Model(file).UpdateColumn("content_hash", hash)
+		}
+	}
+	return f, true
+}
+
+// sendFile отправляет открытое содержимое файла с заголовками скачивания
+func sendFile(c *gin.Context, file *models.File, f *storage.StoredFile) {
+	if file.ContentHash != "" {
+		c.Header("ETag", `"`+file.ContentHash+`"`)
+	}
+	if file.MimeType != "" {
+		c.Header("Content-Type", file.MimeType)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.OriginalFilename}))
+	c.Header("Cache-Control", "private, no-cache")
 
-	// Отправляем файл
-	c.FileAttachment(file.FilePath, file.OriginalFilename)
+	// ServeContent сам обрабатывает Range и условные заголовки по ETag и Last-Modified
+	http.ServeContent(c.Writer, c.Request, file.OriginalFilename, f.ModTime(), f)
 }
 
-// DeleteFile удаление файла
+// DeleteFile удаление файла в корзину
 func DeleteFile(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
@@ -237,26 +380,24 @@ func DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// Удаляем файл с диска
-	if err := os.Remove(file.FilePath); err != nil {
+	// Перемещаем файл в корзину, байты остаются на диске
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return storage.TrashFiles(tx, userID, []models.File{file})
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления файла"})
 		return
 	}
 
-	// Обновляем квоту пользователя
 	var user models.User
-	db.First(&user, userID)
-	user.StorageUsed -= file.FileSize
-	if user.StorageUsed < 0 {
-		user.StorageUsed = 0
-	}
-	db.Save(&user)
-
-	// Удаляем запись из базы данных
-	db.Delete(&file)
+	user.StorageUsed, user.StorageQuota, _ = storage.Usage(db, userID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":       "Файл успешно удалён",
+		"message":       "Файл перемещён в корзину",
 		"storage_used":  user.StorageUsed,
 		"storage_quota": user.StorageQuota, // Используем из модели
 	})
@@ -305,63 +446,6 @@ func RenameFile(c *gin.Context) {
 	})
 }
 
-// CreateFileFolder создание папки для файлов
-func CreateFileFolder(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	userID := c.GetUint("user_id")
-
-	var request struct {
-		Name string `json:"name" binding:"required"`
-	}
-
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
-		return
-	}
-
-	// Проверяем имя папки
-	if request.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Имя папки не может быть пустым"})
-		return
-	}
-
-	// Проверяем, нет ли уже такой папки
-	var existingFiles []models.File
-	db.Where("user_id = ? AND folder = ?", userID, request.Name).Find(&existingFiles)
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Папка создана",
-		"folder": gin.H{
-			"name":       request.Name,
-			"file_count": len(existingFiles),
-			"exists":     len(existingFiles) > 0,
-		},
-	})
-}
-
-// GetFileFolders - получение списка папок пользователя
-func GetFileFolders(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	userID := c.GetUint("user_id")
-
-	var folders []string
-	if err := db.Model(&models.File{}).
-		Where("user_id = ?", userID).
-		Distinct("folder").
-		Pluck("folder", &folders).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
-		return
-	}
-
-	// Добавляем папку "all" для фильтрации
-	allFolders := append([]string{"all"}, folders...)
-
-	c.JSON(http.StatusOK, gin.H{
-		"folders": allFolders,
-		"count":   len(allFolders),
-	})
-}
-
 // MoveFile перемещение файла в другую папку
 func MoveFile(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
@@ -383,36 +467,36 @@ func MoveFile(c *gin.Context) {
 		return
 	}
 
+	path, err := storage.NormalizeFolderPath(request.Folder)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+		return
+	}
+
 	// Сохраняем старую папку для сообщения
 	oldFolder := file.Folder
 
-	// Обновляем папку файла
-	file.Folder = request.Folder
-	if err := db.Save(&file).Error; err != nil {
+	// Обновляем папку файла, создавая её при необходимости
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return storage.MoveFile(tx, &file, path, file.OriginalFilename)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при перемещении файла"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Файл перемещён из '%s' в '%s'", oldFolder, request.Folder),
+		"message": fmt.Sprintf("Файл перемещён из '%s' в '%s'", oldFolder, file.Folder),
 		"file": gin.H{
-			"id":       file.ID,
-			"filename": file.OriginalFilename,
-			"folder":   file.Folder,
+			"id":        file.ID,
+			"filename":  file.OriginalFilename,
+			"folder":    file.Folder,
+			"folder_id": file.FolderID,
 		},
 	})
 }
 
 // Вспомогательная функция для форматирования байтов
 func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return storage.FormatBytes(bytes)
 }
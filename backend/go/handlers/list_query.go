@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Общие параметры списков:
+//
+//	limit      - размер страницы (по умолчанию 100, максимум 500)
+//	cursor     - непрозрачный курсор из next_cursor предыдущего ответа
+//	sort       - поле сортировки (набор зависит от эндпоинта)
+//	order      - asc или desc
+//	<поле>_from, <поле>_to - диапазоны дат (2006-01-02 или RFC3339)
+//
+// Ответ содержит count (элементов на странице), total (всего по фильтрам)
+// и next_cursor (пустой, если страниц больше нет).
+const (
+	defaultListLimit = 100
+	maxListLimit     = 500
+)
+
+// listSpec описывает допустимую сортировку конкретного списка
+type listSpec struct {
+	// SortFields сопоставляет значение параметра sort с SQL-выражением
+	SortFields   map[string]string
+	DefaultSort  string
+	DefaultOrder string
+	// IDColumn - уникальная колонка для разрешения равных значений сортировки
+	IDColumn string
+}
+
+// listQuery - разобранные параметры пагинации и сортировки
+type listQuery struct {
+	Limit     int
+	Sort      string
+	SortExpr  string
+	Desc      bool
+	idColumn  string
+	cursor    *listCursor
+	hasCursor bool
+}
+
+// listCursor - позиция последнего элемента страницы
+type listCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// parseListQuery разбирает limit, cursor, sort и order
+func parseListQuery(c *gin.Context, spec listSpec) (*listQuery, error) {
+	q := &listQuery{
+		Limit:    defaultListLimit,
+		Sort:     spec.DefaultSort,
+		Desc:     spec.DefaultOrder == "desc",
+		idColumn: spec.IDColumn,
+	}
+	if q.idColumn == "" {
+		q.idColumn = "id"
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return nil, fmt.Errorf("limit должен быть от 1 до %d", maxListLimit)
+		}
+		q.Limit = limit
+	}
+
+	if raw := c.Query("sort"); raw != "" {
+		// Допускается краткая запись sort=-field
+		if strings.HasPrefix(raw, "-") {
+			raw = raw[1:]
+			q.Desc = true
+		} else if c.Query("order") == "" {
+			q.Desc = false
+		}
+		if _, ok := spec.SortFields[raw]; !ok {
+			return nil, fmt.Errorf("сортировка по полю %q не поддерживается", raw)
+		}
+		q.Sort = raw
+	}
+	q.SortExpr = spec.SortFields[q.Sort]
+
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return nil, errors.New("order должен быть asc или desc")
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		data, err := base64.RawURLEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, errors.New("неверный курсор")
+		}
+		var cur listCursor
+		if err := json.Unmarshal(data, &cur); err != nil {
+			return nil, errors.New("неверный курсор")
+		}
+		q.cursor = &cur
+		q.hasCursor = true
+	}
+
+	return q, nil
+}
+
+// Scope применяет сортировку, позицию курсора и лимит (с запасом в один
+// элемент, чтобы понять, есть ли следующая страница)
+func (q *listQuery) Scope(db *gorm.DB) *gorm.DB {
+	direction, op := "ASC", ">"
+	if q.Desc {
+		direction, op = "DESC", "<"
+	}
+
+	if q.hasCursor {
+		db = db.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", q.SortExpr, q.idColumn, op), q.cursor.Value, q.cursor.ID)
+	}
+
+	return db.
+		Order(fmt.Sprintf("%s %s, %s %s", q.SortExpr, direction, q.idColumn, direction)).
+		Limit(q.Limit + 1)
+}
+
+// paginate считает total, загружает страницу и возвращает курсор следующей.
+// key возвращает значение поля сортировки и ID элемента.
+func paginate[T any](base *gorm.DB, q *listQuery, dest *[]T, key func(*T) (interface{}, uint)) (int64, string, error) {
+	base = base.Session(&gorm.Session{})
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		return 0, "", err
+	}
+
+	if err := base.Scopes(q.Scope).Find(dest).Error; err != nil {
+		return 0, "", err
+	}
+
+	if len(*dest) <= q.Limit {
+		return total, "", nil
+	}
+
+	*dest = (*dest)[:q.Limit]
+	value, id := key(&(*dest)[q.Limit-1])
+	return total, encodeCursor(value, id), nil
+}
+
+// encodeCursor упаковывает позицию элемента в непрозрачную строку
+func encodeCursor(value interface{}, id uint) string {
+	var v string
+	switch t := value.(type) {
+	case time.Time:
+		v = t.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if t != nil {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+	default:
+		v = fmt.Sprint(t)
+	}
+
+	data, _ := json.Marshal(listCursor{Value: v, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseDateParam разбирает дату из параметра запроса (2006-01-02 или RFC3339)
+func parseDateParam(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", raw, time.Local)
+}
+
+// dateRangeFilter добавляет условия <param>_from и <param>_to по колонке.
+// Граница _to для даты без времени включает весь день.
+func dateRangeFilter(c *gin.Context, db *gorm.DB, param, column string) (*gorm.DB, error) {
+	if raw := c.Query(param + "_from"); raw != "" {
+		from, err := parseDateParam(raw)
+		if err != nil {
+			return nil, fmt.Errorf("неверная дата в %s_from", param)
+		}
+		db = db.Where(column+" >= ?", from)
+	}
+
+	if raw := c.Query(param + "_to"); raw != "" {
+		to, err := parseDateParam(raw)
+		if err != nil {
+			return nil, fmt.Errorf("неверная дата в %s_to", param)
+		}
+		if len(raw) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+			db = db.Where(column+" < ?", to)
+		} else {
+			db = db.Where(column+" <= ?", to)
+		}
+	}
+
+	return db, nil
+}
+
+// boolParam разбирает необязательный логический параметр
+func boolParam(c *gin.Context, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("параметр %s должен быть true или false", name)
+	}
+	return &value, nil
+}
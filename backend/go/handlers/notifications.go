@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"portfolio/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// notificationListSpec - сортировка списка уведомлений
+var notificationListSpec = listSpec{
+	SortFields:   map[string]string{"created_at": "created_at"},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+}
+
+// GetNotifications список уведомлений пользователя.
+// Поддерживает общие параметры списков и фильтры unread, kind.
+func GetNotifications(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	list, err := parseListQuery(c, notificationListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+
+	unread, err := boolParam(c, "unread")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if unread != nil {
+		if *unread {
+			query = query.Where("read_at IS NULL")
+		} else {
+			query = query.Where("read_at IS NOT NULL")
+		}
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var notifications []models.Notification
+	total, nextCursor, err := paginate(query, list, &notifications, func(n *models.Notification) (interface{}, uint) {
+		return n.CreatedAt, n.ID
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения уведомлений"})
+		return
+	}
+
+	var unreadCount int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unreadCount)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"count":         len(notifications),
+		"total":         total,
+		"unread":        unreadCount,
+		"next_cursor":   nextCursor,
+	})
+}
+
+// MarkNotificationRead отмечает уведомление прочитанным
+func MarkNotificationRead(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var notification models.Notification
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Уведомление не найдено"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := db.Model(&notification).UpdateColumn("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления уведомления"})
+			return
+		}
+		notification.ReadAt = &now
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notification": notification})
+}
+
+// MarkAllNotificationsRead отмечает все уведомления прочитанными
+func MarkAllNotificationsRead(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	result := db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		UpdateColumn("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления уведомлений"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// DeleteNotification удаляет уведомление
+func DeleteNotification(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	result := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.Notification{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления уведомления"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Уведомление не найдено"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Уведомление удалено"})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetFileThumbnail получение миниатюры изображения.
+// Параметр size округляется вверх до ближайшего допустимого размера.
+func GetFileThumbnail(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	size := storage.DefaultThumbnailSize
+	if raw := c.Query("size"); raw != "" {
+		requested, err := strconv.Atoi(raw)
+		if err != nil || requested <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный размер миниатюры"})
+			return
+		}
+		size = storage.ThumbnailSize(requested)
+	}
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	thumb, contentType, err := storage.Thumbnail(&file, size)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoThumbnail) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Миниатюры доступны только для JPEG и PNG"})
+			return
+		}
+		if errors.Is(err, storage.ErrImageTooLarge) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Изображение слишком большое для миниатюры"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания миниатюры"})
+		return
+	}
+
+	defer thumb.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "private, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", thumb.ModTime(), thumb)
+}
+
+// GetFilePreview получение текстового содержимого файла (.txt, .go) с ограничением размера
+func GetFilePreview(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	content, truncated, err := storage.TextPreview(&file, storage.MaxPreviewBytes)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoPreview) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Просмотр доступен только для .txt и .go файлов"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения файла"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preview": gin.H{
+			"id":        file.ID,
+			"filename":  file.OriginalFilename,
+			"content":   content,
+			"truncated": truncated,
+			"limit":     storage.MaxPreviewBytes,
+		},
+	})
+}
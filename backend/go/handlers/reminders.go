@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio/models"
+	"portfolio/notify"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetTaskReminders - напоминания задачи (минуты до срока)
+func GetTaskReminders(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	if err := db.Select("id").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&task).Error; err != nil {
+		respondTaskError(c, err, "Database error")
+		return
+	}
+
+	reminders, err := planner.Reminders(db, task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reminders": reminders,
+		"count":     len(reminders),
+	})
+}
+
+// SetTaskReminders - замена напоминаний задачи: reminders - минуты до срока
+func SetTaskReminders(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		Reminders []int `json:"reminders" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var reminders []models.TaskReminder
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		reminders, err = planner.SetReminders(tx, task.ID, input.Reminders)
+		return err
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to update reminders")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Reminders updated",
+		"reminders": reminders,
+		"count":     len(reminders),
+	})
+}
+
+// preferenceResponse - настройки уведомлений для ответа API: секрет
+// webhook не возвращается, только признак его наличия
+func preferenceResponse(pref *models.NotificationPreference, channels notify.Channels) gin.H {
+	_, emailAvailable := channels[notify.ChannelEmail]
+	return gin.H{
+		"in_app":             pref.InApp,
+		"email":              pref.Email,
+		"email_address":      pref.EmailAddress,
+		"email_available":    emailAvailable,
+		"webhook":            pref.Webhook,
+		"webhook_url":        pref.WebhookURL,
+		"webhook_secret_set": pref.WebhookSecret != "",
+		"default_reminders":  pref.Offsets(),
+		"all_day_time":       pref.AllDayTime,
+	}
+}
+
+// GetNotificationPreferences - настройки доставки уведомлений
+func GetNotificationPreferences(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	pref, err := planner.Preferences(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": preferenceResponse(&pref, notify.FromEnv(db))})
+}
+
+// UpdateNotificationPreferences - изменение настроек уведомлений.
+// Меняются только переданные поля; пустой webhook_secret снимает подпись.
+func UpdateNotificationPreferences(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		InApp            *bool   `json:"in_app"`
+		Email            *bool   `json:"email"`
+		EmailAddress     *string `json:"email_address" binding:"omitempty,email,max=255"`
+		Webhook          *bool   `json:"webhook"`
+		WebhookURL       *string `json:"webhook_url" binding:"omitempty,max=500"`
+		WebhookSecret    *string `json:"webhook_secret" binding:"omitempty,max=100"`
+		DefaultReminders *[]int  `json:"default_reminders"`
+		AllDayTime       *string `json:"all_day_time"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	pref, err := planner.Preferences(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения настроек"})
+		return
+	}
+
+	if input.InApp != nil {
+		pref.InApp = *input.InApp
+	}
+	if input.Email != nil {
+		pref.Email = *input.Email
+	}
+	if input.EmailAddress != nil {
+		pref.EmailAddress = strings.TrimSpace(*input.EmailAddress)
+	}
+	if input.Webhook != nil {
+		pref.Webhook = *input.Webhook
+	}
+	if input.WebhookURL != nil {
+		pref.WebhookURL = strings.TrimSpace(*input.WebhookURL)
+	}
+	if input.WebhookSecret != nil {
+		pref.WebhookSecret = *input.WebhookSecret
+	}
+	if input.DefaultReminders != nil {
+		minutes, err := planner.NormalizeReminders(*input.DefaultReminders)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parts := make([]string, len(minutes))
+		for i, m := range minutes {
+			parts[i] = strconv.Itoa(m)
+		}
+		pref.DefaultOffsets = strings.Join(parts, ",")
+	}
+	if input.AllDayTime != nil {
+		if _, err := time.Parse("15:04", *input.AllDayTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "all_day_time должно быть в формате ЧЧ:ММ"})
+			return
+		}
+		pref.AllDayTime = *input.AllDayTime
+	}
+
+	if pref.Webhook {
+		if err := notify.ValidWebhookURL(pref.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&pref).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения настроек"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Настройки уведомлений сохранены",
+		"preferences": preferenceResponse(&pref, notify.FromEnv(db)),
+	})
+}
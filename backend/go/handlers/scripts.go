@@ -132,20 +132,59 @@ func SaveScript(c *gin.Context) {
 	})
 }
 
-// GetScripts - получение списка скриптов пользователя
+// scriptListSpec - сортировка списка скриптов
+var scriptListSpec = listSpec{
+	SortFields: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+}
+
+// GetScripts - получение списка скриптов пользователя.
+// Поддерживает общие параметры списков и фильтры language, created_from/created_to.
 func GetScripts(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
 
+	list, err := parseListQuery(c, scriptListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.Script{}).Where("user_id = ?", userID)
+	if language := c.Query("language"); language != "" {
+		query = query.Where("language = ?", language)
+	}
+	if query, err = dateRangeFilter(c, query, "created", "created_at"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var scripts []models.Script
-	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&scripts).Error; err != nil {
+	total, nextCursor, err := paginate(query, list, &scripts, func(s *models.Script) (interface{}, uint) {
+		switch list.Sort {
+		case "updated_at":
+			return s.UpdatedAt, s.ID
+		case "name":
+			return s.Name, s.ID
+		default:
+			return s.CreatedAt, s.ID
+		}
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scripts"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"scripts": scripts,
-		"count":   len(scripts),
+		"scripts":     scripts,
+		"count":       len(scripts),
+		"total":       total,
+		"next_cursor": nextCursor,
 	})
 }
 
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SearchFiles полнотекстовый поиск по именам, папкам и содержимому файлов
+func SearchFiles(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Пустой поисковый запрос"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр limit должен быть от 1 до 100"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный параметр offset"})
+		return
+	}
+
+	results, total, err := storage.Search(db, userID, query, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска"})
+		return
+	}
+
+	response := make([]gin.H, len(results))
+	for i, result := range results {
+		response[i] = gin.H{
+			"id":                 result.ID,
+			"filename":           result.OriginalFilename,
+			"filename_highlight": result.FilenameHighlight,
+			"folder":             result.Folder,
+			"size":               result.FileSize,
+			"size_human":         formatBytes(result.FileSize),
+			"mime_type":          result.MimeType,
+			"uploaded_at":        result.UploadedAt.Format("2006-01-02 15:04:05"),
+			"rank":               result.Rank,
+			"snippet":            result.Snippet,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":    query,
+		"results":  response,
+		"count":    len(response),
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"has_more": int64(offset+len(response)) < total,
+	})
+}
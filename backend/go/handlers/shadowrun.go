@@ -11,13 +11,32 @@ import (
 	"gorm.io/gorm"
 )
 
-// GetShadowrunEntries - поиск по справочнику
+// shadowrunListSpec - сортировка справочника
+var shadowrunListSpec = listSpec{
+	SortFields: map[string]string{
+		"id":         "id",
+		"title":      "title",
+		"views":      "views",
+		"created_at": "created_at",
+	},
+	DefaultSort:  "id",
+	DefaultOrder: "asc",
+}
+
+// GetShadowrunEntries - поиск по справочнику.
+// Поддерживает общие параметры списков и фильтры q, category, created_from/created_to.
 func GetShadowrunEntries(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 
 	query := c.Query("q")
 	category := c.Query("category")
 
+	list, err := parseListQuery(c, shadowrunListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var entries []models.ShadowrunEntry
 	dbQuery := db.Model(&models.ShadowrunEntry{})
 
@@ -31,14 +50,33 @@ func GetShadowrunEntries(c *gin.Context) {
 		dbQuery = dbQuery.Where("category = ?", category)
 	}
 
-	if err := dbQuery.Order("id ASC").Find(&entries).Error; err != nil {
+	if dbQuery, err = dateRangeFilter(c, dbQuery, "created", "created_at"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total, nextCursor, err := paginate(dbQuery, list, &entries, func(e *models.ShadowrunEntry) (interface{}, uint) {
+		switch list.Sort {
+		case "title":
+			return e.Title, e.ID
+		case "views":
+			return e.Views, e.ID
+		case "created_at":
+			return e.CreatedAt, e.ID
+		default:
+			return e.ID, e.ID
+		}
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch entries"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"entries": entries,
-		"count":   len(entries),
+		"entries":     entries,
+		"count":       len(entries),
+		"total":       total,
+		"next_cursor": nextCursor,
 	})
 }
 
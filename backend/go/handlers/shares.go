@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// CreateFileShare создание публичной ссылки на файл
+func CreateFileShare(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		Password       string     `json:"password"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+		ExpiresInHours int        `json:"expires_in_hours" binding:"omitempty,min=1"`
+		MaxDownloads   int        `json:"max_downloads" binding:"omitempty,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	share := models.FileShare{
+		UserID:       userID,
+		FileID:       file.ID,
+		ExpiresAt:    request.ExpiresAt,
+		MaxDownloads: request.MaxDownloads,
+	}
+
+	if request.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(request.ExpiresInHours) * time.Hour)
+		share.ExpiresAt = &expiresAt
+	}
+	if share.ExpiresAt != nil && share.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Срок действия ссылки уже истёк"})
+		return
+	}
+
+	if request.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка хеширования пароля"})
+			return
+		}
+		share.PasswordHash = string(hash)
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания ссылки"})
+		return
+	}
+	share.Token = token
+
+	if err := db.Create(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания ссылки"})
+		return
+	}
+
+	share.File = file
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Ссылка создана",
+		"share":   shareResponse(&share),
+	})
+}
+
+// GetFileShares получение активных ссылок пользователя
+func GetFileShares(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var shares []models.FileShare
+	if err := db.Preload("File").
+		Joins("JOIN files ON files.id = file_shares.file_id AND files.deleted_at IS NULL").
+		Where("file_shares.user_id = ?", userID).
+		Where("(file_shares.expires_at IS NULL OR file_shares.expires_at > ?)", time.Now()).
+		Where("(file_shares.max_downloads = 0 OR file_shares.downloads < file_shares.max_downloads)").
+		Order("file_shares.created_at DESC").
+		Find(&shares).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения ссылок"})
+		return
+	}
+
+	response := make([]gin.H, len(shares))
+	for i := range shares {
+		response[i] = shareResponse(&shares[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shares": response,
+		"count":  len(shares),
+	})
+}
+
+// RevokeFileShare отзыв ссылки
+func RevokeFileShare(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	result := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.FileShare{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отзыва ссылки"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ссылка не найдена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ссылка отозвана"})
+}
+
+// GetPublicShare информация о файле по публичной ссылке (без аутентификации)
+func GetPublicShare(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	share, ok := findActiveShare(c, db)
+	if !ok {
+		return
+	}
+	if share.Exhausted() {
+		c.JSON(http.StatusGone, gin.H{"error": "Лимит скачиваний исчерпан"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"share": gin.H{
+			"filename":           share.File.OriginalFilename,
+			"size":               share.File.FileSize,
+			"size_human":         share.File.GetSizeHuman(),
+			"mime_type":          share.File.MimeType,
+			"expires_at":         share.ExpiresAt,
+			"password_protected": share.HasPassword(),
+		},
+	})
+}
+
+// DownloadPublicShare скачивание файла по публичной ссылке (без аутентификации).
+// Пароль передаётся в заголовке X-Share-Password. Лимит скачиваний
+// расходуют только запросы, отдающие файл с начала (см. countsAsDownload).
+func DownloadPublicShare(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	share, ok := findActiveShare(c, db)
+	if !ok {
+		return
+	}
+	// Исчерпанная ссылка не отдаёт ничего, в том числе части файла
+	if share.Exhausted() {
+		c.JSON(http.StatusGone, gin.H{"error": "Лимит скачиваний исчерпан"})
+		return
+	}
+
+	if share.HasPassword() {
+		password := c.GetHeader("X-Share-Password")
+		if bcrypt.CompareHashAndPassword([]byte(share.PasswordHash), []byte(password)) != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный пароль"})
+			return
+		}
+	}
+
+	f, ok := openServedFile(c, db, &share.File)
+	if !ok {
+		return
+	}
+	defer f.Close()
+
+	if countsAsDownload(c.Request, share, f.ModTime()) {
+		// Атомарно учитываем скачивание, не превышая лимит
+		result := db.Model(&models.FileShare{}).
+			Where("id = ? AND (max_downloads = 0 OR downloads < max_downloads)", share.ID).
+			UpdateColumn("downloads", gorm.Expr("downloads + 1"))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка скачивания"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusGone, gin.H{"error": "Лимит скачиваний исчерпан"})
+			return
+		}
+	}
+
+	sendFile(c, &share.File, f)
+}
+
+// countsAsDownload сообщает, расходует ли запрос лимит скачиваний ссылки.
+// Не расходуют его только ответы 304 на условные запросы и докачка уже
+// начатого скачивания (см. resumedRange). Условия повторяют проверки
+// http.ServeContent.
+func countsAsDownload(r *http.Request, share *models.FileShare, modTime time.Time) bool {
+	etag := ""
+	if share.File.ContentHash != "" {
+		etag = `"` + share.File.ContentHash + `"`
+	}
+	modTime = modTime.Truncate(time.Second)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagListMatches(inm, etag) {
+			return false
+		}
+	} else if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modTime.After(ims) {
+		return false
+	}
+
+	if share.Downloads == 0 || !resumedRange(r.Header.Get("Range")) {
+		return true
+	}
+	// При несовпадении If-Range вместо части отдаётся весь файл
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" {
+		if t, err := http.ParseTime(ifRange); err == nil {
+			return !modTime.Equal(t)
+		}
+		return etag == "" || ifRange != etag
+	}
+	return false
+}
+
+// resumedRange сообщает, похож ли Range на докачку: один диапазон,
+// начинающийся не с начала файла. Диапазон с конца (bytes=-N) и несколько
+// диапазонов докачкой не считаются.
+func resumedRange(header string) bool {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return false
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	return err == nil && offset > 0
+}
+
+// etagListMatches сообщает, есть ли etag в списке If-None-Match (слабое
+// сравнение, как в http.ServeContent)
+func etagListMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if etag != "" && strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// findActiveShare загружает ссылку с неистёкшим сроком по :token вместе с
+// файлом. Лимит скачиваний проверяет вызывающий.
+func findActiveShare(c *gin.Context, db *gorm.DB) (*models.FileShare, bool) {
+	var share models.FileShare
+	if err := db.Preload("File").Where("token = ?", c.Param("token")).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ссылка не найдена"})
+		return nil, false
+	}
+
+	// Файл мог быть удалён в корзину
+	if share.File.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return nil, false
+	}
+
+	if share.Expired(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Срок действия ссылки истёк"})
+		return nil, false
+	}
+
+	return &share, true
+}
+
+// shareResponse формирует описание ссылки для владельца
+func shareResponse(share *models.FileShare) gin.H {
+	return gin.H{
+		"id":                 share.ID,
+		"file_id":            share.FileID,
+		"filename":           share.File.OriginalFilename,
+		"token":              share.Token,
+		"url":                "/api/share/" + share.Token + "/download",
+		"expires_at":         share.ExpiresAt,
+		"max_downloads":      share.MaxDownloads,
+		"downloads":          share.Downloads,
+		"password_protected": share.HasPassword(),
+		"created_at":         share.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// generateShareToken создаёт случайный токен ссылки
+func generateShareToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
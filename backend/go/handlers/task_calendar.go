@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCalendarFeed - сведения о ленте задач пользователя (feed: null, если
+// лента не создана)
+func GetCalendarFeed(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var feed models.CalendarFeed
+	err := db.Where("user_id = ?", userID).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusOK, gin.H{"feed": nil})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"feed": feed})
+}
+
+// CreateCalendarFeed - создание ленты задач. Прежняя ссылка перестаёт
+// работать; токен возвращается только в этом ответе.
+func CreateCalendarFeed(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	secret, err := generateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create feed"})
+		return
+	}
+	raw := models.CalendarFeedPrefix + secret
+
+	feed := models.CalendarFeed{
+		UserID:    userID,
+		TokenHash: models.HashPersonalToken(raw),
+		Hint:      raw[:len(models.CalendarFeedPrefix)+4],
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&feed).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create feed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Calendar feed created. Save the link: it will not be shown again",
+		"token":   raw,
+		"url":     "/api/calendar/" + raw + ".ics",
+		"feed":    feed,
+	})
+}
+
+// DeleteCalendarFeed - отключение ленты задач
+func DeleteCalendarFeed(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	result := db.Where("user_id = ?", userID).Delete(&models.CalendarFeed{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete feed"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar feed deleted"})
+}
+
+// CalendarFeedICS - публичная iCalendar-лента задач со сроком (без
+// аутентификации, по токену в пути). Параметры: type (todo - VTODO по
+// умолчанию, event - VEVENT) и completed=false, чтобы скрыть выполненные.
+// В ленту попадают и задачи папок, открытых владельцу ленты.
+func CalendarFeedICS(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	raw := strings.TrimSuffix(c.Param("token"), ".ics")
+	var feed models.CalendarFeed
+	if err := db.Where("token_hash = ?", models.HashPersonalToken(raw)).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar feed not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	kind := c.DefaultQuery("type", planner.CalendarTodo)
+	if kind != planner.CalendarTodo && kind != planner.CalendarEvent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be todo or event"})
+		return
+	}
+	completed, err := boolParam(c, "completed")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, ok := userLocation(c, db, feed.UserID)
+	if !ok {
+		return
+	}
+
+	query := db.Preload("Tags").Scopes(planner.VisibleTo(feed.UserID)).Where("deadline IS NOT NULL")
+	if completed != nil && !*completed {
+		query = query.Where("completed = ?", false)
+	}
+	var tasks []models.Task
+	if err := query.Order("deadline ASC, id ASC").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var buf bytes.Buffer
+	err = planner.WriteCalendar(&buf, tasks, planner.CalendarOptions{
+		Name:     "Задачи",
+		Kind:     kind,
+		Domain:   c.Request.Host,
+		Location: loc,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build calendar"})
+		return
+	}
+
+	db.Model(&feed).UpdateColumn("last_used_at", time.Now())
+	c.Header("Content-Disposition", `inline; filename="tasks.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// ImportCalendar - импорт задач из файла .ics (VTODO и VEVENT); параметры
+// те же, что у ImportTasks с format=ics
+func ImportCalendar(c *gin.Context) {
+	importTasks(c, planner.FormatICal)
+}
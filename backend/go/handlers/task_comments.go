@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	// errCommentNotFound - комментарий не найден в задаче
+	errCommentNotFound = errors.New("comment not found")
+	// errCommentAuthor - чужой комментарий нельзя изменить или удалить
+	errCommentAuthor = errors.New("only the author can change a comment")
+)
+
+// Размер страницы журнала задачи
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 200
+)
+
+// taskComment загружает комментарий :comment задачи
+func taskComment(db *gorm.DB, c *gin.Context, taskID uint) (*models.TaskComment, error) {
+	commentID, err := strconv.Atoi(c.Param("comment"))
+	if err != nil {
+		return nil, errCommentNotFound
+	}
+	var comment models.TaskComment
+	err = db.Where("id = ? AND task_id = ?", commentID, taskID).First(&comment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errCommentNotFound
+	}
+	return &comment, err
+}
+
+// respondCommentError переводит ошибку работы с комментарием в HTTP-ответ
+func respondCommentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, errCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+	case errors.Is(err, errCommentAuthor):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, planner.ErrCommentBody):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondTaskError(c, err, message)
+	}
+}
+
+// userTaskID проверяет, что задача :id видна пользователю (своя или из
+// общей папки), и возвращает её ID; при ошибке отвечает сам
+func userTaskID(c *gin.Context, db *gorm.DB, userID uint) (uint, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, false
+	}
+	var task models.Task
+	if err := db.Select("id").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&task).Error; err != nil {
+		respondTaskError(c, err, "Database error")
+		return 0, false
+	}
+	return task.ID, true
+}
+
+// GetTaskActivity - журнал изменений задачи от новых записей к старым.
+// Журнал удалённой задачи тоже доступен. Параметры: limit (до 200) и
+// before - ID записи, с которой продолжить.
+func GetTaskActivity(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	limit := defaultActivityLimit
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxActivityLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be 1-200"})
+			return
+		}
+	}
+	var before uint64
+	if raw := c.Query("before"); raw != "" {
+		if before, err = strconv.ParseUint(raw, 10, 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before ID"})
+			return
+		}
+	}
+
+	var task models.Task
+	if err := db.Unscoped().Select("id").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&task).Error; err != nil {
+		respondTaskError(c, err, "Database error")
+		return
+	}
+
+	entries, err := planner.Activity(db, task.ID, uint(before), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var next *uint
+	if len(entries) == limit {
+		next = &entries[len(entries)-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"activity":    entries,
+		"count":       len(entries),
+		"next_before": next,
+	})
+}
+
+// GetTaskComments - комментарии задачи от старых к новым
+func GetTaskComments(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	taskID, ok := userTaskID(c, db, userID)
+	if !ok {
+		return
+	}
+
+	comments, err := planner.Comments(db, taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments": comments,
+		"count":    len(comments),
+	})
+}
+
+// CreateTaskComment - новый комментарий (body - текст в Markdown)
+func CreateTaskComment(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	body, err := planner.NormalizeComment(input.Body)
+	if err != nil {
+		respondCommentError(c, err, "")
+		return
+	}
+
+	taskID, ok := userTaskID(c, db, userID)
+	if !ok {
+		return
+	}
+
+	comment := models.TaskComment{TaskID: taskID, UserID: userID, Body: body}
+	if err := db.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Comment added",
+		"comment": comment,
+	})
+}
+
+// UpdateTaskComment - изменение текста комментария его автором
+func UpdateTaskComment(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	body, err := planner.NormalizeComment(input.Body)
+	if err != nil {
+		respondCommentError(c, err, "")
+		return
+	}
+
+	taskID, ok := userTaskID(c, db, userID)
+	if !ok {
+		return
+	}
+
+	comment, err := taskComment(db, c, taskID)
+	if err == nil && comment.UserID != userID {
+		err = errCommentAuthor
+	}
+	if err != nil {
+		respondCommentError(c, err, "Database error")
+		return
+	}
+
+	if body != comment.Body {
+		comment.Body = body
+		comment.Edited = true
+		if err := db.Model(comment).Updates(map[string]interface{}{"body": body, "edited": true}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Comment updated",
+		"comment": comment,
+	})
+}
+
+// DeleteTaskComment - удаление комментария его автором
+func DeleteTaskComment(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	taskID, ok := userTaskID(c, db, userID)
+	if !ok {
+		return
+	}
+
+	comment, err := taskComment(db, c, taskID)
+	if err == nil && comment.UserID != userID {
+		err = errCommentAuthor
+	}
+	if err != nil {
+		respondCommentError(c, err, "Database error")
+		return
+	}
+
+	if err := db.Delete(comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+}
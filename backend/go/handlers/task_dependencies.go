@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTaskDependencies - задачи, блокирующие задачу (blockers), и задачи,
+// которые она блокирует (blocking). Показываются только задачи, видимые
+// пользователю.
+func GetTaskDependencies(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	if err := db.Select("id").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&task).Error; err != nil {
+		respondTaskError(c, err, "Database error")
+		return
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var blockers, blocking []models.Task
+	if err := db.Scopes(planner.VisibleTo(userID)).
+		Where("id IN (?)", db.Model(&models.TaskDependency{}).Select("blocker_id").Where("task_id = ?", task.ID)).
+		Order("id ASC").Find(&blockers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := db.Scopes(planner.VisibleTo(userID)).
+		Where("id IN (?)", db.Model(&models.TaskDependency{}).Select("task_id").Where("blocker_id = ?", task.ID)).
+		Order("id ASC").Find(&blocking).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	open := 0
+	for i := range blockers {
+		planner.Localize(&blockers[i], loc)
+		if !blockers[i].Completed {
+			open++
+		}
+	}
+	for i := range blocking {
+		planner.Localize(&blocking[i], loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blockers": blockers,
+		"blocking": blocking,
+		"blocked":  open > 0,
+	})
+}
+
+// AddTaskDependency - задача :id блокируется задачей blocker_id. Задача
+// из общей папки требует права edit, блокирующая - того же владельца.
+func AddTaskDependency(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		BlockerID uint `json:"blocker_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var dependency *models.TaskDependency
+	err = db.Transaction(func(tx *gorm.DB) error {
+		dependency, err = planner.AddDependency(tx, userID, uint(id), input.BlockerID)
+		return err
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to add dependency")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Dependency added",
+		"dependency": dependency,
+	})
+}
+
+// RemoveTaskDependency - снятие блокировки задачи :id задачей :blocker
+func RemoveTaskDependency(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+	blockerID, err := strconv.Atoi(c.Param("blocker"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocker ID"})
+		return
+	}
+
+	var removed int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		result := tx.Where("task_id = ? AND blocker_id = ?", task.ID, blockerID).Delete(&models.TaskDependency{})
+		removed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to remove dependency")
+		return
+	}
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dependency not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed"})
+}
+
+// GetDependencyGraph - граф зависимостей задач и топологический порядок
+// выполнения. Параметр task ограничивает граф связной компонентой задачи.
+func GetDependencyGraph(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var taskID uint
+	if raw := c.Query("task"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+		taskID = uint(id)
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	graph, err := planner.Graph(db, userID, taskID, loc)
+	if err != nil {
+		respondTaskError(c, err, "Failed to build dependency graph")
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
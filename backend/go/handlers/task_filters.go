@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// errFilterNotFound - сохранённого фильтра нет у пользователя
+	errFilterNotFound = errors.New("saved filter not found")
+	// errFilterExists - фильтр с таким именем уже есть
+	errFilterExists = errors.New("saved filter already exists")
+)
+
+// respondFilterError переводит ошибку тегов и фильтров в HTTP-ответ
+func respondFilterError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, planner.ErrTagNotFound), errors.Is(err, errFilterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, planner.ErrTagExists), errors.Is(err, errFilterExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, planner.ErrTagName), errors.Is(err, planner.ErrInvalidQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondTaskError(c, err, message)
+	}
+}
+
+// taskFilters собирает фильтры запроса списка задач: сохранённый фильтр
+// (filter=ID), запрос на языке фильтров (q) и теги (tag=a,b)
+func taskFilters(c *gin.Context, db *gorm.DB, userID uint) ([]*planner.Filter, error) {
+	var filters []*planner.Filter
+
+	if raw := c.Query("filter"); raw != "" {
+		saved, err := savedFilter(db, userID, raw)
+		if err != nil {
+			return nil, err
+		}
+		f, err := planner.ParseQuery(saved.Query)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if q := c.Query("q"); q != "" {
+		f, err := planner.ParseQuery(q)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if tags := c.Query("tag"); tags != "" {
+		f := &planner.Filter{Tags: strings.Split(tags, ",")}
+		if err := f.Normalize(); err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// savedFilter загружает сохранённый фильтр пользователя по ID из строки
+func savedFilter(db *gorm.DB, userID uint, rawID string) (*models.SavedFilter, error) {
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return nil, errFilterNotFound
+	}
+	var filter models.SavedFilter
+	err = db.Where("id = ? AND user_id = ?", id, userID).First(&filter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errFilterNotFound
+	}
+	return &filter, err
+}
+
+// filterQuery приводит условие сохранённого фильтра к каноническому виду:
+// из строки query или из структурированного filter
+func filterQuery(query *string, structured *planner.Filter) (string, error) {
+	if structured != nil {
+		if err := structured.Normalize(); err != nil {
+			return "", err
+		}
+		return structured.String(), nil
+	}
+	f, err := planner.ParseQuery(*query)
+	if err != nil {
+		return "", err
+	}
+	return f.String(), nil
+}
+
+// userTag загружает тег :id пользователя
+func userTag(db *gorm.DB, c *gin.Context, userID uint) (*models.Tag, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, planner.ErrTagNotFound
+	}
+	var tag models.Tag
+	err = db.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, planner.ErrTagNotFound
+	}
+	return &tag, err
+}
+
+// GetTags - теги пользователя с числом задач
+func GetTags(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var tags []struct {
+		models.Tag
+		TaskCount int64 `json:"task_count"`
+	}
+	if err := db.Model(&models.Tag{}).
+		Select("tags.*, (SELECT COUNT(*) FROM task_tags JOIN tasks ON tasks.id = task_tags.task_id "+
+			"WHERE task_tags.tag_id = tags.id AND tasks.deleted_at IS NULL) AS task_count").
+		Where("user_id = ?", userID).
+		Order("name ASC").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
+// CreateTag - новый тег
+func CreateTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name  string `json:"name" binding:"required"`
+		Color string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if !planner.ValidFolderColor(input.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": planner.ErrFolderColor.Error()})
+		return
+	}
+
+	name, err := planner.NormalizeTag(input.Name)
+	if err != nil {
+		respondFilterError(c, err, "Failed to create tag")
+		return
+	}
+	tag := models.Tag{UserID: userID, Name: name, Color: input.Color}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondFilterError(c, planner.ErrTagExists, "")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Tag created",
+		"tag":     tag,
+	})
+}
+
+// UpdateTag - переименование тега и смена цвета
+func UpdateTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if input.Color != nil && !planner.ValidFolderColor(*input.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": planner.ErrFolderColor.Error()})
+		return
+	}
+
+	var tag *models.Tag
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if tag, err = userTag(tx, c, userID); err != nil {
+			return err
+		}
+		if input.Name != nil {
+			if err := planner.RenameTag(tx, tag, *input.Name); err != nil {
+				return err
+			}
+		}
+		if input.Color != nil {
+			tag.Color = *input.Color
+			return tx.Model(tag).UpdateColumn("color", tag.Color).Error
+		}
+		return nil
+	})
+	if err != nil {
+		respondFilterError(c, err, "Failed to update tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tag updated",
+		"tag":     tag,
+	})
+}
+
+// DeleteTag - удаление тега; задачи остаются без него
+func DeleteTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tag, err := userTag(tx, c, userID)
+		if err != nil {
+			return err
+		}
+		return planner.DeleteTag(tx, tag)
+	})
+	if err != nil {
+		respondFilterError(c, err, "Failed to delete tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
+
+// SetTaskTags - замена тегов задачи; недостающие теги создаются
+func SetTaskTags(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input struct {
+		Tags []string `json:"tags" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var task models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		return planner.SetTags(tx, &task, input.Tags)
+	})
+	if err != nil {
+		respondFilterError(c, err, "Failed to update task tags")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task tags updated",
+		"tags":    task.Tags,
+	})
+}
+
+// GetSavedFilters - сохранённые фильтры пользователя по порядку
+func GetSavedFilters(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var filters []models.SavedFilter
+	if err := db.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&filters).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"filters": filters,
+		"count":   len(filters),
+	})
+}
+
+// CreateSavedFilter - сохранение фильтра. Условие задаётся строкой query
+// на языке фильтров или объектом filter и хранится в каноническом виде.
+func CreateSavedFilter(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name   string          `json:"name" binding:"required,max=100"`
+		Query  *string         `json:"query" binding:"required_without=Filter"`
+		Filter *planner.Filter `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	query, err := filterQuery(input.Query, input.Filter)
+	if err != nil {
+		respondFilterError(c, err, "Failed to save filter")
+		return
+	}
+
+	var count int64
+	db.Model(&models.SavedFilter{}).Where("user_id = ?", userID).Count(&count)
+	filter := models.SavedFilter{
+		UserID:   userID,
+		Name:     strings.TrimSpace(input.Name),
+		Query:    query,
+		Position: int(count),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&filter)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save filter"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondFilterError(c, errFilterExists, "")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Filter saved",
+		"filter":  filter,
+	})
+}
+
+// UpdateSavedFilter - изменение имени, условия или позиции фильтра
+func UpdateSavedFilter(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name     *string         `json:"name" binding:"omitempty,min=1,max=100"`
+		Query    *string         `json:"query"`
+		Filter   *planner.Filter `json:"filter"`
+		Position *int            `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	filter, err := savedFilter(db, userID, c.Param("id"))
+	if err != nil {
+		respondFilterError(c, err, "Database error")
+		return
+	}
+
+	if input.Name != nil {
+		filter.Name = strings.TrimSpace(*input.Name)
+		var count int64
+		db.Model(&models.SavedFilter{}).
+			Where("user_id = ? AND name = ? AND id <> ?", userID, filter.Name, filter.ID).
+			Count(&count)
+		if count > 0 {
+			respondFilterError(c, errFilterExists, "")
+			return
+		}
+	}
+	if input.Query != nil || input.Filter != nil {
+		if filter.Query, err = filterQuery(input.Query, input.Filter); err != nil {
+			respondFilterError(c, err, "Failed to update filter")
+			return
+		}
+	}
+	if input.Position != nil {
+		filter.Position = *input.Position
+	}
+
+	if err := db.Save(filter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update filter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Filter updated",
+		"filter":  filter,
+	})
+}
+
+// DeleteSavedFilter - удаление сохранённого фильтра
+func DeleteSavedFilter(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	result := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.SavedFilter{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete filter"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondFilterError(c, errFilterNotFound, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Filter deleted"})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetFolderMembers - участники папки задач (только для владельца)
+func GetFolderMembers(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	folder, err := folderParam(db, c, userID)
+	if err != nil {
+		respondFolderError(c, err, "Ошибка получения участников")
+		return
+	}
+
+	members, err := planner.Members(db, folder.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения участников"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// ShareFolder - открытие папки пользователю username с правами role
+// (view - просмотр, edit - изменение задач) или смена его прав
+func ShareFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Role     string `json:"role"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.FolderRoleView
+	}
+
+	var member *models.TaskFolderMember
+	err := db.Transaction(func(tx *gorm.DB) error {
+		folder, err := folderParam(tx, c, userID)
+		if err != nil {
+			return err
+		}
+		member, err = planner.ShareFolder(tx, folder, input.Username, input.Role)
+		return err
+	})
+	if err != nil {
+		respondFolderError(c, err, "Ошибка открытия доступа")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Доступ к папке открыт",
+		"member":  member,
+	})
+}
+
+// RemoveFolderMember - закрытие доступа к папке. Владелец может удалить
+// любого участника, участник - только себя (выйти из общей папки).
+func RemoveFolderMember(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	folderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondFolderError(c, planner.ErrFolderNotFound, "")
+		return
+	}
+	memberID, err := strconv.Atoi(c.Param("user"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID пользователя"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var folder models.TaskFolder
+		err := tx.Where("id = ?", folderID).First(&folder).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return planner.ErrFolderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if folder.UserID != userID && uint(memberID) != userID {
+			return planner.ErrFolderNotFound
+		}
+		return planner.RemoveMember(tx, folder.ID, uint(memberID))
+	})
+	if err != nil {
+		respondFolderError(c, err, "Ошибка закрытия доступа")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Доступ к папке закрыт"})
+}
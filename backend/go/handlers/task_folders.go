@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// taskFolderItem - папка задач с числом задач в ней
+type taskFolderItem struct {
+	models.TaskFolder
+	TaskCount int64 `json:"task_count"`
+}
+
+// respondFolderError переводит ошибку изменения папки задач в HTTP-ответ
+func respondFolderError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, planner.ErrFolderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Папка не найдена"})
+	case errors.Is(err, planner.ErrFolderExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Папка уже существует"})
+	case errors.Is(err, planner.ErrFolderName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Недопустимое имя папки"})
+	case errors.Is(err, planner.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+	case errors.Is(err, planner.ErrMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Участник не найден"})
+	case errors.Is(err, planner.ErrShareOwner):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Владелец папки уже имеет к ней доступ"})
+	case errors.Is(err, planner.ErrFolderRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, planner.ErrFolderColor),
+		errors.Is(err, planner.ErrFolderOrder),
+		errors.Is(err, planner.ErrFolderTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// folderParam загружает папку задач :id пользователя
+func folderParam(tx *gorm.DB, c *gin.Context, userID uint) (*models.TaskFolder, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, planner.ErrFolderNotFound
+	}
+	return planner.FolderByID(tx, userID, uint(id))
+}
+
+// GetFolders - получение списка папок пользователя.
+// folders - имена для фильтра списка задач, items - папки целиком,
+// shared - папки других пользователей, открытые ему.
+func GetFolders(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var folders []models.TaskFolder
+	if err := db.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
+		return
+	}
+
+	var counts []struct {
+		FolderID uint
+		Count    int64
+	}
+	if err := db.Model(&models.Task{}).
+		Select("folder_id, COUNT(*) AS count").
+		Where("user_id = ? AND folder_id IS NOT NULL", userID).
+		Group("folder_id").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
+		return
+	}
+	byFolder := make(map[uint]int64, len(counts))
+	for _, row := range counts {
+		byFolder[row.FolderID] = row.Count
+	}
+
+	shared, err := planner.SharedFolders(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
+		return
+	}
+
+	names := make([]string, 0, len(folders))
+	items := make([]taskFolderItem, 0, len(folders))
+	for _, folder := range folders {
+		names = append(names, folder.Name)
+		items = append(items, taskFolderItem{TaskFolder: folder, TaskCount: byFolder[folder.ID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"folders": names,
+		"items":   items,
+		"shared":  shared,
+		"count":   len(folders),
+	})
+}
+
+// CreateFolder - создание новой папки
+func CreateFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name  string `json:"name" binding:"required"`
+		Color string `json:"color"`
+		Icon  string `json:"icon" binding:"max=50"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
+		return
+	}
+
+	folder := models.TaskFolder{UserID: userID, Name: input.Name, Color: input.Color, Icon: input.Icon}
+	if err := planner.CreateFolder(db, &folder); err != nil {
+		respondFolderError(c, err, "Ошибка создания папки")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("Папка '%s' создана", folder.Name),
+		"folder":  folder,
+	})
+}
+
+// UpdateFolder - переименование папки, смена цвета и иконки.
+// Новое имя сразу видно у всех задач папки.
+func UpdateFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+		Icon  *string `json:"icon" binding:"omitempty,max=50"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
+		return
+	}
+	if input.Color != nil && !planner.ValidFolderColor(*input.Color) {
+		respondFolderError(c, planner.ErrFolderColor, "")
+		return
+	}
+
+	var folder *models.TaskFolder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if folder, err = folderParam(tx, c, userID); err != nil {
+			return err
+		}
+		if input.Name != nil {
+			if err := planner.RenameFolder(tx, folder, *input.Name); err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{}
+		if input.Color != nil {
+			folder.Color = *input.Color
+			updates["color"] = folder.Color
+		}
+		if input.Icon != nil {
+			folder.Icon = *input.Icon
+			updates["icon"] = folder.Icon
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(folder).Updates(updates).Error
+	})
+	if err != nil {
+		respondFolderError(c, err, "Ошибка обновления папки")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Папка обновлена",
+		"folder":  folder,
+	})
+}
+
+// ReorderFolders - новый порядок папок: folder_ids перечисляет все папки
+func ReorderFolders(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var input struct {
+		FolderIDs []uint `json:"folder_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return planner.ReorderFolders(tx, userID, input.FolderIDs)
+	})
+	if err != nil {
+		respondFolderError(c, err, "Ошибка сортировки папок")
+		return
+	}
+
+	GetFolders(c)
+}
+
+// DeleteFolder - удаление папки. mode=move (по умолчанию) переносит задачи
+// в папку target (ID; по умолчанию general), mode=cascade удаляет их
+// вместе с подзадачами.
+func DeleteFolder(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	mode := c.DefaultQuery("mode", "move")
+	if mode != "move" && mode != "cascade" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be move or cascade"})
+		return
+	}
+	var targetID *uint
+	if raw := c.Query("target"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target folder ID"})
+			return
+		}
+		value := uint(id)
+		targetID = &value
+	}
+
+	var folder *models.TaskFolder
+	var moved, deleted int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if folder, err = folderParam(tx, c, userID); err != nil {
+			return err
+		}
+		var target *models.TaskFolder
+		if targetID != nil {
+			if target, err = planner.FolderByID(tx, userID, *targetID); err != nil {
+				return err
+			}
+		}
+		moved, deleted, err = planner.DeleteFolder(tx, folder, mode == "cascade", target)
+		return err
+	})
+	if err != nil {
+		respondFolderError(c, err, "Ошибка удаления папки")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       fmt.Sprintf("Папка '%s' удалена", folder.Name),
+		"moved_tasks":   moved,
+		"deleted_tasks": deleted,
+	})
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio/models"
+	"portfolio/planner"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportSize - наибольший размер импортируемого файла задач
+const maxImportSize = 5 << 20
+
+// errDryRun откатывает транзакцию пробного импорта
+var errDryRun = errors.New("dry run")
+
+// ExportTasks - выгрузка задач и папок пользователя: format=json (по
+// умолчанию, с папками) или csv (только задачи). Выгружаются только
+// собственные задачи: задачи общих папок входят в выгрузку их владельца,
+// а импорт выгрузки создал бы их копии.
+func ExportTasks(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	format := c.DefaultQuery("format", planner.FormatJSON)
+	if format != planner.FormatJSON && format != planner.FormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	var folders []models.TaskFolder
+	if err := db.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	var tasks []models.Task
+	if err := db.Preload("Tags").Where("user_id = ?", userID).Order("id ASC").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	now := time.Now()
+	export := planner.NewExport(folders, tasks, loc, now)
+
+	var buf bytes.Buffer
+	contentType := "application/json; charset=utf-8"
+	var err error
+	if format == planner.FormatCSV {
+		contentType = "text/csv; charset=utf-8"
+		err = export.WriteCSV(&buf)
+	} else {
+		err = export.WriteJSON(&buf)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+
+	filename := "tasks-" + now.In(loc).Format(planner.DateLayout) + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// ImportTasks - импорт задач из файла. Параметры (запроса или полей
+// формы): format - json, csv, ics, todoist (CSV Todoist) или trello
+// (JSON доски Trello); folder_id или folder - папка для всех задач вместо
+// папок из файла; dry_run=true - только отчёт о том, что было бы создано
+// и пропущено; skip_duplicates=false - не пропускать задачи, которые уже
+// есть (то же название, папка и срок).
+func ImportTasks(c *gin.Context) {
+	importTasks(c, importParam(c, "format"))
+}
+
+// importBody возвращает импортируемый файл: поле file формы multipart или
+// тело запроса целиком. Размер ограничен maxImportSize.
+func importBody(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var r io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	return io.ReadAll(r)
+}
+
+// importParam возвращает параметр импорта из полей формы или запроса
+func importParam(c *gin.Context, name string) string {
+	return c.DefaultPostForm(name, c.Query(name))
+}
+
+// importFlag возвращает булев параметр импорта (value, если он не задан)
+func importFlag(c *gin.Context, name string, value bool) (bool, error) {
+	raw := importParam(c, name)
+	if raw == "" {
+		return value, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New(name + " must be true or false")
+	}
+	return value, nil
+}
+
+// parseImport разбирает файл импорта в формате format
+func parseImport(format string, data []byte, loc *time.Location) ([]planner.ImportedFolder, []planner.ImportedTask, error) {
+	r := bytes.NewReader(data)
+	var items []planner.ImportedTask
+	var err error
+	switch format {
+	case planner.FormatJSON:
+		return planner.ParseJSON(r, loc)
+	case planner.FormatCSV:
+		items, err = planner.ParseCSV(r, loc)
+	case planner.FormatICal:
+		items, err = planner.ParseCalendar(r, loc)
+	case planner.FormatTodoist:
+		items, err = planner.ParseTodoist(r, loc)
+	case planner.FormatTrello:
+		items, err = planner.ParseTrello(r, loc)
+	default:
+		err = errUnknownFormat
+	}
+	return nil, items, err
+}
+
+// errUnknownFormat - неизвестный формат импорта
+var errUnknownFormat = errors.New("format must be json, csv, ics, todoist or trello")
+
+// importTasks импортирует задачи из файла запроса в формате format
+func importTasks(c *gin.Context, format string) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	data, err := importBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file: " + err.Error()})
+		return
+	}
+
+	var folderID *uint
+	if raw := importParam(c, "folder_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+		value := uint(id)
+		folderID = &value
+	}
+	folderName := importParam(c, "folder")
+
+	dryRun, err := importFlag(c, "dry_run", false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	skipDuplicates, err := importFlag(c, "skip_duplicates", true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	folders, items, err := parseImport(format, data, loc)
+	if errors.Is(err, errUnknownFormat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondTaskError(c, err, "Failed to parse file")
+		return
+	}
+
+	var result *planner.ImportResult
+	err = db.Transaction(func(tx *gorm.DB) error {
+		opts := planner.ImportOptions{SkipDuplicates: skipDuplicates}
+		if folderID != nil || folderName != "" {
+			folder, err := planner.ResolveFolder(tx, userID, folderID, folderName)
+			if err != nil {
+				return err
+			}
+			opts.Folder = folder
+		}
+
+		var created []string
+		if opts.Folder == nil && len(folders) > 0 {
+			if created, err = planner.ImportFolders(tx, userID, folders); err != nil {
+				return err
+			}
+		}
+		if result, err = planner.ImportTasks(tx, userID, items, opts); err != nil {
+			return err
+		}
+		result.FoldersCreated = append(created, result.FoldersCreated...)
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		respondTaskError(c, err, "Failed to import tasks")
+		return
+	}
+
+	response := gin.H{
+		"dry_run":         dryRun,
+		"created":         len(result.Tasks),
+		"skipped":         result.Skipped,
+		"warnings":        result.Warnings,
+		"folders_created": result.FoldersCreated,
+	}
+	if dryRun {
+		// Задачи из откаченной транзакции показываются без ID, в формате экспорта
+		response["message"] = "Dry run: nothing was imported"
+		response["tasks"] = planner.NewExport(nil, result.Tasks, loc, time.Now()).Tasks
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	for i := range result.Tasks {
+		planner.Localize(&result.Tasks[i], loc)
+	}
+	response["message"] = "Tasks imported"
+	response["tasks"] = result.Tasks
+	c.JSON(http.StatusCreated, response)
+}
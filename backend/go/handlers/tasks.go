@@ -1,34 +1,228 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"portfolio/models"
+	"portfolio/planner"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetTasks - получение списка задач пользователя
+// taskListSpec - сортировка списка задач
+var taskListSpec = listSpec{
+	SortFields: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"deadline":   "COALESCE(deadline, 'infinity')",
+		"priority":   priorityRankSQL,
+		"title":      "title",
+		"position":   "position",
+	},
+	DefaultSort:  "created_at",
+	DefaultOrder: "desc",
+}
+
+// priorityRankSQL упорядочивает приоритеты по важности, а не по алфавиту
+// (совпадает с planner.PriorityRank)
+const priorityRankSQL = "CASE priority WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END"
+
+// errInvalidPriority - приоритет вне перечня models.Priority*
+var errInvalidPriority = errors.New("priority must be low, medium or high")
+
+// userLocation загружает часовой пояс пользователя; при ошибке отвечает 500
+func userLocation(c *gin.Context, db *gorm.DB, userID uint) (*time.Location, bool) {
+	loc, err := planner.UserLocation(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	return loc, true
+}
+
+// GetTasks - получение списка задач пользователя и задач папок, открытых
+// ему другими пользователями.
+// Поддерживает общие параметры списков и фильтры folder (имя), folder_id,
+// priority, completed, status (ключи колонок доски через запятую),
+// deadline_from/deadline_to (даты в часовом поясе пользователя), due (today
+// или overdue), parent (ID родителя или none для задач верхнего уровня),
+// created_from/created_to, shared (true - только задачи общих папок, false
+// - только свои), assignee (ID исполнителя, me или none), а также tag
+// (теги через запятую, нужны все), q (язык запросов planner.Filter) и
+// filter (ID сохранённого фильтра).
 func GetTasks(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
 
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	list, err := parseListQuery(c, taskListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.Task{}).Scopes(planner.VisibleTo(userID))
+
+	if folder := c.Query("folder"); folder != "" && folder != "all" {
+		query = query.Where("folder = ?", folder)
+	}
+	if raw := c.Query("folder_id"); raw != "" {
+		folderID, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+			return
+		}
+		query = query.Where("folder_id = ?", folderID)
+	}
+	if priority := c.Query("priority"); priority != "" {
+		priorities := strings.Split(priority, ",")
+		for _, p := range priorities {
+			if !models.ValidPriority(p) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPriority.Error()})
+				return
+			}
+		}
+		query = query.Where("priority IN ?", priorities)
+	}
+	switch parent := c.Query("parent"); parent {
+	case "":
+	case "none":
+		query = query.Where("parent_id IS NULL")
+	default:
+		parentID, err := strconv.Atoi(parent)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+			return
+		}
+		query = query.Where("parent_id = ?", parentID)
+	}
+
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "none":
+		query = query.Where("assignee_id IS NULL")
+	case "me":
+		query = query.Where("assignee_id = ?", userID)
+	default:
+		assigneeID, err := strconv.Atoi(assignee)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid assignee ID"})
+			return
+		}
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+
+	completed, err := boolParam(c, "completed")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if completed != nil {
+		query = query.Where("completed = ?", *completed)
+	}
+	shared, err := boolParam(c, "shared")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if shared != nil {
+		if *shared {
+			query = query.Where("user_id <> ?", userID)
+		} else {
+			query = query.Where("user_id = ?", userID)
+		}
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+
+	// Границы - календарные дни пользователя, deadline_to включает весь день
+	for _, bound := range []struct {
+		param, op string
+		days      int
+	}{{"deadline_from", ">=", 0}, {"deadline_to", "<", 1}} {
+		if raw := c.Query(bound.param); raw != "" {
+			day, err := time.ParseInLocation(planner.DateLayout, raw, loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date in " + bound.param})
+				return
+			}
+			query = query.Where("deadline "+bound.op+" ?", day.AddDate(0, 0, bound.days))
+		}
+	}
+
+	if due := c.Query("due"); due != "" {
+		scope, err := planner.DueScope(due, time.Now(), loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Scopes(scope)
+	}
+
+	if query, err = dateRangeFilter(c, query, "created", "created_at"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := taskFilters(c, db, userID)
+	if err != nil {
+		respondFilterError(c, err, "Database error")
+		return
+	}
+	for _, f := range filters {
+		query = query.Scopes(f.Scope(time.Now(), loc))
+	}
+
 	var tasks []models.Task
-	if err := db.Where("user_id = ?", userID).Find(&tasks).Error; err != nil {
+	total, nextCursor, err := paginate(query.Preload("Tags"), list, &tasks, func(t *models.Task) (interface{}, uint) {
+		switch list.Sort {
+		case "updated_at":
+			return t.UpdatedAt, t.ID
+		case "deadline":
+			if t.Deadline == nil {
+				return "infinity", t.ID
+			}
+			return *t.Deadline, t.ID
+		case "priority":
+			return planner.PriorityRank(t.Priority), t.ID
+		case "title":
+			return t.Title, t.ID
+		case "position":
+			return t.Position, t.ID
+		default:
+			return t.CreatedAt, t.ID
+		}
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks: " + err.Error()})
 		return
 	}
+	for i := range tasks {
+		planner.Localize(&tasks[i], loc)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"tasks": tasks,
-		"count": len(tasks),
+		"tasks":       tasks,
+		"count":       len(tasks),
+		"total":       total,
+		"next_cursor": nextCursor,
 	})
 }
 
-// GetTask - получение задачи по ID
+// GetTask - получение задачи по ID. access - права пользователя на неё:
+// owner, edit или view.
 func GetTask(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
@@ -40,7 +234,7 @@ func GetTask(c *gin.Context) {
 	}
 
 	var task models.Task
-	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&task).Error; err != nil {
+	if err := db.Preload("Tags").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&task).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 			return
@@ -49,20 +243,111 @@ func GetTask(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"task": task})
+	access, err := planner.TaskRole(db, &task, userID)
+	if err != nil {
+		respondTaskError(c, err, "Database error")
+		return
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+	planner.Localize(&task, loc)
+
+	var subtasks []models.Task
+	if err := db.Scopes(planner.VisibleTo(userID)).Where("parent_id = ?", task.ID).Order("id ASC").Find(&subtasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	for i := range subtasks {
+		planner.Localize(&subtasks[i], loc)
+	}
+
+	var checklist []models.ChecklistItem
+	if err := db.Where("task_id = ?", task.ID).Order("position ASC").Find(&checklist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	reminders, err := planner.Reminders(db, task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task":      task,
+		"access":    access,
+		"subtasks":  subtasks,
+		"checklist": checklist,
+		"reminders": reminders,
+	})
 }
 
-// CreateTask - создание новой задачи
+// maxOccurrenceRange - наибольший интервал календаря в днях
+const maxOccurrenceRange = 366
+
+// GetTaskOccurrences - задачи и будущие повторения за интервал дат
+// from..to (YYYY-MM-DD, включительно) для календаря
+func GetTaskOccurrences(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
+	}
+
+	from, err := time.ParseInLocation(planner.DateLayout, c.Query("from"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date in from"})
+		return
+	}
+	to, err := time.ParseInLocation(planner.DateLayout, c.Query("to"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date in to"})
+		return
+	}
+	if to.Before(from) || to.After(from.AddDate(0, 0, maxOccurrenceRange)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Range must be 0..%d days", maxOccurrenceRange)})
+		return
+	}
+
+	occurrences, err := planner.Expand(db, userID, from, to, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to expand occurrences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"occurrences": occurrences,
+		"count":       len(occurrences),
+		"from":        from.Format(planner.DateLayout),
+		"to":          to.Format(planner.DateLayout),
+		"timezone":    loc.String(),
+	})
+}
+
+// CreateTask - создание новой задачи. В папке, открытой пользователю с
+// правом edit (folder_id), задача создаётся от имени владельца папки.
 func CreateTask(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
 
 	var input struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description"`
-		Folder      string `json:"folder"`
-		Deadline    string `json:"deadline"`
-		Priority    string `json:"priority"`
+		Title       string   `json:"title" binding:"required"`
+		Description string   `json:"description"`
+		Folder      string   `json:"folder"`
+		FolderID    *uint    `json:"folder_id"` // приоритетнее имени folder; может быть общей папкой
+		Deadline    string   `json:"deadline"`
+		Priority    string   `json:"priority"`
+		Recurrence  string   `json:"recurrence"`
+		ParentID    *uint    `json:"parent_id"`
+		Status      string   `json:"status"` // колонка доски, по умолчанию первая невыполненная
+		Tags        []string `json:"tags"`
+		Reminders   *[]int   `json:"reminders"` // минуты до срока; без поля - из настроек уведомлений
+		AssigneeID  *uint    `json:"assignee_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -71,28 +356,84 @@ func CreateTask(c *gin.Context) {
 	}
 
 	// Устанавливаем значения по умолчанию
-	if input.Folder == "" {
-		input.Folder = "general"
-	}
 	if input.Priority == "" {
-		input.Priority = "medium"
+		input.Priority = models.PriorityMedium
+	}
+	if !models.ValidPriority(input.Priority) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPriority.Error()})
+		return
+	}
+
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
 	}
 
 	task := models.Task{
 		UserID:      userID,
 		Title:       input.Title,
 		Description: input.Description,
-		Folder:      input.Folder,
-		Deadline:    input.Deadline,
 		Priority:    input.Priority,
 		Completed:   false,
 	}
 
-	if err := db.Create(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task: " + err.Error()})
+	if err := planner.SetDeadline(&task, input.Deadline, loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := planner.SetRecurrence(&task, input.Recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	err := db.Transaction(func(tx *gorm.DB) error {
+		folder, err := planner.WritableFolder(tx, userID, input.FolderID, input.Folder)
+		if err != nil {
+			return err
+		}
+		task.UserID = folder.UserID
+		planner.SetFolder(&task, folder)
+		if err := planner.SetParent(tx, &task, input.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if input.AssigneeID != nil {
+			if err := planner.SetAssignee(tx, &task, input.AssigneeID, userID); err != nil {
+				return err
+			}
+			if err := tx.Model(&task).Update("assignee_id", task.AssigneeID).Error; err != nil {
+				return err
+			}
+		}
+		if err := planner.AppendTask(tx, &task, input.Status); err != nil {
+			return err
+		}
+		if len(input.Tags) > 0 {
+			if err := planner.SetTags(tx, &task, input.Tags); err != nil {
+				return err
+			}
+		}
+		if input.Reminders != nil {
+			_, err = planner.SetReminders(tx, task.ID, *input.Reminders)
+		} else {
+			err = planner.DefaultReminders(tx, &task)
+		}
+		if err != nil {
+			return err
+		}
+		if err := planner.RecalcParent(tx, &task); err != nil {
+			return err
+		}
+		return planner.LogCreated(tx, userID, &task, loc)
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to create task")
+		return
+	}
+	planner.Localize(&task, loc)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Task created successfully",
 		"task":    task,
@@ -111,57 +452,159 @@ func UpdateTask(c *gin.Context) {
 	}
 
 	var input struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		Folder      string `json:"folder"`
-		Deadline    string `json:"deadline"`
-		Priority    string `json:"priority"`
-		Completed   *bool  `json:"completed"`
+		Title       string    `json:"title"`
+		Description string    `json:"description"`
+		Folder      string    `json:"folder"`
+		FolderID    *uint     `json:"folder_id"`
+		Deadline    *string   `json:"deadline"` // пустая строка снимает срок
+		Priority    string    `json:"priority"`
+		Completed   *bool     `json:"completed"`
+		Recurrence  *string   `json:"recurrence"`  // пустая строка снимает повторение
+		ParentID    *uint     `json:"parent_id"`   // 0 делает задачу задачей верхнего уровня
+		Force       bool      `json:"force"`       // выполнить, несмотря на открытые блокирующие задачи
+		Tags        *[]string `json:"tags"`        // заменяет теги задачи
+		Reminders   *[]int    `json:"reminders"`   // заменяет напоминания (минуты до срока)
+		AssigneeID  *uint     `json:"assignee_id"` // 0 снимает исполнителя
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
-
-	var task models.Task
-	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&task).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	if input.Priority != "" && !models.ValidPriority(input.Priority) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPriority.Error()})
 		return
 	}
 
-	// Обновляем поля
-	if input.Title != "" {
-		task.Title = input.Title
-	}
-	if input.Description != "" {
-		task.Description = input.Description
-	}
-	if input.Folder != "" {
-		task.Folder = input.Folder
-	}
-	if input.Deadline != "" {
-		task.Deadline = input.Deadline
-	}
-	if input.Priority != "" {
-		task.Priority = input.Priority
-	}
-	if input.Completed != nil {
-		task.Completed = *input.Completed
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
+		return
 	}
 
-	if err := db.Save(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+	var task models.Task
+	var next *models.Task
+	var blockers []models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		if err := planner.LoadTags(tx, &task); err != nil {
+			return err
+		}
+		before := planner.TakeSnapshot(&task, loc)
+
+		// Обновляем поля
+		if input.Title != "" {
+			task.Title = input.Title
+		}
+		if input.Description != "" {
+			task.Description = input.Description
+		}
+		// Исполнитель проверяется заново и при переносе в другую папку
+		assignee := task.AssigneeID
+		checkAssignee := input.AssigneeID != nil
+		if checkAssignee {
+			assignee = nil
+			if *input.AssigneeID != 0 {
+				assignee = input.AssigneeID
+			}
+		}
+		if input.FolderID != nil || input.Folder != "" {
+			if task.UserID != userID {
+				return planner.ErrOwnerOnly
+			}
+			folder, err := planner.ResolveFolder(tx, userID, input.FolderID, input.Folder)
+			if err != nil {
+				return err
+			}
+			planner.SetFolder(&task, folder)
+			checkAssignee = true
+		}
+		if checkAssignee {
+			if err := planner.SetAssignee(tx, &task, assignee, userID); err != nil {
+				return err
+			}
+		}
+		if input.Deadline != nil {
+			if err := planner.SetDeadline(&task, *input.Deadline, loc); err != nil {
+				return err
+			}
+		}
+		if input.Priority != "" {
+			task.Priority = input.Priority
+		}
+		if input.Tags != nil {
+			if err := planner.SetTags(tx, &task, *input.Tags); err != nil {
+				return err
+			}
+		}
+		if input.Reminders != nil {
+			if _, err := planner.SetReminders(tx, task.ID, *input.Reminders); err != nil {
+				return err
+			}
+		}
+
+		// Без нового правила текущее проверяется заново: дедлайн мог измениться
+		recurrence := task.Recurrence
+		if input.Recurrence != nil {
+			recurrence = *input.Recurrence
+		}
+		if err := planner.SetRecurrence(&task, recurrence); err != nil {
+			return err
+		}
+
+		// При переносе прогресс пересчитывается у прежнего родителя
+		oldParent := task.ParentID
+		if input.ParentID != nil {
+			var parentID *uint
+			if *input.ParentID != 0 {
+				parentID = input.ParentID
+			}
+			if err := planner.SetParent(tx, &task, parentID); err != nil {
+				return err
+			}
+		}
+
+		completed := task.Completed
+		if input.Completed != nil {
+			completed = *input.Completed
+		}
+		if completed && !task.Completed {
+			if blockers, err = checkBlockers(tx, &task, input.Force); err != nil {
+				return err
+			}
+		}
+		next, err = completeTask(tx, &task, completed)
+		if err != nil {
+			return err
+		}
+		if err := planner.LogChanges(tx, userID, models.ActivityUpdated, &task, before, loc); err != nil {
+			return err
+		}
+
+		if oldParent != nil && (task.ParentID == nil || *oldParent != *task.ParentID) {
+			return planner.RecalcProgress(tx, *oldParent)
+		}
+		return nil
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to update task")
 		return
 	}
+	localizeTaskPair(&task, next, loc)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Task updated successfully",
-		"task":    task,
+		"message":       "Task updated successfully",
+		"task":          task,
+		"next_task":     next,
+		"open_blockers": blockers,
 	})
 }
 
-// UpdateTaskStatus - обновление статуса задачи
+// UpdateTaskStatus - обновление статуса задачи.
+// Выполнение повторяющейся задачи создаёт её следующее повторение.
+// Задачу с открытыми блокирующими задачами можно выполнить только с
+// force: true - тогда они возвращаются в open_blockers как предупреждение.
 func UpdateTaskStatus(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
@@ -172,8 +615,10 @@ func UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
+	// Указатель: binding:"required" отклоняет false у обычного bool
 	var input struct {
-		Completed bool `json:"completed" binding:"required"`
+		Completed *bool `json:"completed" binding:"required"`
+		Force     bool  `json:"force"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -181,96 +626,197 @@ func UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
-	var task models.Task
-	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&task).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
 		return
 	}
 
-	task.Completed = input.Completed
-	if err := db.Save(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
+	var task models.Task
+	var next *models.Task
+	var blockers []models.Task
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		before := planner.TakeSnapshot(&task, loc)
+		if *input.Completed && !task.Completed {
+			if blockers, err = checkBlockers(tx, &task, input.Force); err != nil {
+				return err
+			}
+		}
+		if next, err = completeTask(tx, &task, *input.Completed); err != nil {
+			return err
+		}
+		return planner.LogChanges(tx, userID, models.ActivityStatus, &task, before, loc)
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to update task status")
 		return
 	}
+	localizeTaskPair(&task, next, loc)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Task status updated successfully",
-		"task":    task,
+		"message":       "Task status updated successfully",
+		"task":          task,
+		"next_task":     next,
+		"open_blockers": blockers,
 	})
 }
 
-// DeleteTask - удаление задачи
-func DeleteTask(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	userID := c.GetUint("user_id")
+// lockTask загружает задачу, которую пользователь может изменять (свою или
+// из папки, открытой ему с правом edit), с блокировкой строки, чтобы
+// параллельные отметки выполнения не создали повторение дважды. Перед
+// строкой задачи блокируется доска владельца (см. planner.LockBoard).
+func lockTask(tx *gorm.DB, task *models.Task, id int, userID uint) error {
+	var owner models.Task
+	if err := tx.Select("user_id").Scopes(planner.VisibleTo(userID)).Where("id = ?", id).First(&owner).Error; err != nil {
+		return err
+	}
+	if err := planner.LockBoard(tx, owner.UserID); err != nil {
+		return err
+	}
 
-	id, err := strconv.Atoi(c.Param("id"))
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Scopes(planner.VisibleTo(userID)).
+		Where("id = ?", id).
+		First(task).Error
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
-		return
+		return err
 	}
+	return planner.CanEdit(tx, task, userID)
+}
 
-	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Task{})
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
-		return
+// checkBlockers возвращает открытые блокирующие задачи. Без force наличие
+// таких задач - ошибка *planner.BlockedError.
+func checkBlockers(tx *gorm.DB, task *models.Task, force bool) ([]models.Task, error) {
+	blockers, err := planner.OpenBlockers(tx, task.ID)
+	if err != nil || len(blockers) == 0 {
+		return nil, err
 	}
-
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
-		return
+	if !force {
+		return nil, &planner.BlockedError{Blockers: blockers}
 	}
+	return blockers, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+// completeTask сохраняет задачу с новым статусом, перенося её в колонку
+// доски, соответствующую completed. Если задача только что выполнена,
+// возвращает созданное следующее повторение.
+func completeTask(tx *gorm.DB, task *models.Task, completed bool) (*models.Task, error) {
+	wasCompleted := task.Completed
+	if err := planner.SetCompleted(tx, task, completed); err != nil {
+		return nil, err
+	}
+	if err := tx.Save(task).Error; err != nil {
+		return nil, err
+	}
+	return afterStatusChange(tx, task, wasCompleted)
 }
 
-// GetFolders - получение списка папок пользователя
-func GetFolders(c *gin.Context) {
-	db := c.MustGet("db").(*gorm.DB)
-	userID := c.GetUint("user_id")
+// afterStatusChange пересчитывает прогресс родителя и создаёт следующее
+// повторение, если задача только что выполнена. Даты повторения считаются
+// в часовом поясе владельца задачи, а не участника, который её выполнил.
+func afterStatusChange(tx *gorm.DB, task *models.Task, wasCompleted bool) (*models.Task, error) {
+	if err := planner.RecalcParent(tx, task); err != nil {
+		return nil, err
+	}
+	if !task.Completed || wasCompleted {
+		return nil, nil
+	}
+	loc, err := planner.UserLocation(tx, task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return planner.SpawnNext(tx, task, loc)
+}
 
-	var folders []string
-	if err := db.Model(&models.Task{}).
-		Where("user_id = ?", userID).
-		Distinct("folder").
-		Pluck("folder", &folders).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения папок"})
-		return
+// localizeTaskPair переводит в часовой пояс пользователя задачу и её
+// следующее повторение (если оно создано)
+func localizeTaskPair(task, next *models.Task, loc *time.Location) {
+	planner.Localize(task, loc)
+	if next != nil {
+		planner.Localize(next, loc)
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"folders": folders,
-		"count":   len(folders),
-	})
+// respondTaskError переводит ошибку изменения задачи в HTTP-ответ
+func respondTaskError(c *gin.Context, err error, message string) {
+	var blocked *planner.BlockedError
+	switch {
+	case errors.As(err, &blocked):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":    err.Error(),
+			"blockers": blocked.Blockers,
+			"hint":     "pass force: true to complete anyway",
+		})
+	case errors.Is(err, planner.ErrFolderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+	case errors.Is(err, planner.ErrReadOnly), errors.Is(err, planner.ErrOwnerOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, planner.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, planner.ErrDependencyCycle), errors.Is(err, planner.ErrDependencyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, planner.ErrInvalidRule),
+		errors.Is(err, planner.ErrInvalidDeadline),
+		errors.Is(err, planner.ErrRecurrenceDeadline),
+		errors.Is(err, planner.ErrParentNotFound),
+		errors.Is(err, planner.ErrParentCycle),
+		errors.Is(err, planner.ErrChecklistOrder),
+		errors.Is(err, planner.ErrDependencySelf),
+		errors.Is(err, planner.ErrDependencyOwner),
+		errors.Is(err, planner.ErrColumnNotFound),
+		errors.Is(err, planner.ErrFolderName),
+		errors.Is(err, planner.ErrTagName),
+		errors.Is(err, planner.ErrInvalidReminder),
+		errors.Is(err, planner.ErrInvalidCalendar),
+		errors.Is(err, planner.ErrInvalidImport),
+		errors.Is(err, planner.ErrTooManyTasks),
+		errors.Is(err, planner.ErrAssignee):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
 }
 
-// CreateFolder - создание новой папки
-func CreateFolder(c *gin.Context) {
+// DeleteTask - удаление задачи вместе со всеми подзадачами
+func DeleteTask(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userID := c.GetUint("user_id")
 
-	var input struct {
-		Name string `json:"name" binding:"required"`
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос"})
+	loc, ok := userLocation(c, db, userID)
+	if !ok {
 		return
 	}
 
-	// Проверяем, существует ли уже такая папка
-	var count int64
-	db.Model(&models.Task{}).
-		Where("user_id = ? AND folder = ?", userID, input.Name).
-		Count(&count)
-
-	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Папка уже существует"})
+	var deleted int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := lockTask(tx, &task, id, userID); err != nil {
+			return err
+		}
+		if err := planner.LoadTags(tx, &task); err != nil {
+			return err
+		}
+		before := planner.TakeSnapshot(&task, loc)
+		if deleted, err = planner.DeleteTasks(tx, task.UserID, []uint{task.ID}); err != nil {
+			return err
+		}
+		return planner.LogDeleted(tx, userID, before, task.ID)
+	})
+	if err != nil {
+		respondTaskError(c, err, "Failed to delete task")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Папка '%s' создана", input.Name),
-		"folder":  input.Name,
+		"message": "Task deleted successfully",
+		"deleted": deleted,
 	})
 }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"portfolio/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreatePersonalToken создание персонального токена доступа.
+// Токен возвращается только в этом ответе, в базе хранится его хеш.
+func CreatePersonalToken(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var request struct {
+		Name          string `json:"name" binding:"required,max=100"`
+		ExpiresInDays int    `json:"expires_in_days" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	secret, err := generateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания токена"})
+		return
+	}
+	raw := models.PersonalTokenPrefix + secret
+
+	token := models.PersonalToken{
+		UserID:    userID,
+		Name:      request.Name,
+		TokenHash: models.HashPersonalToken(raw),
+		Hint:      raw[:len(models.PersonalTokenPrefix)+4],
+	}
+	if request.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, request.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := db.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания токена"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Токен создан. Сохраните его: повторно он показан не будет",
+		"token":   raw,
+		"info":    token,
+	})
+}
+
+// GetPersonalTokens список действующих персональных токенов
+func GetPersonalTokens(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var tokens []models.PersonalToken
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения токенов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// RevokePersonalToken отзыв персонального токена
+func RevokePersonalToken(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	result := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).Delete(&models.PersonalToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отзыва токена"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Токен не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Токен отозван"})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTrash получение списка файлов в корзине
+func GetTrash(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var files []models.File
+	if err := db.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения корзины"})
+		return
+	}
+
+	retention := storage.TrashRetention()
+	response := make([]gin.H, len(files))
+	for i, file := range files {
+		response[i] = gin.H{
+			"id":         file.ID,
+			"filename":   file.OriginalFilename,
+			"size":       file.FileSize,
+			"size_human": file.GetSizeHuman(),
+			"mime_type":  file.MimeType,
+			"folder":     file.Folder,
+			"deleted_at": file.DeletedAt.Time.Format("2006-01-02 15:04:05"),
+			"purge_at":   file.DeletedAt.Time.Add(retention).Format("2006-01-02 15:04:05"),
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":          response,
+		"count":          len(files),
+		"retention_days": int(retention / (24 * time.Hour)),
+		"counts_quota":   storage.TrashCountsQuota(),
+	})
+}
+
+// RestoreTrashedFile восстановление файла из корзины
+func RestoreTrashedFile(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	file, ok := findTrashedFile(c, db, userID)
+	if !ok {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return storage.RestoreFile(tx, file)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Файл в корзине не найден"})
+			return
+		}
+		if errors.Is(err, storage.ErrQuotaExceeded) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Недостаточно места в хранилище для восстановления"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка восстановления файла"})
+		return
+	}
+
+	used, quota, _ := storage.Usage(db, userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Файл восстановлен",
+		"file": gin.H{
+			"id":       file.ID,
+			"filename": file.OriginalFilename,
+			"folder":   file.Folder,
+		},
+		"storage_used":  used,
+		"storage_quota": quota,
+	})
+}
+
+// DeleteTrashedFile окончательное удаление файла из корзины
+func DeleteTrashedFile(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	file, ok := findTrashedFile(c, db, userID)
+	if !ok {
+		return
+	}
+
+	purged, err := storage.PurgeFiles(db, []models.File{*file})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления файла"})
+		return
+	}
+	if purged == 0 {
+		// Файл восстановлен или удалён параллельным запросом
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл в корзине не найден"})
+		return
+	}
+
+	used, quota, _ := storage.Usage(db, userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Файл удалён навсегда",
+		"storage_used":  used,
+		"storage_quota": quota,
+	})
+}
+
+// EmptyTrash очистка корзины пользователя
+func EmptyTrash(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var files []models.File
+	if err := db.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Find(&files).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения корзины"})
+		return
+	}
+
+	purged, err := storage.PurgeFiles(db, files)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка очистки корзины"})
+		return
+	}
+
+	used, quota, _ := storage.Usage(db, userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Корзина очищена",
+		"deleted_files": purged,
+		"storage_used":  used,
+		"storage_quota": quota,
+	})
+}
+
+// findTrashedFile загружает файл из корзины по :id и проверяет владельца
+func findTrashedFile(c *gin.Context, db *gorm.DB, userID uint) (*models.File, bool) {
+	var file models.File
+	if err := db.Unscoped().
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", c.Param("id"), userID).
+		First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл в корзине не найден"})
+		return nil, false
+	}
+	return &file, true
+}
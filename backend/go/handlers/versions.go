@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"portfolio/models"
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetFileVersions получение истории версий файла
+func GetFileVersions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	var versions []models.FileVersion
+	if err := db.Where("file_id = ?", file.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения версий"})
+		return
+	}
+
+	response := []gin.H{{
+		"version":     file.Version,
+		"size":        file.FileSize,
+		"size_human":  file.GetSizeHuman(),
+		"uploaded_at": file.UploadedAt.Format("2006-01-02 15:04:05"),
+		"current":     true,
+	}}
+	var total int64
+	for _, version := range versions {
+		total += version.FileSize
+		response = append(response, gin.H{
+			"version":     version.Version,
+			"size":        version.FileSize,
+			"size_human":  formatBytes(version.FileSize),
+			"uploaded_at": version.UploadedAt.Format("2006-01-02 15:04:05"),
+			"current":     false,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file_id":             file.ID,
+		"filename":            file.OriginalFilename,
+		"versions":            response,
+		"count":               len(response),
+		"versions_size":       total,
+		"versions_size_human": formatBytes(total),
+	})
+}
+
+// DownloadFileVersion скачивание конкретной версии файла
+func DownloadFileVersion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	file, version, ok := findFileVersion(c, db, userID)
+	if !ok {
+		return
+	}
+
+	if version == nil {
+		serveFile(c, db, file)
+		return
+	}
+
+	// Без ID, чтобы serveFile не перезаписал хеш текущей версии
+	serveFile(c, db, &models.File{
+		UserID:           file.UserID,
+		OriginalFilename: file.OriginalFilename,
+		FilePath:         version.FilePath,
+		FileSize:         version.FileSize,
+		MimeType:         version.MimeType,
+		ContentHash:      version.ContentHash,
+		WrappedKey:       version.WrappedKey,
+		KeyID:            version.KeyID,
+	})
+}
+
+// RestoreFileVersion восстановление выбранной версии как текущей
+func RestoreFileVersion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	file, version, ok := findFileVersion(c, db, userID)
+	if !ok {
+		return
+	}
+	if version == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Эта версия уже текущая"})
+		return
+	}
+
+	restored := version.Version
+	if err := storage.RestoreVersion(db, file, version); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Версия не найдена"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка восстановления версии"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Версия " + strconv.Itoa(restored) + " восстановлена",
+		"file": gin.H{
+			"id":       file.ID,
+			"filename": file.OriginalFilename,
+			"size":     file.FileSize,
+			"version":  file.Version,
+		},
+	})
+}
+
+// PruneFileVersions удаление старых версий файла.
+// Параметр keep задаёт, сколько последних версий оставить (по умолчанию 0).
+func PruneFileVersions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+
+	keep, err := strconv.Atoi(c.DefaultQuery("keep", "0"))
+	if err != nil || keep < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверное значение keep"})
+		return
+	}
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return
+	}
+
+	pruned, err := storage.PruneVersions(db, &file, keep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления версий"})
+		return
+	}
+
+	used, quota, _ := storage.Usage(db, userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Старые версии удалены",
+		"pruned":        pruned,
+		"storage_used":  used,
+		"storage_quota": quota,
+	})
+}
+
+// findFileVersion загружает файл по :id и версию по :version.
+// Для текущей версии возвращается nil вместо FileVersion.
+func findFileVersion(c *gin.Context, db *gorm.DB, userID uint) (*models.File, *models.FileVersion, bool) {
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный номер версии"})
+		return nil, nil, false
+	}
+
+	var file models.File
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+		return nil, nil, false
+	}
+
+	if number == max(file.Version, 1) {
+		return &file, nil, true
+	}
+
+	var version models.FileVersion
+	if err := db.Where("file_id = ? AND version = ?", file.ID, number).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Версия не найдена"})
+		return nil, nil, false
+	}
+	return &file, &version, true
+}
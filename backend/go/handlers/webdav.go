@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"portfolio/dav"
+	"portfolio/storage"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+	"gorm.io/gorm"
+)
+
+// WebDAVPrefix - путь, по которому хранилище подключается как сетевой диск
+const WebDAVPrefix = "/webdav"
+
+// WebDAVMethods - методы, которые нужно зарегистрировать в роутере
+var WebDAVMethods = []string{
+	"OPTIONS", "GET", "HEAD", "PUT", "DELETE", "MKCOL",
+	"COPY", "MOVE", "PROPFIND", "PROPPATCH", "LOCK", "UNLOCK",
+}
+
+// davLocks - блокировки WebDAV отдельно для каждого пользователя
+var davLocks = struct {
+	sync.Mutex
+	systems map[uint]webdav.LockSystem
+}{systems: make(map[uint]webdav.LockSystem)}
+
+// davLockSystem возвращает систему блокировок пользователя
+func davLockSystem(userID uint) webdav.LockSystem {
+	davLocks.Lock()
+	defer davLocks.Unlock()
+
+	ls, ok := davLocks.systems[userID]
+	if !ok {
+		ls = webdav.NewMemLS()
+		davLocks.systems[userID] = ls
+	}
+	return ls
+}
+
+// WebDAV обработчик сетевого диска. PUT обрабатывается отдельно, чтобы
+// вернуть клиенту понятный код при нехватке квоты или недопустимом файле;
+// остальные методы выполняет webdav.Handler поверх dav.FileSystem.
+func WebDAV(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetUint("user_id")
+	fs := dav.NewFileSystem(db, userID)
+
+	if c.Request.Method == http.MethodPut {
+		putWebDAV(c, fs)
+		return
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     WebDAVPrefix,
+		FileSystem: fs,
+		LockSystem: davLockSystem(userID),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("⚠️ WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	handler.ServeHTTP(c.Writer, c.Request)
+}
+
+// putWebDAV загрузка файла по WebDAV
+func putWebDAV(c *gin.Context, fs *dav.FileSystem) {
+	if c.Request.ContentLength > storage.MaxFileSize {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	_, statErr := fs.Stat(c.Request.Context(), c.Param("path"))
+
+	file, err := fs.Put(c.Param("path"), c.Request.Body, c.ContentType())
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrQuotaExceeded):
+			c.Status(http.StatusInsufficientStorage)
+		case errors.Is(err, storage.ErrFileTooLarge):
+			c.Status(http.StatusRequestEntityTooLarge)
+		case errors.Is(err, storage.ErrFileType), errors.Is(err, os.ErrPermission):
+			c.Status(http.StatusForbidden)
+		case errors.Is(err, os.ErrNotExist):
+			c.Status(http.StatusConflict) // нет родительской папки
+		case errors.Is(err, dav.ErrIsDir):
+			c.Status(http.StatusMethodNotAllowed)
+		case errors.Is(err, os.ErrInvalid):
+			c.Status(http.StatusBadRequest)
+		default:
+			log.Printf("⚠️ WebDAV PUT %s: %v", c.Request.URL.Path, err)
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.Header("ETag", `"`+file.ContentHash+`"`)
+	if statErr == nil {
+		c.Status(http.StatusNoContent)
+	} else {
+		c.Status(http.StatusCreated)
+	}
+}
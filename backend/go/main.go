@@ -6,6 +6,10 @@ import (
 	"portfolio/database"
 	"portfolio/handlers"
 	"portfolio/middleware"
+	"portfolio/notify"
+	"portfolio/planner"
+	"portfolio/storage"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -30,6 +34,34 @@ func main() {
 		// НЕ завершаем с fatal ошибкой!
 	}
 
+	// Шифрование файлов включается ключом STORAGE_MASTER_KEY
+	if err := storage.InitEncryption(); err != nil {
+		log.Fatal("Ошибка настройки шифрования: ", err)
+	}
+	if storage.EncryptionEnabled() {
+		log.Printf("🔐 Шифрование файлов включено (ключ %s)", storage.CurrentKeyID())
+	}
+	if !storage.SigningEnabled() {
+		log.Println("⚠️ SIGNED_URL_SECRET и JWT_SECRET не заданы: подписанные ссылки на файлы отключены")
+	}
+
+	// Служебные команды, например: go run . reconcile-storage -fix
+	if len(os.Args) > 1 {
+		if err := runCommand(db, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Фоновая очистка корзины от файлов старше TRASH_RETENTION_DAYS
+	storage.StartTrashPurger(db, time.Hour)
+
+	// Фоновая индексация содержимого файлов для поиска
+	storage.StartIndexer(db)
+
+	// Фоновая отправка напоминаний о сроках задач
+	planner.StartReminderScheduler(db, notify.FromEnv(db), time.Minute)
+
 	router := gin.Default()
 
 	// Настройка CORS для разработки
@@ -44,8 +76,8 @@ func main() {
 			"file://", // Добавьте если открываете через file://
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-Requested-With", "Range", "If-None-Match", "If-Modified-Since", "If-Range"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "Content-Range", "Accept-Ranges", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * 3600,
 	}))
@@ -64,6 +96,16 @@ func main() {
 	{
 		public.POST("/login", handlers.Login)
 		public.POST("/register", handlers.Register)
+
+		// Публичные ссылки на файлы
+		public.GET("/share/:token", handlers.GetPublicShare)
+		public.GET("/share/:token/download", handlers.DownloadPublicShare)
+
+		// iCalendar-лента задач по секретному токену
+		public.GET("/calendar/:token", handlers.CalendarFeedICS)
+
+		// Скачивание по временной подписанной ссылке
+		public.GET("/files/signed/:id", handlers.DownloadSignedFile)
 		public.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "ok", "service": "portfolio-backend"})
 		})
@@ -76,19 +118,90 @@ func main() {
 	{
 		// Пользователь
 		api.GET("/user", handlers.GetUser)
+		api.PUT("/user/timezone", handlers.UpdateUserTimezone)
 		api.POST("/logout", handlers.Logout)
 
 		// Задачи
 		tasks := api.Group("/tasks")
 		{
 			tasks.GET("", handlers.GetTasks)
+			tasks.GET("/occurrences", handlers.GetTaskOccurrences)
+			tasks.GET("/graph", handlers.GetDependencyGraph)
 			tasks.GET("/:id", handlers.GetTask)
 			tasks.POST("", handlers.CreateTask)
 			tasks.PUT("/:id", handlers.UpdateTask)
 			tasks.DELETE("/:id", handlers.DeleteTask)
 			tasks.PUT("/:id/status", handlers.UpdateTaskStatus)
+			tasks.POST("/:id/move", handlers.MoveTask)
+			tasks.PUT("/:id/tags", handlers.SetTaskTags)
+			tasks.GET("/:id/reminders", handlers.GetTaskReminders)
+			tasks.PUT("/:id/reminders", handlers.SetTaskReminders)
+
+			// Чек-листы
+			tasks.GET("/:id/checklist", handlers.GetChecklist)
+			tasks.POST("/:id/checklist", handlers.AddChecklistItem)
+			tasks.PUT("/:id/checklist/order", handlers.ReorderChecklist)
+			tasks.PUT("/:id/checklist/:item", handlers.UpdateChecklistItem)
+			tasks.POST("/:id/checklist/:item/toggle", handlers.ToggleChecklistItem)
+			tasks.DELETE("/:id/checklist/:item", handlers.DeleteChecklistItem)
+
+			// Журнал изменений и комментарии
+			tasks.GET("/:id/activity", handlers.GetTaskActivity)
+			tasks.GET("/:id/comments", handlers.GetTaskComments)
+			tasks.POST("/:id/comments", handlers.CreateTaskComment)
+			tasks.PUT("/:id/comments/:comment", handlers.UpdateTaskComment)
+			tasks.DELETE("/:id/comments/:comment", handlers.DeleteTaskComment)
+
+			// Зависимости
+			tasks.GET("/:id/dependencies", handlers.GetTaskDependencies)
+			tasks.POST("/:id/dependencies", handlers.AddTaskDependency)
+			tasks.DELETE("/:id/dependencies/:blocker", handlers.RemoveTaskDependency)
+
+			// Папки задач
 			tasks.GET("/folders", handlers.GetFolders)
 			tasks.POST("/folders", handlers.CreateFolder)
+			tasks.PUT("/folders/order", handlers.ReorderFolders)
+			tasks.PUT("/folders/:id", handlers.UpdateFolder)
+			tasks.DELETE("/folders/:id", handlers.DeleteFolder)
+			tasks.GET("/folders/:id/members", handlers.GetFolderMembers)
+			tasks.PUT("/folders/:id/members", handlers.ShareFolder)
+			tasks.DELETE("/folders/:id/members/:user", handlers.RemoveFolderMember)
+
+			// Сохранённые фильтры ("умные списки")
+			tasks.GET("/filters", handlers.GetSavedFilters)
+			tasks.POST("/filters", handlers.CreateSavedFilter)
+			tasks.PUT("/filters/:id", handlers.UpdateSavedFilter)
+			tasks.DELETE("/filters/:id", handlers.DeleteSavedFilter)
+
+			// iCalendar: лента для календарей и импорт .ics
+			tasks.GET("/calendar", handlers.GetCalendarFeed)
+			tasks.POST("/calendar", handlers.CreateCalendarFeed)
+			tasks.DELETE("/calendar", handlers.DeleteCalendarFeed)
+			tasks.POST("/import/ics", handlers.ImportCalendar)
+
+			// Импорт и экспорт задач
+			tasks.GET("/export", handlers.ExportTasks)
+			tasks.POST("/import", handlers.ImportTasks)
+		}
+
+		// Теги задач
+		tags := api.Group("/tags")
+		{
+			tags.GET("", handlers.GetTags)
+			tags.POST("", handlers.CreateTag)
+			tags.PUT("/:id", handlers.UpdateTag)
+			tags.DELETE("/:id", handlers.DeleteTag)
+		}
+
+		// Канбан-доска задач
+		board := api.Group("/board")
+		{
+			board.GET("", handlers.GetBoard)
+			board.GET("/columns", handlers.GetBoardColumns)
+			board.POST("/columns", handlers.CreateBoardColumn)
+			board.PUT("/columns/order", handlers.ReorderBoardColumns)
+			board.PUT("/columns/:id", handlers.UpdateBoardColumn)
+			board.DELETE("/columns/:id", handlers.DeleteBoardColumn)
 		}
 
 		// Файлы
@@ -97,15 +210,67 @@ func main() {
 			// Сначала общие маршруты, затем динамические
 			files.GET("/folders", handlers.GetFileFolders) // <-- ДОЛЖЕН БЫТЬ ПЕРВЫМ!
 			files.POST("/folders", handlers.CreateFileFolder)
+			files.PUT("/folders/:id/rename", handlers.RenameFileFolder)
+			files.PUT("/folders/:id/move", handlers.MoveFileFolder)
+			files.DELETE("/folders/:id", handlers.DeleteFileFolder)
+
+			// Пакетные операции и архивы
+			files.POST("/batch/delete", handlers.BatchDeleteFiles)
+			files.POST("/batch/move", handlers.BatchMoveFiles)
+			files.POST("/archive", handlers.DownloadArchive)
+
+			// Корзина
+			files.GET("/trash", handlers.GetTrash)
+			files.DELETE("/trash", handlers.EmptyTrash)
+			files.POST("/trash/:id/restore", handlers.RestoreTrashedFile)
+			files.DELETE("/trash/:id", handlers.DeleteTrashedFile)
 
 			// Затем остальные
 			files.GET("", handlers.GetFiles)
+			files.GET("/search", handlers.SearchFiles)
+			files.GET("/stats", handlers.GetFileStats)
 			files.GET("/:id", handlers.GetFile)
 			files.POST("/upload", handlers.UploadFile)
 			files.DELETE("/:id", handlers.DeleteFile)
 			files.GET("/download/:id", handlers.DownloadFile)
+			files.POST("/:id/signed-url", handlers.GetSignedDownloadURL)
 			files.PUT("/:id/rename", handlers.RenameFile)
 			files.PUT("/:id/move", handlers.MoveFile)
+			files.POST("/:id/share", handlers.CreateFileShare)
+			files.GET("/:id/thumbnail", handlers.GetFileThumbnail)
+			files.GET("/:id/preview", handlers.GetFilePreview)
+
+			// Версии файлов
+			files.GET("/:id/versions", handlers.GetFileVersions)
+			files.GET("/:id/versions/:version/download", handlers.DownloadFileVersion)
+			files.POST("/:id/versions/:version/restore", handlers.RestoreFileVersion)
+			files.DELETE("/:id/versions", handlers.PruneFileVersions)
+		}
+
+		// Публичные ссылки
+		shares := api.Group("/shares")
+		{
+			shares.GET("", handlers.GetFileShares)
+			shares.DELETE("/:id", handlers.RevokeFileShare)
+		}
+
+		// Персональные токены для внешних клиентов
+		tokens := api.Group("/tokens")
+		{
+			tokens.GET("", handlers.GetPersonalTokens)
+			tokens.POST("", handlers.CreatePersonalToken)
+			tokens.DELETE("/:id", handlers.RevokePersonalToken)
+		}
+
+		// Уведомления
+		notifications := api.Group("/notifications")
+		{
+			notifications.GET("", handlers.GetNotifications)
+			notifications.GET("/preferences", handlers.GetNotificationPreferences)
+			notifications.PUT("/preferences", handlers.UpdateNotificationPreferences)
+			notifications.POST("/read-all", handlers.MarkAllNotificationsRead)
+			notifications.POST("/:id/read", handlers.MarkNotificationRead)
+			notifications.DELETE("/:id", handlers.DeleteNotification)
 		}
 
 		// Скрипты
@@ -131,12 +296,20 @@ func main() {
 		}
 	}
 
-	// Статические файлы загрузок
-	uploadsDir := "./uploads"
+	// WebDAV: хранилище как сетевой диск (Basic auth или персональный токен)
+	davGroup := router.Group(handlers.WebDAVPrefix)
+	davGroup.Use(middleware.DBMiddleware())
+	davGroup.Use(middleware.DAVAuthMiddleware())
+	for _, method := range handlers.WebDAVMethods {
+		davGroup.Handle(method, "/*path", handlers.WebDAV)
+	}
+
+	// Директория загрузок. Она не раздаётся статически: файлы доступны
+	// только через авторизованное скачивание и подписанные ссылки
+	uploadsDir := "./" + storage.UploadsDir
 	if _, err := os.Stat(uploadsDir); os.IsNotExist(err) {
 		os.MkdirAll(uploadsDir, 0755)
 	}
-	router.Static("/uploads", uploadsDir)
 
 	// Запуск сервера
 	port := os.Getenv("PORT")
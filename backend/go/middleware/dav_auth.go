@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"portfolio/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// DAVAuthMiddleware - аутентификация WebDAV-клиентов. Принимает
+// Basic auth (имя пользователя и пароль или персональный токен вместо пароля)
+// и заголовок "Bearer <персональный токен>".
+func DAVAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := c.MustGet("db").(*gorm.DB)
+
+		var user *models.User
+		if username, password, ok := c.Request.BasicAuth(); ok {
+			user = basicAuthUser(db, username, password)
+		} else if raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			user = personalTokenUser(db, raw)
+		}
+
+		if user == nil {
+			c.Header("WWW-Authenticate", `Basic realm="Portfolio WebDAV", charset="UTF-8"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Next()
+	}
+}
+
+// basicAuthUser проверяет пару логин/пароль. Вместо пароля можно
+// передать персональный токен этого пользователя.
+func basicAuthUser(db *gorm.DB, username, password string) *models.User {
+	// Токен проверяется первым: это дешевле bcrypt, а клиенты WebDAV
+	// присылают учётные данные с каждым запросом
+	if strings.HasPrefix(password, models.PersonalTokenPrefix) {
+		if user := personalTokenUser(db, password); user != nil && user.Username == username {
+			return user
+		}
+		return nil
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil
+	}
+	return &user
+}
+
+// personalTokenUser возвращает владельца действующего персонального токена
+func personalTokenUser(db *gorm.DB, raw string) *models.User {
+	var token models.PersonalToken
+	if err := db.Where("token_hash = ?", models.HashPersonalToken(raw)).First(&token).Error; err != nil {
+		return nil
+	}
+	now := time.Now()
+	if token.Expired(now) {
+		return nil
+	}
+
+	var user models.User
+	if err := db.First(&user, token.UserID).Error; err != nil {
+		return nil
+	}
+
+	db.Model(&token).UpdateColumn("last_used_at", now)
+	return &user
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// BoardColumn - колонка канбан-доски пользователя. Key хранится в
+// Task.Status; задачи в колонках с IsDone считаются выполненными.
+type BoardColumn struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_board_column_key" json:"user_id"`
+	Key       string    `gorm:"size:50;not null;uniqueIndex:idx_board_column_key" json:"key"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	IsDone    bool      `gorm:"default:false" json:"is_done"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// CalendarFeedPrefix - префикс токена iCalendar-ленты
+const CalendarFeedPrefix = "cal_"
+
+// CalendarFeed - секретная ссылка на iCalendar-ленту задач пользователя
+// для подписки из календарей. У пользователя одна лента; новый токен
+// заменяет прежний. Как и у PersonalToken, хранится только хеш токена.
+type CalendarFeed struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Hint       string     `gorm:"size:16" json:"hint"` // первые символы токена для отображения
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ChecklistItem - пункт чек-листа задачи
+type ChecklistItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TaskID    uint      `gorm:"not null;index" json:"task_id"`
+	Title     string    `gorm:"size:255;not null" json:"title"`
+	Done      bool      `gorm:"default:false" json:"done"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
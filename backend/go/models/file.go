@@ -15,9 +15,15 @@ type File struct {
 	FilePath         string         `json:"file_path"`                         // Путь на диске
 	FileSize         int64          `json:"file_size"`
 	MimeType         string         `json:"mime_type"`
-	Folder           string         `json:"folder" gorm:"default:'general'"`
+	ContentHash      string         `json:"content_hash" gorm:"size:64"`     // SHA-256 содержимого, используется как ETag
+	Version          int            `json:"version" gorm:"default:1"`        // Номер текущей версии
+	Folder           string         `json:"folder" gorm:"default:'general'"` // Полный путь папки
+	FolderID         *uint          `json:"folder_id" gorm:"index"`
 	UploadedAt       time.Time      `json:"uploaded_at"`
-	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`         // Момент перемещения в корзину
+	QuotaReleased    bool           `json:"-" gorm:"default:false"` // Место освобождено при удалении в корзину
+	WrappedKey       string         `json:"-" gorm:"size:128"`      // Ключ данных, зашифрованный мастер-ключом ("" - файл не зашифрован)
+	KeyID            string         `json:"-" gorm:"size:16;index"` // Идентификатор мастер-ключа
 }
 
 type UploadRequest struct {
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// FileContent - извлечённый текст файла для полнотекстового поиска.
+// SearchVector заполняется SQL-выражением to_tsvector при индексации.
+type FileContent struct {
+	FileID       uint      `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	Content      string    `gorm:"type:text" json:"-"`
+	SearchVector string    `gorm:"type:tsvector;index:idx_file_contents_search,type:gin" json:"-"`
+	IndexedAt    time.Time `json:"indexed_at"`
+
+	File File `gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE" json:"-"`
+}
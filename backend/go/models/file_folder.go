@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// FileFolder - папка файлового хранилища.
+// Path хранит полный путь от корня ("docs/2024/scans") и дублирует
+// цепочку ParentID, чтобы поддеревья выбирались одним запросом.
+type FileFolder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_file_folders_user_path" json:"user_id"`
+	ParentID  *uint     `gorm:"index" json:"parent_id"`
+	Name      string    `gorm:"size:255;not null" json:"name"`
+	Path      string    `gorm:"size:1000;not null;uniqueIndex:idx_file_folders_user_path" json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
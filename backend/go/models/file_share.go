@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FileShare - публичная ссылка на файл для пользователей без аккаунта
+type FileShare struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	UserID       uint           `gorm:"not null;index" json:"user_id"`
+	FileID       uint           `gorm:"not null;index" json:"file_id"`
+	Token        string         `gorm:"size:64;uniqueIndex;not null" json:"token"`
+	PasswordHash string         `gorm:"size:255" json:"-"`
+	ExpiresAt    *time.Time     `json:"expires_at"`
+	MaxDownloads int            `gorm:"default:0" json:"max_downloads"` // 0 - без ограничений
+	Downloads    int            `gorm:"default:0" json:"downloads"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"` // Момент отзыва ссылки
+
+	File File `gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// HasPassword сообщает, защищена ли ссылка паролем
+func (s *FileShare) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// Expired сообщает, истёк ли срок действия ссылки
+func (s *FileShare) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && now.After(*s.ExpiresAt)
+}
+
+// Exhausted сообщает, исчерпан ли лимит скачиваний
+func (s *FileShare) Exhausted() bool {
+	return s.MaxDownloads > 0 && s.Downloads >= s.MaxDownloads
+}
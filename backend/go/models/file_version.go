@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// FileVersion - предыдущая версия файла, сохранённая при повторной загрузке
+type FileVersion struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	FileID      uint      `gorm:"not null;uniqueIndex:idx_file_versions_file_version" json:"file_id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	Version     int       `gorm:"not null;uniqueIndex:idx_file_versions_file_version" json:"version"`
+	Filename    string    `gorm:"not null" json:"-"`
+	FilePath    string    `json:"-"`
+	FileSize    int64     `json:"file_size"`
+	MimeType    string    `json:"mime_type"`
+	ContentHash string    `gorm:"size:64" json:"content_hash"`
+	WrappedKey  string    `gorm:"size:128" json:"-"`      // см. File.WrappedKey
+	KeyID       string    `gorm:"size:16;index" json:"-"` // см. File.KeyID
+	UploadedAt  time.Time `json:"uploaded_at"`
+	CreatedAt   time.Time `json:"created_at"` // Момент, когда версия была заменена
+
+	File File `gorm:"foreignKey:FileID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "strings"
+
+// likeEscaper экранирует спецсимволы шаблона LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike экранирует спецсимволы шаблона LIKE, чтобы строка
+// сравнивалась буквально. Обратная косая черта - символ экранирования
+// PostgreSQL по умолчанию.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Типы уведомлений
+const (
+	NotificationStorageWarning = "storage_warning"
+	NotificationTaskReminder   = "task_reminder"
+	NotificationTaskAssigned   = "task_assigned"
+)
+
+// Notification - уведомление пользователя
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Kind      string     `gorm:"size:50;not null;index" json:"kind"`
+	Title     string     `gorm:"size:255;not null" json:"title"`
+	Message   string     `gorm:"type:text" json:"message"`
+	TaskID    *uint      `gorm:"index" json:"task_id,omitempty"` // задача, о которой напоминание
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PersonalTokenPrefix - префикс персональных токенов, отличающий их от JWT
+const PersonalTokenPrefix = "pat_"
+
+// PersonalToken - персональный токен доступа для внешних клиентов (WebDAV).
+// В базе хранится только SHA-256 хеш токена.
+type PersonalToken struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	UserID     uint           `gorm:"not null;index" json:"user_id"`
+	Name       string         `gorm:"size:100;not null" json:"name"`
+	TokenHash  string         `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Hint       string         `gorm:"size:16" json:"hint"` // первые символы токена для отображения
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"` // Момент отзыва токена
+}
+
+// Expired сообщает, истёк ли срок действия токена
+func (t *PersonalToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
+
+// HashPersonalToken возвращает хеш токена для поиска в базе
+func HashPersonalToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TaskReminder - напоминание о сроке задачи за MinutesBefore минут до дедлайна.
+// SentFor хранит дедлайн, о котором уже напомнили: после переноса срока
+// напоминание срабатывает снова.
+type TaskReminder struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	TaskID        uint       `gorm:"not null;uniqueIndex:idx_task_reminder_offset" json:"task_id"`
+	MinutesBefore int        `gorm:"not null;uniqueIndex:idx_task_reminder_offset" json:"minutes_before"`
+	SentFor       *time.Time `gorm:"type:timestamptz" json:"sent_for,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NotificationPreference - настройки доставки уведомлений пользователя.
+// Пока пользователь их не менял, действуют DefaultNotificationPreference.
+type NotificationPreference struct {
+	UserID         uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	InApp          bool      `json:"in_app"`
+	Email          bool      `json:"email"`
+	EmailAddress   string    `gorm:"size:255" json:"email_address"` // пусто - email пользователя
+	Webhook        bool      `json:"webhook"`
+	WebhookURL     string    `gorm:"size:500" json:"webhook_url"`
+	WebhookSecret  string    `gorm:"size:100" json:"-"`               // ключ подписи X-Signature
+	DefaultOffsets string    `gorm:"size:100" json:"default_offsets"` // минуты через запятую для новых задач со сроком
+	AllDayTime     string    `gorm:"size:5" json:"all_day_time"`      // HH:MM, от которого отсчитываются сроки на весь день
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// DefaultNotificationPreference - настройки пользователя по умолчанию:
+// уведомления в приложении за час до срока, сроки на весь день - с 09:00
+func DefaultNotificationPreference(userID uint) NotificationPreference {
+	return NotificationPreference{
+		UserID:         userID,
+		InApp:          true,
+		DefaultOffsets: "60",
+		AllDayTime:     "09:00",
+	}
+}
+
+// Offsets разбирает DefaultOffsets, пропуская некорректные значения
+func (p *NotificationPreference) Offsets() []int {
+	offsets := []int{}
+	for _, part := range strings.Split(p.DefaultOffsets, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && n >= 0 {
+			offsets = append(offsets, n)
+		}
+	}
+	return offsets
+}
+
+// AllDayMinutes возвращает AllDayTime в минутах от полуночи
+func (p *NotificationPreference) AllDayMinutes() int {
+	t, err := time.Parse("15:04", p.AllDayTime)
+	if err != nil {
+		return 9 * 60
+	}
+	return t.Hour()*60 + t.Minute()
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Tag - метка задач пользователя. Задачи и теги связаны многие-ко-многим
+// через таблицу task_tags.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_tags_user_name" json:"user_id"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:idx_tags_user_name" json:"name"` // в нижнем регистре
+	Color     string    `gorm:"size:20" json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SavedFilter - именованный фильтр задач ("умный список"). Query хранит
+// условие на языке запросов GetTasks (параметр q).
+type SavedFilter struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_saved_filters_user_name" json:"user_id"`
+	Name      string    `gorm:"size:100;not null;uniqueIndex:idx_saved_filters_user_name" json:"name"`
+	Query     string    `gorm:"size:1000;not null" json:"query"`
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
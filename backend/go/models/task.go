@@ -6,15 +6,43 @@ import (
 	"gorm.io/gorm"
 )
 
+// Приоритеты задач
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+// ValidPriority сообщает, допустимо ли значение приоритета
+func ValidPriority(priority string) bool {
+	switch priority {
+	case PriorityLow, PriorityMedium, PriorityHigh:
+		return true
+	}
+	return false
+}
+
 type Task struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
 	UserID      uint           `gorm:"not null" json:"user_id"`
 	Title       string         `gorm:"size:255;not null" json:"title"`
 	Description string         `gorm:"type:text" json:"description"`
-	Folder      string         `gorm:"size:100;default:'general'" json:"folder"`
-	Deadline    string         `gorm:"size:20" json:"deadline"`
-	Priority    string         `gorm:"size:20;default:'medium'" json:"priority"`
-	Completed   bool           `gorm:"default:false" json:"completed"`
+	Folder      string         `gorm:"size:100;default:'general'" json:"folder"` // имя папки, дублирует TaskFolder.Name
+	FolderID    *uint          `gorm:"index" json:"folder_id"`
+	Deadline    *time.Time     `gorm:"type:timestamptz;index" json:"deadline"`
+	HasTime     bool           `gorm:"default:false" json:"deadline_has_time"` // false - срок на весь день
+	Priority    string         `gorm:"size:20;default:'medium';check:chk_tasks_priority,priority IN ('low','medium','high')" json:"priority"`
+	Completed   bool           `gorm:"default:false" json:"completed"`                 // совпадает с BoardColumn.IsDone колонки Status
+	Status      string         `gorm:"size:50;index;default:'todo'" json:"status"`     // ключ колонки доски
+	Position    int            `gorm:"default:0" json:"position"`                      // порядок внутри колонки
+	Recurrence  string         `gorm:"size:255" json:"recurrence,omitempty"`           // правило RRULE
+	SeriesID    *uint          `gorm:"index" json:"series_id,omitempty"`               // первая задача серии
+	SeriesStart *time.Time     `gorm:"type:timestamptz" json:"series_start,omitempty"` // дедлайн первого повторения
+	Occurrence  int            `gorm:"default:0" json:"occurrence,omitempty"`          // номер повторения с 1
+	ParentID    *uint          `gorm:"index" json:"parent_id,omitempty"`               // родительская задача
+	Progress    int            `gorm:"default:0" json:"progress"`                      // % выполненных подзадач и пунктов чек-листа
+	AssigneeID  *uint          `gorm:"index" json:"assignee_id,omitempty"`             // исполнитель: владелец или участник общей папки
+	Tags        []Tag          `gorm:"many2many:task_tags" json:"tags,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
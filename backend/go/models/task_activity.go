@@ -0,0 +1,60 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Действия журнала задачи
+const (
+	ActivityCreated = "created"
+	ActivityUpdated = "updated"
+	ActivityStatus  = "status_changed"
+	ActivityDeleted = "deleted"
+)
+
+// FieldChange - значение поля задачи до и после изменения
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// FieldChanges - изменённые поля задачи по именам полей API; хранится в jsonb
+type FieldChanges map[string]FieldChange
+
+// Value сохраняет изменения как JSON
+func (c FieldChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	return string(data), err
+}
+
+// Scan читает изменения из jsonb
+func (c *FieldChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("unsupported FieldChanges value %T", value)
+}
+
+// TaskActivity - запись журнала изменений задачи. Журнал только
+// дополняется и переживает удаление задачи.
+type TaskActivity struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	TaskID    uint         `gorm:"not null;index" json:"task_id"`
+	UserID    uint         `gorm:"not null" json:"user_id"` // кто изменил
+	Username  string       `gorm:"->;-:migration" json:"username,omitempty"`
+	Action    string       `gorm:"size:30;not null" json:"action"`
+	Changes   FieldChanges `gorm:"type:jsonb" json:"changes,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskComment - комментарий к задаче; Body - текст в Markdown, который
+// отображает клиент
+type TaskComment struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	TaskID    uint           `gorm:"not null;index" json:"task_id"`
+	UserID    uint           `gorm:"not null" json:"user_id"` // автор
+	Username  string         `gorm:"->;-:migration" json:"username,omitempty"`
+	Body      string         `gorm:"type:text;not null" json:"body"`
+	Edited    bool           `gorm:"default:false" json:"edited"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
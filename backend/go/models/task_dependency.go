@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TaskDependency - задача TaskID заблокирована задачей BlockerID:
+// её следует выполнять после BlockerID
+type TaskDependency struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	TaskID    uint      `gorm:"not null;uniqueIndex:idx_task_dependency" json:"task_id"`
+	BlockerID uint      `gorm:"not null;uniqueIndex:idx_task_dependency;index" json:"blocker_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DefaultTaskFolder - папка, в которую попадают задачи без явно указанной
+const DefaultTaskFolder = "general"
+
+// TaskFolder - папка задач. Task.Folder дублирует Name, чтобы списки
+// задач фильтровались по имени без соединения таблиц.
+type TaskFolder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_task_folders_user_name" json:"user_id"`
+	Name      string    `gorm:"size:100;not null;uniqueIndex:idx_task_folders_user_name" json:"name"`
+	Color     string    `gorm:"size:20" json:"color"` // #rrggbb
+	Icon      string    `gorm:"size:50" json:"icon"`  // имя иконки Font Awesome, например fa-briefcase
+	Position  int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
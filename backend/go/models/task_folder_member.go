@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Права участника общей папки задач
+const (
+	FolderRoleView = "view" // только просмотр задач папки
+	FolderRoleEdit = "edit" // изменение, выполнение и удаление задач папки
+)
+
+// ValidFolderRole сообщает, допустимо ли значение прав участника
+func ValidFolderRole(role string) bool {
+	return role == FolderRoleView || role == FolderRoleEdit
+}
+
+// TaskFolderMember - пользователь, которому владелец открыл папку задач.
+// Задачи общей папки остаются задачами владельца (Task.UserID).
+type TaskFolderMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	FolderID  uint      `gorm:"not null;uniqueIndex:idx_task_folder_members_folder_user" json:"folder_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_task_folder_members_folder_user;index" json:"user_id"`
+	Username  string    `gorm:"->;-:migration" json:"username,omitempty"`
+	Role      string    `gorm:"size:10;not null;default:'view';check:chk_task_folder_members_role,role IN ('view','edit')" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Folder TaskFolder `gorm:"foreignKey:FolderID;constraint:OnDelete:CASCADE" json:"-"`
+}
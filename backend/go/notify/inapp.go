@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// InApp сохраняет уведомление в таблицу notifications
+type InApp struct {
+	DB *gorm.DB
+}
+
+// Notify создаёт запись models.Notification
+func (n *InApp) Notify(ctx context.Context, to Recipient, msg Message) error {
+	return n.DB.WithContext(ctx).Create(&models.Notification{
+		UserID:  to.UserID,
+		Kind:    msg.Kind,
+		Title:   msg.Title,
+		Message: msg.Body,
+		TaskID:  msg.TaskID,
+	}).Error
+}
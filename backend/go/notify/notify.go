@@ -0,0 +1,61 @@
+// Package notify доставляет уведомления пользователям по разным каналам:
+// в приложении, по email и через webhook.
+package notify
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Каналы доставки
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// ErrNoAddress - у получателя нет адреса для канала
+var ErrNoAddress = errors.New("recipient has no address for this channel")
+
+// Message - уведомление для доставки
+type Message struct {
+	UserID uint      `json:"user_id"`
+	Kind   string    `json:"kind"`
+	Title  string    `json:"title"`
+	Body   string    `json:"body"`
+	TaskID *uint     `json:"task_id,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Recipient - адреса получателя для внешних каналов
+type Recipient struct {
+	UserID        uint
+	Email         string
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// Notifier доставляет сообщение по одному каналу
+type Notifier interface {
+	Notify(ctx context.Context, to Recipient, msg Message) error
+}
+
+// Channels - доступные каналы по имени. Канал без настроенного Notifier
+// (например, email без SMTP_HOST) отсутствует в карте.
+type Channels map[string]Notifier
+
+// FromEnv собирает каналы доставки: в приложении и webhook доступны
+// всегда, email - при настроенном SMTP (см. SMTPFromEnv)
+func FromEnv(db *gorm.DB) Channels {
+	channels := Channels{
+		ChannelInApp:   &InApp{DB: db},
+		ChannelWebhook: NewWebhook(10 * time.Second),
+	}
+	if s := SMTPFromEnv(); s != nil {
+		channels[ChannelEmail] = s
+	}
+	return channels
+}
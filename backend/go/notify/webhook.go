@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrWebhookURL - адрес webhook должен быть абсолютным http(s) URL
+	ErrWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+	// ErrWebhookAddress - webhook указывает на внутренний адрес
+	// (loopback, частная сеть, link-local)
+	ErrWebhookAddress = errors.New("webhook URL must not point to a loopback, private or link-local address")
+)
+
+// Webhook отправляет уведомление POST-запросом с JSON-телом Message.
+// Если у получателя задан секрет, тело подписывается HMAC-SHA256 в
+// заголовке X-Signature: sha256=<hex>.
+type Webhook struct {
+	Client *http.Client
+}
+
+// NewWebhook создаёт канал webhook с таймаутом запроса. Адрес проверяется
+// при каждом соединении, уже после разрешения имени, поэтому ни
+// перенаправление, ни смена DNS-записи не приведут запрос во внутреннюю
+// сеть. Прокси из окружения не используется: через него проверка обходится.
+func NewWebhook(timeout time.Duration) *Webhook {
+	dialer := &net.Dialer{Timeout: timeout, Control: checkDialAddress}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &Webhook{Client: &http.Client{Timeout: timeout, Transport: transport}}
+}
+
+// ValidWebhookURL проверяет адрес webhook. Имя узла проверяется здесь
+// только если это IP-адрес или localhost; разрешённые имена проверяет
+// соединение (см. NewWebhook).
+func ValidWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrWebhookURL
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && internalAddr(addr) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// internalAddr сообщает, относится ли адрес к внутренней сети
+func internalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast()
+}
+
+// checkDialAddress запрещает соединение с внутренним адресом. Вызывается
+// для каждого адреса, к которому подключается dialer.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || internalAddr(addr) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// Sign возвращает значение заголовка X-Signature для тела body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify отправляет сообщение на to.WebhookURL
+func (w *Webhook) Notify(ctx context.Context, to Recipient, msg Message) error {
+	if to.WebhookURL == "" {
+		return ErrNoAddress
+	}
+	if err := ValidWebhookURL(to.WebhookURL); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, to.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if to.WebhookSecret != "" {
+		req.Header.Set("X-Signature", Sign(to.WebhookSecret, body))
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// maxCommentLength - наибольшая длина комментария в символах
+const maxCommentLength = 10000
+
+// ErrCommentBody - комментарий пустой или слишком длинный
+var ErrCommentBody = errors.New("comment must be 1-10000 characters")
+
+// Snapshot - значения отслеживаемых полей задачи в том виде, в котором
+// их принимает API: срок - YYYY-MM-DD или RFC3339 в часовом поясе
+// пользователя, теги - отсортированные имена
+type Snapshot map[string]interface{}
+
+// TakeSnapshot запоминает поля задачи для журнала. Теги задачи должны
+// быть загружены (LoadTags).
+func TakeSnapshot(task *models.Task, loc *time.Location) Snapshot {
+	tags := make([]string, 0, len(task.Tags))
+	for _, tag := range task.Tags {
+		tags = append(tags, tag.Name)
+	}
+	sort.Strings(tags)
+
+	var deadline, parent, assignee interface{}
+	if d := formatDeadline(task, loc); d != "" {
+		deadline = d
+	}
+	if task.ParentID != nil {
+		parent = *task.ParentID
+	}
+	if task.AssigneeID != nil {
+		assignee = *task.AssigneeID
+	}
+	return Snapshot{
+		"title":       task.Title,
+		"description": task.Description,
+		"folder":      task.Folder,
+		"deadline":    deadline,
+		"priority":    task.Priority,
+		"completed":   task.Completed,
+		"status":      task.Status,
+		"recurrence":  task.Recurrence,
+		"parent_id":   parent,
+		"assignee_id": assignee,
+		"tags":        tags,
+	}
+}
+
+// Diff возвращает поля, значения которых отличаются в after
+func (s Snapshot) Diff(after Snapshot) models.FieldChanges {
+	changes := models.FieldChanges{}
+	for field, to := range after {
+		if from := s[field]; !reflect.DeepEqual(from, to) {
+			changes[field] = models.FieldChange{From: from, To: to}
+		}
+	}
+	return changes
+}
+
+// values возвращает непустые поля снимка как изменения из пустого
+// значения (created) или в пустое значение (deleted)
+func (s Snapshot) values(removed bool) models.FieldChanges {
+	changes := models.FieldChanges{}
+	for field, value := range s {
+		if value == nil || reflect.ValueOf(value).IsZero() {
+			continue
+		}
+		if tags, ok := value.([]string); ok && len(tags) == 0 {
+			continue
+		}
+		if removed {
+			changes[field] = models.FieldChange{From: value}
+		} else {
+			changes[field] = models.FieldChange{To: value}
+		}
+	}
+	return changes
+}
+
+// LoadTags загружает теги задачи
+func LoadTags(tx *gorm.DB, task *models.Task) error {
+	task.Tags = nil
+	return tx.Model(task).Order("name ASC").Association("Tags").Find(&task.Tags)
+}
+
+// logActivity добавляет запись в журнал задачи
+func logActivity(tx *gorm.DB, taskID, userID uint, action string, changes models.FieldChanges) error {
+	if len(changes) == 0 {
+		changes = nil
+	}
+	return tx.Create(&models.TaskActivity{
+		TaskID:  taskID,
+		UserID:  userID,
+		Action:  action,
+		Changes: changes,
+	}).Error
+}
+
+// LogCreated записывает создание задачи со значениями её полей
+func LogCreated(tx *gorm.DB, userID uint, task *models.Task, loc *time.Location) error {
+	return logActivity(tx, task.ID, userID, models.ActivityCreated, TakeSnapshot(task, loc).values(false))
+}
+
+// LogChanges записывает изменённые с before поля задачи; если ничего не
+// изменилось, запись не добавляется
+func LogChanges(tx *gorm.DB, userID uint, action string, task *models.Task, before Snapshot, loc *time.Location) error {
+	changes := before.Diff(TakeSnapshot(task, loc))
+	if len(changes) == 0 {
+		return nil
+	}
+	return logActivity(tx, task.ID, userID, action, changes)
+}
+
+// LogDeleted записывает удаление задачи с последними значениями её полей
+func LogDeleted(tx *gorm.DB, userID uint, before Snapshot, taskID uint) error {
+	return logActivity(tx, taskID, userID, models.ActivityDeleted, before.values(true))
+}
+
+// Activity возвращает журнал задачи от новых записей к старым: не более
+// limit записей с ID меньше before (0 - с последней)
+func Activity(db *gorm.DB, taskID uint, before uint, limit int) ([]models.TaskActivity, error) {
+	query := db.Model(&models.TaskActivity{}).
+		Select("task_activities.*, users.username").
+		Joins("LEFT JOIN users ON users.id = task_activities.user_id").
+		Where("task_activities.task_id = ?", taskID)
+	if before > 0 {
+		query = query.Where("task_activities.id < ?", before)
+	}
+	entries := []models.TaskActivity{}
+	err := query.Order("task_activities.id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// NormalizeComment обрезает пробелы по краям комментария и проверяет длину
+func NormalizeComment(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		return "", ErrCommentBody
+	}
+	return body, nil
+}
+
+// Comments возвращает комментарии задачи от старых к новым с именами авторов
+func Comments(db *gorm.DB, taskID uint) ([]models.TaskComment, error) {
+	comments := []models.TaskComment{}
+	err := db.Model(&models.TaskComment{}).
+		Select("task_comments.*, users.username").
+		Joins("LEFT JOIN users ON users.id = task_comments.user_id").
+		Where("task_comments.task_id = ?", taskID).
+		Order("task_comments.id ASC").
+		Find(&comments).Error
+	return comments, err
+}
@@ -0,0 +1,397 @@
+package planner
+
+import (
+	"errors"
+	"regexp"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ключи колонок по умолчанию. Задачи, созданные до появления доски,
+// переносятся в StatusTodo или StatusDone по полю Completed.
+const (
+	StatusTodo = "todo"
+	StatusDone = "done"
+)
+
+// defaultColumns - колонки новой доски
+var defaultColumns = []models.BoardColumn{
+	{Key: StatusTodo, Name: "К выполнению"},
+	{Key: "in_progress", Name: "В работе"},
+	{Key: "review", Name: "На проверке"},
+	{Key: StatusDone, Name: "Готово", IsDone: true},
+}
+
+var (
+	// ErrColumnNotFound - колонки с таким ключом или ID нет на доске
+	ErrColumnNotFound = errors.New("board column not found")
+	// ErrColumnKey - ключ колонки не подходит под columnKeyPattern
+	ErrColumnKey = errors.New("column key must be 1-50 characters: a-z, 0-9, _")
+	// ErrColumnExists - колонка с таким ключом уже есть
+	ErrColumnExists = errors.New("board column already exists")
+	// ErrColumnRequired - на доске должна остаться хотя бы одна колонка
+	// выполненных и одна колонка невыполненных задач
+	ErrColumnRequired = errors.New("board needs at least one done and one not-done column")
+	// ErrColumnOrder - порядок должен перечислять все колонки ровно один раз
+	ErrColumnOrder = errors.New("column_ids must list every column exactly once")
+	// ErrColumnNotEmpty - is_done можно сменить только у пустой колонки:
+	// выполнение задач идёт через проверку блокирующих задач и повторения
+	ErrColumnNotEmpty = errors.New("move the tasks out of the column before changing is_done")
+	// ErrColumnTarget - задачи удаляемой колонки переносятся только в
+	// колонку того же вида (is_done), чтобы не менять их выполнение
+	ErrColumnTarget = errors.New("move_to must have the same is_done as the deleted column")
+)
+
+var columnKeyPattern = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// ValidColumnKey сообщает, подходит ли строка как ключ колонки
+func ValidColumnKey(key string) bool {
+	return columnKeyPattern.MatchString(key)
+}
+
+// LockBoard упорядочивает изменения доски и задач одного пользователя.
+// Блокировка берётся раньше блокировок строк задач: PlaceTask и изменения
+// колонок обновляют задачи, уже держа её, поэтому обратный порядок привёл
+// бы к взаимной блокировке встречных запросов.
+func LockBoard(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&models.User{}, userID).Error
+}
+
+// Columns возвращает колонки доски по порядку, создавая колонки по
+// умолчанию при первом обращении
+func Columns(tx *gorm.DB, userID uint) ([]models.BoardColumn, error) {
+	var columns []models.BoardColumn
+	if err := tx.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&columns).Error; err != nil {
+		return nil, err
+	}
+	if len(columns) > 0 {
+		return columns, nil
+	}
+
+	columns = make([]models.BoardColumn, len(defaultColumns))
+	for i, column := range defaultColumns {
+		column.UserID = userID
+		column.Position = i
+		columns[i] = column
+	}
+	// Параллельный запрос мог успеть создать колонки
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&columns).Error; err != nil {
+		return nil, err
+	}
+	columns = nil
+	err := tx.Where("user_id = ?", userID).Order("position ASC, id ASC").Find(&columns).Error
+	return columns, err
+}
+
+// FindColumn ищет колонку по ключу
+func FindColumn(columns []models.BoardColumn, key string) (*models.BoardColumn, error) {
+	for i := range columns {
+		if columns[i].Key == key {
+			return &columns[i], nil
+		}
+	}
+	return nil, ErrColumnNotFound
+}
+
+// CompletionColumn возвращает первую колонку выполненных (completed) или
+// невыполненных задач - в неё попадает задача при смене Completed
+func CompletionColumn(columns []models.BoardColumn, completed bool) (*models.BoardColumn, error) {
+	for i := range columns {
+		if columns[i].IsDone == completed {
+			return &columns[i], nil
+		}
+	}
+	return nil, ErrColumnRequired
+}
+
+// OwnerColumn переводит колонку key доски участника общей папки в колонку
+// доски владельца: с тем же ключом и признаком выполненности, а если такой
+// нет - в первую колонку того же вида
+func OwnerColumn(member, owner []models.BoardColumn, key string) (*models.BoardColumn, error) {
+	column, err := FindColumn(member, key)
+	if err != nil {
+		return nil, err
+	}
+	if target, err := FindColumn(owner, key); err == nil && target.IsDone == column.IsDone {
+		return target, nil
+	}
+	return CompletionColumn(owner, column.IsDone)
+}
+
+// PlaceTask ставит задачу в колонку column на позицию position (с 0;
+// отрицательная или слишком большая - в конец), перенумеровывая задачи
+// прежней и новой колонок. Status, Position и Completed задачи
+// сохраняются сразу; остальные поля не трогаются.
+func PlaceTask(tx *gorm.DB, task *models.Task, column *models.BoardColumn, position int) error {
+	if err := LockBoard(tx, task.UserID); err != nil {
+		return err
+	}
+
+	ids, err := columnTaskIDs(tx, task.UserID, column.Key, task.ID)
+	if err != nil {
+		return err
+	}
+	if position < 0 || position > len(ids) {
+		position = len(ids)
+	}
+	ids = append(ids[:position], append([]uint{task.ID}, ids[position:]...)...)
+
+	oldStatus := task.Status
+	task.Status = column.Key
+	task.Position = position
+	task.Completed = column.IsDone
+	if err := tx.Model(task).UpdateColumns(map[string]interface{}{
+		"status":    task.Status,
+		"position":  task.Position,
+		"completed": task.Completed,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := renumber(tx, ids); err != nil {
+		return err
+	}
+	if oldStatus != "" && oldStatus != column.Key {
+		rest, err := columnTaskIDs(tx, task.UserID, oldStatus, task.ID)
+		if err != nil {
+			return err
+		}
+		return renumber(tx, rest)
+	}
+	return nil
+}
+
+// AppendTask ставит задачу в конец колонки status; пустой ключ означает
+// первую колонку невыполненных задач
+func AppendTask(tx *gorm.DB, task *models.Task, status string) error {
+	columns, err := Columns(tx, task.UserID)
+	if err != nil {
+		return err
+	}
+	var column *models.BoardColumn
+	if status == "" {
+		column, err = CompletionColumn(columns, false)
+	} else {
+		column, err = FindColumn(columns, status)
+	}
+	if err != nil {
+		return err
+	}
+	return PlaceTask(tx, task, column, -1)
+}
+
+// SetCompleted - совместимость с булевым статусом: задача, чья колонка не
+// соответствует completed, переносится в конец CompletionColumn
+func SetCompleted(tx *gorm.DB, task *models.Task, completed bool) error {
+	columns, err := Columns(tx, task.UserID)
+	if err != nil {
+		return err
+	}
+	if current, err := FindColumn(columns, task.Status); err == nil && current.IsDone == completed {
+		task.Completed = completed
+		return nil
+	}
+	column, err := CompletionColumn(columns, completed)
+	if err != nil {
+		return err
+	}
+	return PlaceTask(tx, task, column, -1)
+}
+
+// columnTaskIDs возвращает задачи колонки по порядку, кроме exclude
+func columnTaskIDs(tx *gorm.DB, userID uint, status string, exclude uint) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&models.Task{}).
+		Where("user_id = ? AND status = ? AND id <> ?", userID, status, exclude).
+		Order("position ASC, id ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// renumber записывает задачам позиции по порядку ids
+func renumber(tx *gorm.DB, ids []uint) error {
+	for pos, id := range ids {
+		if err := tx.Model(&models.Task{}).Where("id = ? AND position <> ?", id, pos).
+			UpdateColumn("position", pos).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddColumn добавляет колонку в позицию position (отрицательная - в конец)
+func AddColumn(tx *gorm.DB, userID uint, column *models.BoardColumn, position int) error {
+	if !ValidColumnKey(column.Key) {
+		return ErrColumnKey
+	}
+	if err := LockBoard(tx, userID); err != nil {
+		return err
+	}
+	columns, err := Columns(tx, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := FindColumn(columns, column.Key); err == nil {
+		return ErrColumnExists
+	}
+
+	column.UserID = userID
+	if err := tx.Create(column).Error; err != nil {
+		return err
+	}
+
+	if position < 0 || position > len(columns) {
+		position = len(columns)
+	}
+	ids := make([]uint, 0, len(columns)+1)
+	for _, c := range columns {
+		ids = append(ids, c.ID)
+	}
+	ids = append(ids[:position], append([]uint{column.ID}, ids[position:]...)...)
+	column.Position = position
+	return ReorderColumns(tx, userID, ids)
+}
+
+// ReorderColumns задаёт порядок колонок: ids перечисляет все колонки доски
+func ReorderColumns(tx *gorm.DB, userID uint, ids []uint) error {
+	if err := LockBoard(tx, userID); err != nil {
+		return err
+	}
+	var existing []uint
+	if err := tx.Model(&models.BoardColumn{}).Where("user_id = ?", userID).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	if len(existing) != len(ids) {
+		return ErrColumnOrder
+	}
+	known := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+	for pos, id := range ids {
+		if !known[id] {
+			return ErrColumnOrder
+		}
+		delete(known, id)
+		if err := tx.Model(&models.BoardColumn{}).Where("id = ?", id).UpdateColumn("position", pos).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateColumn меняет название колонки и признак выполненности. IsDone
+// меняется только у пустой колонки (ErrColumnNotEmpty).
+func UpdateColumn(tx *gorm.DB, column *models.BoardColumn, name string, isDone *bool) error {
+	if err := LockBoard(tx, column.UserID); err != nil {
+		return err
+	}
+	if name != "" {
+		column.Name = name
+	}
+	if isDone != nil && *isDone != column.IsDone {
+		columns, err := Columns(tx, column.UserID)
+		if err != nil {
+			return err
+		}
+		if !keepsBoardUsable(columns, column.ID, isDone) {
+			return ErrColumnRequired
+		}
+		tasks, err := columnTaskIDs(tx, column.UserID, column.Key, 0)
+		if err != nil {
+			return err
+		}
+		if len(tasks) > 0 {
+			return ErrColumnNotEmpty
+		}
+		column.IsDone = *isDone
+	}
+	return tx.Save(column).Error
+}
+
+// DeleteColumn удаляет колонку, перенося её задачи в конец колонки target
+// того же вида (IsDone): выполнение задач при этом не меняется
+func DeleteColumn(tx *gorm.DB, column *models.BoardColumn, targetKey string) error {
+	if err := LockBoard(tx, column.UserID); err != nil {
+		return err
+	}
+	columns, err := Columns(tx, column.UserID)
+	if err != nil {
+		return err
+	}
+	if !keepsBoardUsable(columns, column.ID, nil) {
+		return ErrColumnRequired
+	}
+	target, err := FindColumn(columns, targetKey)
+	if err != nil || target.ID == column.ID {
+		return ErrColumnNotFound
+	}
+	if target.IsDone != column.IsDone {
+		return ErrColumnTarget
+	}
+
+	// Задачи встают после задач целевой колонки в прежнем порядке
+	moved, err := columnTaskIDs(tx, column.UserID, column.Key, 0)
+	if err != nil {
+		return err
+	}
+	if len(moved) > 0 {
+		if err := tx.Model(&models.Task{}).Where("id IN ?", moved).
+			UpdateColumn("status", target.Key).Error; err != nil {
+			return err
+		}
+		ids, err := columnTaskIDs(tx, column.UserID, target.Key, 0)
+		if err != nil {
+			return err
+		}
+		ordered := make([]uint, 0, len(ids))
+		isMoved := make(map[uint]bool, len(moved))
+		for _, id := range moved {
+			isMoved[id] = true
+		}
+		for _, id := range ids {
+			if !isMoved[id] {
+				ordered = append(ordered, id)
+			}
+		}
+		if err := renumber(tx, append(ordered, moved...)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Delete(column).Error; err != nil {
+		return err
+	}
+	var ids []uint
+	for _, c := range columns {
+		if c.ID != column.ID {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ReorderColumns(tx, column.UserID, ids)
+}
+
+// keepsBoardUsable проверяет, что после удаления колонки id (isDone == nil)
+// или смены её IsDone на доске останутся колонки обоих видов
+func keepsBoardUsable(columns []models.BoardColumn, id uint, isDone *bool) bool {
+	done, open := 0, 0
+	for _, c := range columns {
+		value := c.IsDone
+		if c.ID == id {
+			if isDone == nil {
+				continue
+			}
+			value = *isDone
+		}
+		if value {
+			done++
+		} else {
+			open++
+		}
+	}
+	return done > 0 && open > 0
+}
@@ -0,0 +1,102 @@
+package planner
+
+import (
+	"errors"
+	"time"
+	// База часовых поясов встроена: в контейнере её может не быть
+	_ "time/tzdata"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// Форматы дедлайна во вводе: дата (срок на весь день), дата со временем
+// в часовом поясе пользователя или RFC3339 с явным смещением
+const (
+	DateLayout     = "2006-01-02"
+	DateTimeLayout = "2006-01-02T15:04"
+)
+
+// ErrInvalidDeadline - дедлайн не разобран ни в одном из форматов
+var ErrInvalidDeadline = errors.New("invalid deadline: expected YYYY-MM-DD, YYYY-MM-DDTHH:MM or RFC3339")
+
+// ParseDeadline разбирает дедлайн в часовом поясе loc. hasTime равен false
+// для срока на весь день: он хранится как полночь этого дня в loc.
+func ParseDeadline(s string, loc *time.Location) (t time.Time, hasTime bool, err error) {
+	if t, err := time.ParseInLocation(DateLayout, s, loc); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation(DateTimeLayout, s, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), true, nil
+	}
+	return time.Time{}, false, ErrInvalidDeadline
+}
+
+// SetDeadline задаёт задаче дедлайн из строки ввода (пустая строка снимает срок)
+func SetDeadline(task *models.Task, s string, loc *time.Location) error {
+	if s == "" {
+		task.Deadline = nil
+		task.HasTime = false
+		return nil
+	}
+	t, hasTime, err := ParseDeadline(s, loc)
+	if err != nil {
+		return err
+	}
+	task.Deadline = &t
+	task.HasTime = hasTime
+	return nil
+}
+
+// DayStart возвращает полночь дня t в часовом поясе loc
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// UserLocation загружает часовой пояс пользователя
+func UserLocation(db *gorm.DB, userID uint) (*time.Location, error) {
+	var user models.User
+	if err := db.Select("id", "timezone").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return user.Location(), nil
+}
+
+// Localize переводит дедлайны задачи в часовой пояс loc, чтобы в ответе
+// API дата совпадала с календарной датой пользователя
+func Localize(task *models.Task, loc *time.Location) {
+	if task.Deadline != nil {
+		t := task.Deadline.In(loc)
+		task.Deadline = &t
+	}
+	if task.SeriesStart != nil {
+		t := task.SeriesStart.In(loc)
+		task.SeriesStart = &t
+	}
+}
+
+// DueScope отбирает задачи по сроку относительно now в часовом поясе loc:
+// today - срок сегодня, overdue - невыполненные с истёкшим сроком.
+// Срок на весь день истекает с окончанием дня.
+func DueScope(due string, now time.Time, loc *time.Location) (func(*gorm.DB) *gorm.DB, error) {
+	today := DayStart(now, loc)
+	tomorrow := today.AddDate(0, 0, 1)
+
+	switch due {
+	case "today":
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where("deadline >= ? AND deadline < ?", today, tomorrow)
+		}, nil
+	case "overdue":
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where("completed = ? AND ((has_time AND deadline < ?) OR (NOT has_time AND deadline < ?))",
+				false, now, today)
+		}, nil
+	}
+	return nil, errors.New("due must be today or overdue")
+}
@@ -0,0 +1,333 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTaskNotFound - задача не найдена у пользователя
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrDependencySelf - задача не может блокировать саму себя
+	ErrDependencySelf = errors.New("task cannot block itself")
+	// ErrDependencyCycle - зависимость замкнула бы цикл
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrDependencyExists - такая зависимость уже есть
+	ErrDependencyExists = errors.New("dependency already exists")
+	// ErrDependencyOwner - зависимость связывает задачи разных владельцев
+	ErrDependencyOwner = errors.New("tasks of different owners cannot depend on each other")
+)
+
+// BlockedError - задачу нельзя выполнить, пока открыты блокирующие задачи
+type BlockedError struct {
+	Blockers []models.Task
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("task is blocked by %d open task(s)", len(e.Blockers))
+}
+
+// AddDependency отмечает, что taskID заблокирована blockerID. Обе задачи
+// должны быть видны пользователю и принадлежать одному владельцу, taskID -
+// доступна пользователю для изменения; цикл в графе зависимостей не
+// допускается. Зависимость записывается на владельца задач.
+func AddDependency(tx *gorm.DB, userID, taskID, blockerID uint) (*models.TaskDependency, error) {
+	if taskID == blockerID {
+		return nil, ErrDependencySelf
+	}
+
+	var tasks []models.Task
+	if err := tx.Scopes(VisibleTo(userID)).
+		Where("id IN ?", []uint{taskID, blockerID}).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(tasks) != 2 {
+		return nil, ErrTaskNotFound
+	}
+	task := &tasks[0]
+	if task.ID != taskID {
+		task = &tasks[1]
+	}
+	if tasks[0].UserID != tasks[1].UserID {
+		return nil, ErrDependencyOwner
+	}
+	if err := CanEdit(tx, task, userID); err != nil {
+		return nil, err
+	}
+	ownerID := task.UserID
+
+	// Зависимости владельца меняются последовательно, иначе две встречные
+	// вставки могли бы вместе замкнуть цикл
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&models.User{}, ownerID).Error; err != nil {
+		return nil, err
+	}
+
+	// Ребро blocker -> task замыкает цикл, если blocker уже достижим из task
+	reachable, err := blockedClosure(tx, ownerID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if reachable[blockerID] {
+		return nil, ErrDependencyCycle
+	}
+
+	dependency := models.TaskDependency{UserID: ownerID, TaskID: taskID, BlockerID: blockerID}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&dependency)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDependencyExists
+	}
+	return &dependency, nil
+}
+
+// blockedClosure возвращает задачи, прямо или косвенно заблокированные
+// taskID. Зависимости связывают только задачи одного владельца, поэтому
+// обход ограничен его зависимостями.
+func blockedClosure(tx *gorm.DB, ownerID, taskID uint) (map[uint]bool, error) {
+	seen := map[uint]bool{}
+	level := []uint{taskID}
+	for len(level) > 0 {
+		var next []uint
+		if err := tx.Model(&models.TaskDependency{}).
+			Where("user_id = ? AND blocker_id IN ?", ownerID, level).
+			Pluck("task_id", &next).Error; err != nil {
+			return nil, err
+		}
+		level = level[:0]
+		for _, id := range next {
+			if !seen[id] {
+				seen[id] = true
+				level = append(level, id)
+			}
+		}
+	}
+	return seen, nil
+}
+
+// OpenBlockers возвращает невыполненные задачи, блокирующие taskID
+func OpenBlockers(tx *gorm.DB, taskID uint) ([]models.Task, error) {
+	var blockers []models.Task
+	err := tx.Where("completed = ? AND id IN (?)", false,
+		tx.Model(&models.TaskDependency{}).Select("blocker_id").Where("task_id = ?", taskID)).
+		Order("id ASC").
+		Find(&blockers).Error
+	return blockers, err
+}
+
+// RemoveDependencies удаляет зависимости, в которых участвуют задачи ids
+func RemoveDependencies(tx *gorm.DB, ids []uint) error {
+	return tx.Where("task_id IN ? OR blocker_id IN ?", ids, ids).Delete(&models.TaskDependency{}).Error
+}
+
+// GraphNode - задача в графе зависимостей
+type GraphNode struct {
+	ID        uint       `json:"id"`
+	Title     string     `json:"title"`
+	Completed bool       `json:"completed"`
+	Deadline  *time.Time `json:"deadline"`
+	Priority  string     `json:"priority"`
+	// Blocked - у задачи есть невыполненные блокирующие задачи
+	Blocked bool `json:"blocked"`
+}
+
+// GraphEdge - зависимость: From блокирует To
+type GraphEdge struct {
+	From uint `json:"from"`
+	To   uint `json:"to"`
+}
+
+// DependencyGraph - граф зависимостей и порядок выполнения задач
+type DependencyGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+	// Order - топологический порядок: каждая задача идёт после всех
+	// блокирующих. Среди доступных раньше идут задачи с ближайшим сроком.
+	Order []uint `json:"order"`
+}
+
+// Graph строит граф зависимостей задач, видимых пользователю (своих и из
+// общих папок). Если taskID не 0, граф ограничивается связной компонентой
+// этой задачи.
+func Graph(db *gorm.DB, userID, taskID uint, loc *time.Location) (*DependencyGraph, error) {
+	// Связи с удалёнными и невидимыми пользователю задачами не учитываются
+	var ids []uint
+	if err := db.Model(&models.Task{}).Scopes(VisibleTo(userID)).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	var dependencies []models.TaskDependency
+	if err := db.Where("task_id IN ?", ids).Order("id ASC").Find(&dependencies).Error; err != nil {
+		return nil, err
+	}
+	alive := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		alive[id] = true
+	}
+
+	neighbours := map[uint][]uint{}
+	var edges []GraphEdge
+	for _, d := range dependencies {
+		if !alive[d.TaskID] || !alive[d.BlockerID] {
+			continue
+		}
+		edges = append(edges, GraphEdge{From: d.BlockerID, To: d.TaskID})
+		neighbours[d.BlockerID] = append(neighbours[d.BlockerID], d.TaskID)
+		neighbours[d.TaskID] = append(neighbours[d.TaskID], d.BlockerID)
+	}
+
+	// Узлы графа - задачи, участвующие в зависимостях (или компонента taskID)
+	members := map[uint]bool{}
+	if taskID != 0 {
+		if !alive[taskID] {
+			return nil, ErrTaskNotFound
+		}
+		members[taskID] = true
+		queue := []uint{taskID}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, n := range neighbours[id] {
+				if !members[n] {
+					members[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+		filtered := edges[:0]
+		for _, e := range edges {
+			if members[e.From] {
+				filtered = append(filtered, e)
+			}
+		}
+		edges = filtered
+	} else {
+		for id := range neighbours {
+			members[id] = true
+		}
+	}
+
+	memberIDs := make([]uint, 0, len(members))
+	for id := range members {
+		memberIDs = append(memberIDs, id)
+	}
+	var tasks []models.Task
+	if len(memberIDs) > 0 {
+		if err := db.Where("id IN ?", memberIDs).Find(&tasks).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	byID := make(map[uint]*models.Task, len(tasks))
+	for i := range tasks {
+		Localize(&tasks[i], loc)
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	order, err := topologicalOrder(tasks, edges)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &DependencyGraph{Nodes: make([]GraphNode, 0, len(order)), Edges: edges, Order: order}
+	if graph.Edges == nil {
+		graph.Edges = []GraphEdge{}
+	}
+	blocked := map[uint]bool{}
+	for _, e := range edges {
+		if !byID[e.From].Completed {
+			blocked[e.To] = true
+		}
+	}
+	for _, id := range order {
+		t := byID[id]
+		graph.Nodes = append(graph.Nodes, GraphNode{
+			ID:        t.ID,
+			Title:     t.Title,
+			Completed: t.Completed,
+			Deadline:  t.Deadline,
+			Priority:  t.Priority,
+			Blocked:   blocked[t.ID],
+		})
+	}
+	return graph, nil
+}
+
+// topologicalOrder упорядочивает задачи алгоритмом Кана
+func topologicalOrder(tasks []models.Task, edges []GraphEdge) ([]uint, error) {
+	indegree := make(map[uint]int, len(tasks))
+	successors := map[uint][]uint{}
+	for _, e := range edges {
+		indegree[e.To]++
+		successors[e.From] = append(successors[e.From], e.To)
+	}
+
+	byID := make(map[uint]*models.Task, len(tasks))
+	var ready []*models.Task
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+		if indegree[tasks[i].ID] == 0 {
+			ready = append(ready, &tasks[i])
+		}
+	}
+
+	order := make([]uint, 0, len(tasks))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return runsBefore(ready[i], ready[j]) })
+		task := ready[0]
+		ready = ready[1:]
+		order = append(order, task.ID)
+
+		for _, id := range successors[task.ID] {
+			indegree[id]--
+			if indegree[id] == 0 {
+				ready = append(ready, byID[id])
+			}
+		}
+	}
+
+	if len(order) != len(tasks) {
+		return nil, ErrDependencyCycle
+	}
+	return order, nil
+}
+
+// runsBefore - порядок среди доступных задач: сначала с ближайшим сроком,
+// затем более важные, затем созданные раньше
+func runsBefore(a, b *models.Task) bool {
+	switch {
+	case a.Deadline != nil && b.Deadline == nil:
+		return true
+	case a.Deadline == nil && b.Deadline != nil:
+		return false
+	case a.Deadline != nil && !a.Deadline.Equal(*b.Deadline):
+		return a.Deadline.Before(*b.Deadline)
+	}
+	if ra, rb := PriorityRank(a.Priority), PriorityRank(b.Priority); ra != rb {
+		return ra > rb
+	}
+	return a.ID < b.ID
+}
+
+// PriorityRank - вес приоритета для сравнения (чем важнее, тем больше)
+func PriorityRank(priority string) int {
+	switch priority {
+	case models.PriorityHigh:
+		return 3
+	case models.PriorityMedium:
+		return 2
+	case models.PriorityLow:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package planner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio/models"
+)
+
+// todoistDateLayouts - форматы поля DATE экспорта Todoist, которые удаётся
+// разобрать без языка DATE_LANG
+var todoistDateLayouts = []struct {
+	layout  string
+	hasTime bool
+}{
+	{DateLayout, false},
+	{DateTimeLayout, true},
+	{"2006-01-02 15:04", true},
+	{"2006-01-02T15:04:05", true},
+	{"Jan 2 2006", false},
+	{"Jan 2 2006 15:04", true},
+	{"2 Jan 2006", false},
+	{"2 Jan 2006 15:04", true},
+	{"02.01.2006", false},
+	{"02.01.2006 15:04", true},
+}
+
+// ParseTodoist читает CSV-экспорт проекта Todoist (колонки TYPE, CONTENT,
+// DESCRIPTION, PRIORITY, INDENT, DATE, TIMEZONE). Разделы (section)
+// становятся папками следующих за ними задач, комментарии (note)
+// дописываются к описанию задачи, метки @label в тексте - тегами.
+// PRIORITY 1 (p1) означает высокий приоритет, 2 - средний, 3 - низкий,
+// 4 (без приоритета) - средний. Повторяющиеся и словесные сроки
+// ("every monday", "tomorrow") не переносятся.
+func ParseTodoist(r io.Reader, loc *time.Location) ([]ImportedTask, error) {
+	rows, err := readCSV(r, "content")
+	if err != nil {
+		return nil, err
+	}
+
+	var items []ImportedTask
+	section := ""
+	for _, row := range rows {
+		content := strings.TrimSpace(row["content"])
+		switch strings.ToLower(strings.TrimSpace(row["type"])) {
+		case "section":
+			section = content
+		case "note":
+			if len(items) > 0 && content != "" {
+				last := &items[len(items)-1]
+				if last.Description != "" {
+					last.Description += "\n\n"
+				}
+				last.Description += content
+			}
+		case "task":
+			item := ImportedTask{
+				Description: row["description"],
+				Folder:      section,
+				Priority:    todoistPriority(row["priority"]),
+			}
+			item.Title, item.Tags = todoistLabels(content)
+			if indent, _ := strconv.Atoi(row["indent"]); indent > 1 {
+				item.warn("subtask imported as a top-level task")
+			}
+			if date := strings.TrimSpace(row["date"]); date != "" {
+				todoistDeadline(&item, date, row["timezone"], loc)
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// todoistPriority переводит PRIORITY Todoist в приоритет задачи
+func todoistPriority(value string) string {
+	switch strings.TrimSpace(value) {
+	case "1":
+		return models.PriorityHigh
+	case "3":
+		return models.PriorityLow
+	}
+	return models.PriorityMedium
+}
+
+// todoistLabels убирает из текста задачи метки @label и возвращает их
+func todoistLabels(content string) (string, []string) {
+	var words, labels []string
+	for _, word := range strings.Fields(content) {
+		if strings.HasPrefix(word, "@") && len(word) > 1 {
+			labels = append(labels, word[1:])
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), labels
+}
+
+// todoistDeadline разбирает срок Todoist в часовом поясе TIMEZONE
+// (или loc); неразобранный срок становится предупреждением
+func todoistDeadline(item *ImportedTask, date, timezone string, loc *time.Location) {
+	zone := loc
+	if timezone != "" {
+		if l, err := time.LoadLocation(strings.TrimSpace(timezone)); err == nil {
+			zone = l
+		}
+	}
+	for _, f := range todoistDateLayouts {
+		if t, err := time.ParseInLocation(f.layout, date, zone); err == nil {
+			if !f.hasTime {
+				// Срок на весь день хранится полночью в поясе пользователя
+				t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+			}
+			t = t.In(loc)
+			item.Deadline, item.HasTime = &t, f.hasTime
+			return
+		}
+	}
+	item.warn(fmt.Sprintf("deadline %q not recognized, task imported without it", date))
+}
+
+// trelloBoard - нужная часть JSON-экспорта доски Trello
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		Name        string  `json:"name"`
+		Desc        string  `json:"desc"`
+		Due         *string `json:"due"`
+		DueComplete bool    `json:"dueComplete"`
+		Closed      bool    `json:"closed"`
+		IDList      string  `json:"idList"`
+		Labels      []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+}
+
+// ParseTrello читает JSON-экспорт доски Trello. Карточки попадают в папку
+// с именем доски; список карточки сопоставляется с колонкой доски по ключу
+// или имени, иначе выполненность берётся из dueComplete. Метки становятся
+// тегами (метка без имени - по цвету). Архивные карточки и карточки
+// архивных списков пропускаются.
+func ParseTrello(r io.Reader, loc *time.Location) ([]ImportedTask, error) {
+	var board trelloBoard
+	if err := json.NewDecoder(skipBOM(r)).Decode(&board); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if board.Cards == nil {
+		return nil, fmt.Errorf("%w: not a Trello board export (no cards)", ErrInvalidImport)
+	}
+
+	lists := make(map[string]string, len(board.Lists))
+	closed := make(map[string]bool)
+	for _, list := range board.Lists {
+		lists[list.ID] = list.Name
+		closed[list.ID] = list.Closed
+	}
+
+	items := make([]ImportedTask, 0, len(board.Cards))
+	for _, card := range board.Cards {
+		item := ImportedTask{
+			Title:       card.Name,
+			Description: card.Desc,
+			Folder:      board.Name,
+			Completed:   card.DueComplete,
+			Status:      lists[card.IDList],
+		}
+		if card.Closed || closed[card.IDList] {
+			item.Skip = "archived in Trello"
+		}
+		for _, label := range card.Labels {
+			name := label.Name
+			if name == "" {
+				name = label.Color
+			}
+			item.Tags = append(item.Tags, name)
+		}
+		if card.Due != nil && *card.Due != "" {
+			if t, err := time.Parse(time.RFC3339, *card.Due); err == nil {
+				t = t.In(loc)
+				item.Deadline, item.HasTime = &t, true
+			} else {
+				item.warn(fmt.Sprintf("deadline %q not recognized, task imported without it", *card.Due))
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
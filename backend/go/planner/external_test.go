@@ -0,0 +1,135 @@
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"portfolio/models"
+)
+
+func TestParseTodoist(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	data := "\ufeffTYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\r\n" +
+		"note,Orphan note,,,,,,,,\r\n" +
+		"task,Buy milk @home @errands,Lactose free,1,1,,,2024-03-31,en,Europe/Berlin\r\n" +
+		"note,Second shop is closed on Sunday,,,,,,,,\r\n" +
+		"section,Work\r\n" +
+		"task,Call Bob,,4,2,,,2024-03-10 09:00,en,America/New_York\r\n" +
+		"task,Weekly sync,,3,1,,,every monday,en,\r\n" +
+		"task,Short row\r\n" +
+		",\r\n"
+
+	items, err := ParseTodoist(strings.NewReader(data), berlin)
+	if err != nil {
+		t.Fatalf("ParseTodoist: %v", err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("got %d items, want 4", len(items))
+	}
+
+	milk := items[0]
+	if milk.Title != "Buy milk" || !reflect.DeepEqual(milk.Tags, []string{"home", "errands"}) {
+		t.Errorf("labels: title %q tags %q", milk.Title, milk.Tags)
+	}
+	if milk.Description != "Lactose free\n\nSecond shop is closed on Sunday" {
+		t.Errorf("description with note = %q", milk.Description)
+	}
+	if milk.Priority != models.PriorityHigh || milk.Folder != "" || milk.HasTime ||
+		milk.Deadline == nil || !milk.Deadline.Equal(time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)) {
+		t.Errorf("item = %+v", milk)
+	}
+
+	call := items[1]
+	if call.Folder != "Work" || call.Priority != models.PriorityMedium || call.Warning == "" {
+		t.Errorf("subtask in section = %+v, want folder Work and a warning", call)
+	}
+	if want := time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC); call.Deadline == nil || !call.Deadline.Equal(want) || !call.HasTime {
+		t.Errorf("deadline in TIMEZONE = %v, want %v", call.Deadline, want)
+	}
+
+	if sync := items[2]; sync.Deadline != nil || sync.Priority != models.PriorityLow || !strings.Contains(sync.Warning, "every monday") {
+		t.Errorf("recurring date = %+v, want no deadline and a warning", sync)
+	}
+	if short := items[3]; short.Title != "Short row" || short.Folder != "Work" || short.Deadline != nil || short.Warning != "" {
+		t.Errorf("short row = %+v", short)
+	}
+}
+
+func TestParseTodoistMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":         "",
+		"no content":    "TYPE,TITLE\ntask,x\n",
+		"header only":   "TYPE,PRIORITY\n",
+		"trello export": `{"name": "Board", "cards": []}`,
+	} {
+		if _, err := ParseTodoist(strings.NewReader(data), time.UTC); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%s: error = %v, want ErrInvalidImport", name, err)
+		}
+	}
+}
+
+func TestParseTrello(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	data := "\ufeff" + `{
+		"name": "Project",
+		"lists": [
+			{"id": "l1", "name": "Doing"},
+			{"id": "l2", "name": "Old", "closed": true}
+		],
+		"cards": [
+			{"name": "Card", "desc": "Text", "idList": "l1", "due": "2024-03-31T08:00:00.000Z", "dueComplete": true,
+				"labels": [{"name": "Urgent", "color": "red"}, {"name": "", "color": "green"}]},
+			{"name": "Archived", "idList": "l1", "closed": true},
+			{"name": "In archived list", "idList": "l2"},
+			{"name": "Bad due", "idList": "missing", "due": "next week"},
+			{"name": "No due", "due": null}
+		]
+	}`
+
+	items, err := ParseTrello(strings.NewReader(data), berlin)
+	if err != nil {
+		t.Fatalf("ParseTrello: %v", err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("got %d items, want 5", len(items))
+	}
+
+	card := items[0]
+	if card.Title != "Card" || card.Description != "Text" || card.Folder != "Project" || card.Status != "Doing" || !card.Completed {
+		t.Errorf("card = %+v", card)
+	}
+	if !reflect.DeepEqual(card.Tags, []string{"Urgent", "green"}) {
+		t.Errorf("tags = %q, want the color for an unnamed label", card.Tags)
+	}
+	if want := time.Date(2024, 3, 31, 10, 0, 0, 0, berlin); card.Deadline == nil || !card.Deadline.Equal(want) || !card.HasTime {
+		t.Errorf("deadline = %v, want %v", card.Deadline, want)
+	}
+
+	if items[1].Skip == "" || items[2].Skip == "" {
+		t.Errorf("archived cards not skipped: %+v, %+v", items[1], items[2])
+	}
+	if bad := items[3]; bad.Skip != "" || bad.Deadline != nil || bad.Warning == "" || bad.Status != "" {
+		t.Errorf("card with bad due = %+v, want a warning", bad)
+	}
+	if none := items[4]; none.Deadline != nil || none.Warning != "" {
+		t.Errorf("card without due = %+v", none)
+	}
+}
+
+func TestParseTrelloMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":      "",
+		"truncated":  `{"name": "Board", "cards": [{"name": "x"`,
+		"no cards":   `{"name": "Board", "lists": []}`,
+		"wrong type": `{"cards": {"name": "x"}}`,
+		"csv":        "TYPE,CONTENT\ntask,x\n",
+		"bad due":    `{"cards": [{"name": "x", "due": 5}]}`,
+	} {
+		if _, err := ParseTrello(strings.NewReader(data), time.UTC); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%s: error = %v, want ErrInvalidImport", name, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidQuery - запрос на языке фильтров не разобран
+var ErrInvalidQuery = errors.New("invalid filter query")
+
+// Filter - условие отбора задач. Все заданные поля объединяются через И;
+// значения внутри списка - через ИЛИ, кроме Tags: задача должна иметь
+// все перечисленные теги.
+//
+// Язык запросов (параметр q и сохранённые фильтры) записывает фильтр
+// строкой из слов, разделённых пробелами:
+//
+//	tag:work #urgent          теги (можно повторять)
+//	priority:high,medium      приоритеты
+//	folder:"Дом и быт"        папки по имени
+//	status:in_progress        колонки доски
+//	is:done, is:open          выполненные / невыполненные
+//	due:today, due:overdue    срок сегодня / просрочено
+//	due:2024-05-01            срок в этот день
+//	due>=2024-05-01 due<=...  границы срока (включительно)
+//
+// Остальные слова ищутся в названии и описании задачи.
+type Filter struct {
+	Text       string   `json:"text,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Priorities []string `json:"priorities,omitempty"`
+	Folders    []string `json:"folders,omitempty"`
+	Statuses   []string `json:"statuses,omitempty"`
+	Completed  *bool    `json:"completed,omitempty"`
+	Due        string   `json:"due,omitempty"`      // today или overdue
+	DueFrom    string   `json:"due_from,omitempty"` // YYYY-MM-DD в часовом поясе пользователя
+	DueTo      string   `json:"due_to,omitempty"`   // YYYY-MM-DD, включительно
+}
+
+// ParseQuery разбирает строку языка запросов
+func ParseQuery(s string) (*Filter, error) {
+	words, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Filter{}
+	var text []string
+	for _, word := range words {
+		if strings.HasPrefix(word, "#") && len(word) > 1 {
+			f.Tags = append(f.Tags, word[1:])
+			continue
+		}
+		for _, op := range []string{">=", "<="} {
+			if strings.HasPrefix(word, "due"+op) {
+				value, err := unquote(strings.TrimPrefix(word, "due"+op))
+				if err != nil {
+					return nil, err
+				}
+				if op == ">=" {
+					f.DueFrom = value
+				} else {
+					f.DueTo = value
+				}
+				word = ""
+			}
+		}
+		if word == "" {
+			continue
+		}
+
+		key, raw, found := strings.Cut(word, ":")
+		if !found || raw == "" || strings.HasPrefix(word, `"`) {
+			value, err := unquote(word)
+			if err != nil {
+				return nil, err
+			}
+			text = append(text, value)
+			continue
+		}
+		value, err := unquote(raw)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(key) {
+		case "tag":
+			f.Tags = append(f.Tags, value)
+		case "priority":
+			f.Priorities = append(f.Priorities, strings.Split(value, ",")...)
+		case "folder":
+			f.Folders = append(f.Folders, value)
+		case "status":
+			f.Statuses = append(f.Statuses, strings.Split(value, ",")...)
+		case "is":
+			switch value {
+			case "done", "completed":
+				f.Completed = boolPtr(true)
+			case "open":
+				f.Completed = boolPtr(false)
+			default:
+				return nil, fmt.Errorf("%w: unknown is:%s", ErrInvalidQuery, value)
+			}
+		case "due":
+			if value == "today" || value == "overdue" {
+				f.Due = value
+			} else {
+				f.DueFrom, f.DueTo = value, value
+			}
+		default:
+			text = append(text, word)
+		}
+	}
+	f.Text = strings.Join(text, " ")
+
+	if err := f.Normalize(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// splitQuery делит строку на слова по пробелам вне двойных кавычек
+func splitQuery(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	quoted, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(r)
+	}
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// unquote снимает кавычки со значения в кавычках
+func unquote(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad quoted value %s", ErrInvalidQuery, s)
+	}
+	return value, nil
+}
+
+// quote берёт значение в кавычки, если без них оно не прочитается обратно
+// (в том числе слово вида due>=..., которое иначе станет границей срока)
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, "\" \t\n:#<>") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// Normalize проверяет значения фильтра и приводит теги к нижнему регистру
+func (f *Filter) Normalize() error {
+	for i, tag := range f.Tags {
+		name, err := NormalizeTag(tag)
+		if err != nil {
+			return err
+		}
+		f.Tags[i] = name
+	}
+	for _, p := range f.Priorities {
+		if !models.ValidPriority(p) {
+			return fmt.Errorf("%w: priority must be low, medium or high", ErrInvalidQuery)
+		}
+	}
+	if f.Due != "" && f.Due != "today" && f.Due != "overdue" {
+		return fmt.Errorf("%w: due must be today or overdue", ErrInvalidQuery)
+	}
+	for _, day := range []string{f.DueFrom, f.DueTo} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(DateLayout, day); err != nil {
+			return fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidQuery)
+		}
+	}
+	f.Text = strings.TrimSpace(f.Text)
+	return nil
+}
+
+// String записывает фильтр на языке запросов; ParseQuery(f.String())
+// возвращает равный фильтр
+func (f *Filter) String() string {
+	var words []string
+	for _, tag := range f.Tags {
+		words = append(words, "tag:"+quote(tag))
+	}
+	if len(f.Priorities) > 0 {
+		words = append(words, "priority:"+strings.Join(f.Priorities, ","))
+	}
+	for _, folder := range f.Folders {
+		words = append(words, "folder:"+quote(folder))
+	}
+	for _, status := range f.Statuses {
+		words = append(words, "status:"+quote(status))
+	}
+	if f.Completed != nil {
+		if *f.Completed {
+			words = append(words, "is:done")
+		} else {
+			words = append(words, "is:open")
+		}
+	}
+	if f.Due != "" {
+		words = append(words, "due:"+f.Due)
+	}
+	if f.DueFrom != "" && f.DueFrom == f.DueTo {
+		words = append(words, "due:"+f.DueFrom)
+	} else {
+		if f.DueFrom != "" {
+			words = append(words, "due>="+f.DueFrom)
+		}
+		if f.DueTo != "" {
+			words = append(words, "due<="+f.DueTo)
+		}
+	}
+	for _, word := range strings.Fields(f.Text) {
+		words = append(words, quote(word))
+	}
+	return strings.Join(words, " ")
+}
+
+// Scope возвращает условие отбора для запроса к tasks. Даты считаются в
+// часовом поясе loc, due - относительно now. Фильтр должен пройти Normalize.
+func (f *Filter) Scope(now time.Time, loc *time.Location) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(f.Tags) > 0 {
+			db = db.Where(`id IN (SELECT task_tags.task_id FROM task_tags
+				JOIN tags ON tags.id = task_tags.tag_id
+				WHERE tags.user_id = tasks.user_id AND tags.name IN ?
+				GROUP BY task_tags.task_id HAVING COUNT(DISTINCT tags.id) = ?)`, f.Tags, len(uniqueStrings(f.Tags)))
+		}
+		if len(f.Priorities) > 0 {
+			db = db.Where("priority IN ?", f.Priorities)
+		}
+		if len(f.Folders) > 0 {
+			db = db.Where("folder IN ?", f.Folders)
+		}
+		if len(f.Statuses) > 0 {
+			db = db.Where("status IN ?", f.Statuses)
+		}
+		if f.Completed != nil {
+			db = db.Where("completed = ?", *f.Completed)
+		}
+		if f.Due != "" {
+			if scope, err := DueScope(f.Due, now, loc); err == nil {
+				db = db.Scopes(scope)
+			}
+		}
+		// Границы - календарные дни пользователя, DueTo включает весь день
+		if day, err := time.ParseInLocation(DateLayout, f.DueFrom, loc); err == nil {
+			db = db.Where("deadline >= ?", day)
+		}
+		if day, err := time.ParseInLocation(DateLayout, f.DueTo, loc); err == nil {
+			db = db.Where("deadline < ?", day.AddDate(0, 0, 1))
+		}
+		for _, word := range strings.Fields(f.Text) {
+			like := "%" + models.EscapeLike(word) + "%"
+			db = db.Where("(title ILIKE ? OR description ILIKE ?)", like, like)
+		}
+		return db
+	}
+}
+
+// uniqueStrings возвращает значения без повторов
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,182 @@
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"portfolio/models"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Filter
+	}{
+		{"", Filter{}},
+		{"tag:Work #urgent", Filter{Tags: []string{"work", "urgent"}}},
+		{"TAG:work PRIORITY:high,medium", Filter{Tags: []string{"work"}, Priorities: []string{"high", "medium"}}},
+		{`folder:"Дом и быт" status:todo,in_progress`, Filter{Folders: []string{"Дом и быт"}, Statuses: []string{"todo", "in_progress"}}},
+		{"is:done", Filter{Completed: boolPtr(true)}},
+		{"is:completed", Filter{Completed: boolPtr(true)}},
+		{"is:open", Filter{Completed: boolPtr(false)}},
+		{"due:today", Filter{Due: "today"}},
+		{"due:overdue", Filter{Due: "overdue"}},
+		{"due:2024-05-01", Filter{DueFrom: "2024-05-01", DueTo: "2024-05-01"}},
+		{"due>=2024-05-01", Filter{DueFrom: "2024-05-01"}},
+		{"due<=2024-05-31", Filter{DueTo: "2024-05-31"}},
+		{`due>="2024-05-01" due<=2024-05-31`, Filter{DueFrom: "2024-05-01", DueTo: "2024-05-31"}},
+		{"  купить   молоко  ", Filter{Text: "купить молоко"}},
+		{`"tag:work" notes:`, Filter{Text: "tag:work notes:"}},
+		{`"say \"hi\""`, Filter{Text: `say "hi"`}},
+		{"url:http://x #", Filter{Text: "url:http://x #"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuery(tt.in)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.in, *got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryInvalid(t *testing.T) {
+	for _, in := range []string{
+		`folder:"Дом`,
+		`"unterminated`,
+		`folder:"bad \q escape"`,
+		"is:maybe",
+		"priority:urgent",
+		"due:tomorrow",
+		"due>=2024-13-01",
+		"due<=01.05.2024",
+		"tag:no.dots",
+	} {
+		if _, err := ParseQuery(in); err == nil {
+			t.Errorf("ParseQuery(%q) succeeded, want an error", in)
+		}
+	}
+	if _, err := ParseQuery("is:maybe"); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("ParseQuery(is:maybe) error = %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestFilterStringRoundTrip(t *testing.T) {
+	tests := []Filter{
+		{},
+		{Tags: []string{"work", "дом"}},
+		{Priorities: []string{"high", "low"}},
+		{Folders: []string{"Дом и быт", "a:b", "#hash", `quote"d`}},
+		{Statuses: []string{"in_progress", "на проверке"}},
+		{Completed: boolPtr(true)},
+		{Completed: boolPtr(false)},
+		{Due: "overdue"},
+		{DueFrom: "2024-05-01", DueTo: "2024-05-01"},
+		{DueFrom: "2024-05-01"},
+		{DueTo: "2024-05-31"},
+		{DueFrom: "2024-05-01", DueTo: "2024-05-31"},
+		{Text: "купить молоко"},
+		{Text: `tag:work #urgent "quoted" is:done`},
+		{Text: "due>=2024-05-01 due<=soon"},
+		{Text: `back\slash tab	inside`},
+		{
+			Text:       "report",
+			Tags:       []string{"work"},
+			Priorities: []string{"high"},
+			Folders:    []string{"Работа"},
+			Statuses:   []string{"review"},
+			Completed:  boolPtr(false),
+			Due:        "today",
+			DueFrom:    "2024-01-01",
+			DueTo:      "2024-12-31",
+		},
+	}
+	for _, f := range tests {
+		want := f
+		if want.Text != "" {
+			want.Text = strings.Join(strings.Fields(want.Text), " ")
+		}
+		s := f.String()
+		got, err := ParseQuery(s)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) of %+v: %v", s, f, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("round trip of %+v through %q = %+v", f, s, *got)
+		}
+	}
+}
+
+// scopeSQL возвращает SQL и параметры запроса задач с фильтром f без
+// подключения к базе
+func scopeSQL(t *testing.T, f *Filter, now time.Time, loc *time.Location) (string, []interface{}) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	var tasks []models.Task
+	stmt := db.Scopes(f.Scope(now, loc)).Find(&tasks).Statement
+	return stmt.SQL.String(), stmt.Vars
+}
+
+func TestFilterScopeTags(t *testing.T) {
+	f := &Filter{Tags: []string{"work", "urgent", "work"}}
+	sql, vars := scopeSQL(t, f, time.Now(), time.UTC)
+
+	for _, part := range []string{
+		"tags.user_id = tasks.user_id",
+		"tags.name IN ($1,$2,$3)",
+		"GROUP BY task_tags.task_id HAVING COUNT(DISTINCT tags.id) = $4",
+	} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("SQL %q does not contain %q", sql, part)
+		}
+	}
+	// Повтор тега не должен требовать двух разных тегов с одним именем
+	if len(vars) != 4 || vars[3] != 2 {
+		t.Errorf("vars = %v, want tag names and a count of 2", vars)
+	}
+}
+
+func TestFilterScopeDueBounds(t *testing.T) {
+	loc := mustLocation(t, "Europe/Berlin")
+	f, err := ParseQuery("due>=2024-03-30 due<=2024-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, vars := scopeSQL(t, f, time.Now(), loc)
+
+	if !strings.Contains(sql, "deadline >= $1") || !strings.Contains(sql, "deadline < $2") {
+		t.Fatalf("SQL %q lacks deadline bounds", sql)
+	}
+	from := time.Date(2024, 3, 30, 0, 0, 0, 0, loc)
+	to := time.Date(2024, 4, 1, 0, 0, 0, 0, loc)
+	if len(vars) != 2 || !vars[0].(time.Time).Equal(from) || !vars[1].(time.Time).Equal(to) {
+		t.Errorf("vars = %v, want [%s %s)", vars, from, to)
+	}
+	// Последний день включается целиком, хотя короче на час
+	if got := to.Sub(from); got != 47*time.Hour {
+		t.Errorf("range = %v, want 47h over the DST switch", got)
+	}
+}
+
+func TestFilterScopeText(t *testing.T) {
+	sql, vars := scopeSQL(t, &Filter{Text: `50%_off a\b`}, time.Now(), time.UTC)
+	if strings.Count(sql, "ILIKE") != 4 {
+		t.Errorf("SQL %q, want two ILIKE pairs", sql)
+	}
+	want := []interface{}{`%50\%\_off%`, `%50\%\_off%`, `%a\\b%`, `%a\\b%`}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("vars = %q, want %q", vars, want)
+	}
+}
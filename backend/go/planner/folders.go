@@ -0,0 +1,240 @@
+package planner
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrFolderNotFound - папки нет у пользователя
+	ErrFolderNotFound = errors.New("folder not found")
+	// ErrFolderName - пустое, слишком длинное или зарезервированное имя
+	ErrFolderName = errors.New("folder name must be 1-100 characters and not \"all\"")
+	// ErrFolderExists - папка с таким именем уже есть
+	ErrFolderExists = errors.New("folder already exists")
+	// ErrFolderColor - цвет не в формате #rrggbb
+	ErrFolderColor = errors.New("color must be #rrggbb")
+	// ErrFolderOrder - порядок должен перечислять все папки ровно один раз
+	ErrFolderOrder = errors.New("folder_ids must list every folder exactly once")
+	// ErrFolderTarget - задачи нельзя перенести в удаляемую папку
+	ErrFolderTarget = errors.New("target folder must differ from the deleted one")
+)
+
+var folderColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// NormalizeFolderName обрезает пробелы и проверяет имя папки. Имя "all"
+// зарезервировано: так фильтр списка задач обозначает все папки.
+func NormalizeFolderName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 || name == "all" {
+		return "", ErrFolderName
+	}
+	return name, nil
+}
+
+// ValidFolderColor сообщает, подходит ли строка как цвет папки (пустая -
+// цвет по умолчанию)
+func ValidFolderColor(color string) bool {
+	return color == "" || folderColorPattern.MatchString(color)
+}
+
+// FolderByID загружает папку пользователя
+func FolderByID(tx *gorm.DB, userID, id uint) (*models.TaskFolder, error) {
+	var folder models.TaskFolder
+	err := tx.Where("id = ? AND user_id = ?", id, userID).First(&folder).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFolderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// EnsureFolder возвращает папку с именем name, создавая её в конце списка
+func EnsureFolder(tx *gorm.DB, userID uint, name string) (*models.TaskFolder, error) {
+	var folder models.TaskFolder
+	err := tx.Where("user_id = ? AND name = ?", userID, name).First(&folder).Error
+	if err == nil {
+		return &folder, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.TaskFolder{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	folder = models.TaskFolder{UserID: userID, Name: name, Position: int(count)}
+	// Параллельный запрос мог успеть создать папку
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&folder).Error; err != nil {
+		return nil, err
+	}
+	if folder.ID == 0 {
+		err = tx.Where("user_id = ? AND name = ?", userID, name).First(&folder).Error
+	}
+	return &folder, err
+}
+
+// ResolveFolder находит папку задачи: по id, если он задан, иначе по
+// имени (пустое имя - models.DefaultTaskFolder). Папка с новым именем
+// создаётся.
+func ResolveFolder(tx *gorm.DB, userID uint, id *uint, name string) (*models.TaskFolder, error) {
+	if id != nil {
+		return FolderByID(tx, userID, *id)
+	}
+	if strings.TrimSpace(name) == "" {
+		return EnsureFolder(tx, userID, models.DefaultTaskFolder)
+	}
+	name, err := NormalizeFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+	return EnsureFolder(tx, userID, name)
+}
+
+// SetFolder помещает задачу в папку (без сохранения)
+func SetFolder(task *models.Task, folder *models.TaskFolder) {
+	task.FolderID = &folder.ID
+	task.Folder = folder.Name
+}
+
+// CreateFolder создаёт папку в конце списка
+func CreateFolder(tx *gorm.DB, folder *models.TaskFolder) error {
+	name, err := NormalizeFolderName(folder.Name)
+	if err != nil {
+		return err
+	}
+	if !ValidFolderColor(folder.Color) {
+		return ErrFolderColor
+	}
+
+	var count int64
+	if err := tx.Model(&models.TaskFolder{}).Where("user_id = ?", folder.UserID).Count(&count).Error; err != nil {
+		return err
+	}
+	folder.Name = name
+	folder.Position = int(count)
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(folder)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrFolderExists
+	}
+	return nil
+}
+
+// RenameFolder переименовывает папку и обновляет имя у её задач
+func RenameFolder(tx *gorm.DB, folder *models.TaskFolder, name string) error {
+	name, err := NormalizeFolderName(name)
+	if err != nil {
+		return err
+	}
+	if name == folder.Name {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.TaskFolder{}).
+		Where("user_id = ? AND name = ? AND id <> ?", folder.UserID, name, folder.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrFolderExists
+	}
+
+	folder.Name = name
+	if err := tx.Model(folder).UpdateColumn("name", name).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&models.Task{}).Where("folder_id = ?", folder.ID).
+		UpdateColumn("folder", name).Error
+}
+
+// ReorderFolders задаёт порядок папок: ids перечисляет все папки пользователя
+func ReorderFolders(tx *gorm.DB, userID uint, ids []uint) error {
+	var existing []uint
+	if err := tx.Model(&models.TaskFolder{}).Where("user_id = ?", userID).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	if len(existing) != len(ids) {
+		return ErrFolderOrder
+	}
+	known := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return ErrFolderOrder
+		}
+		delete(known, id)
+	}
+
+	for pos, id := range ids {
+		if err := tx.Model(&models.TaskFolder{}).Where("id = ?", id).UpdateColumn("position", pos).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteFolder удаляет папку. При cascade её задачи удаляются вместе с
+// подзадачами, иначе переносятся в target (nil - models.DefaultTaskFolder).
+// Возвращает число перенесённых и удалённых задач.
+func DeleteFolder(tx *gorm.DB, folder *models.TaskFolder, cascade bool, target *models.TaskFolder) (moved, deleted int64, err error) {
+	var ids []uint
+	if err := tx.Model(&models.Task{}).Where("folder_id = ?", folder.ID).Pluck("id", &ids).Error; err != nil {
+		return 0, 0, err
+	}
+
+	// Участники папки теряют доступ к её задачам и перестают быть исполнителями
+	if err := tx.Unscoped().Model(&models.Task{}).Where("folder_id = ? AND assignee_id <> user_id", folder.ID).
+		UpdateColumn("assignee_id", nil).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Where("folder_id = ?", folder.ID).Delete(&models.TaskFolderMember{}).Error; err != nil {
+		return 0, 0, err
+	}
+
+	if cascade {
+		if deleted, err = DeleteTasks(tx, folder.UserID, ids); err != nil {
+			return 0, 0, err
+		}
+	} else {
+		if target == nil {
+			if target, err = EnsureFolder(tx, folder.UserID, models.DefaultTaskFolder); err != nil {
+				return 0, 0, err
+			}
+		}
+		if target.ID == folder.ID {
+			return 0, 0, ErrFolderTarget
+		}
+		result := tx.Unscoped().Model(&models.Task{}).Where("folder_id = ?", folder.ID).
+			UpdateColumns(map[string]interface{}{"folder_id": target.ID, "folder": target.Name})
+		if result.Error != nil {
+			return 0, 0, result.Error
+		}
+		moved = int64(len(ids))
+	}
+
+	if err := tx.Delete(folder).Error; err != nil {
+		return 0, 0, err
+	}
+	var rest []uint
+	if err := tx.Model(&models.TaskFolder{}).Where("user_id = ?", folder.UserID).
+		Order("position ASC, id ASC").Pluck("id", &rest).Error; err != nil {
+		return 0, 0, err
+	}
+	return moved, deleted, ReorderFolders(tx, folder.UserID, rest)
+}
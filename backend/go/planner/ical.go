@@ -0,0 +1,405 @@
+package planner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"portfolio/models"
+)
+
+// Виды компонентов iCalendar-ленты: VTODO понимают планировщики задач,
+// VEVENT - календари, которые задачи не показывают (Google Calendar)
+const (
+	CalendarTodo  = "todo"
+	CalendarEvent = "event"
+)
+
+// Форматы дат iCalendar (RFC 5545, 3.3.4 и 3.3.5)
+const (
+	icalDate     = "20060102"
+	icalDateTime = "20060102T150405"
+	icalUTC      = "20060102T150405Z"
+)
+
+// icalLineLimit - длина строки в октетах, после которой строка переносится
+const icalLineLimit = 75
+
+// ErrInvalidCalendar - данные не похожи на iCalendar
+var ErrInvalidCalendar = errors.New("invalid iCalendar data")
+
+// CalendarOptions - параметры ленты задач
+type CalendarOptions struct {
+	Name     string         // X-WR-CALNAME
+	Kind     string         // CalendarTodo или CalendarEvent
+	Domain   string         // правая часть UID: task-ID@Domain
+	Location *time.Location // часовой пояс пользователя: в нём считаются сроки на весь день
+}
+
+// WriteCalendar записывает задачи со сроком в формате iCalendar.
+// Задачи без срока пропускаются.
+func WriteCalendar(w io.Writer, tasks []models.Task, opts CalendarOptions) error {
+	out := &icalWriter{w: bufio.NewWriter(w)}
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", "-//portfolio//tasks//RU")
+	out.line("CALSCALE", "GREGORIAN")
+	out.line("METHOD", "PUBLISH")
+	if opts.Name != "" {
+		out.line("X-WR-CALNAME", escapeText(opts.Name))
+	}
+	out.line("X-WR-TIMEZONE", opts.Location.String())
+	out.line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	out.line("X-PUBLISHED-TTL", "PT1H")
+
+	for i := range tasks {
+		if tasks[i].Deadline != nil {
+			writeTask(out, &tasks[i], opts)
+		}
+	}
+
+	out.line("END", "VCALENDAR")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// writeTask записывает задачу компонентом VTODO или VEVENT
+func writeTask(out *icalWriter, task *models.Task, opts CalendarOptions) {
+	component := "VTODO"
+	if opts.Kind == CalendarEvent {
+		component = "VEVENT"
+	}
+	deadline := task.Deadline.In(opts.Location)
+
+	out.line("BEGIN", component)
+	out.line("UID", fmt.Sprintf("task-%d@%s", task.ID, opts.Domain))
+	out.line("DTSTAMP", task.UpdatedAt.UTC().Format(icalUTC))
+	out.line("CREATED", task.CreatedAt.UTC().Format(icalUTC))
+	out.line("LAST-MODIFIED", task.UpdatedAt.UTC().Format(icalUTC))
+
+	summary := task.Title
+	if component == "VEVENT" && task.Completed {
+		summary = "✓ " + summary
+	}
+	out.line("SUMMARY", escapeText(summary))
+	if task.Description != "" {
+		out.line("DESCRIPTION", escapeText(task.Description))
+	}
+
+	if component == "VTODO" {
+		if task.HasTime {
+			out.line("DUE", deadline.UTC().Format(icalUTC))
+		} else {
+			out.line("DUE;VALUE=DATE", deadline.Format(icalDate))
+		}
+		if task.Completed {
+			out.line("STATUS", "COMPLETED")
+			out.line("COMPLETED", task.UpdatedAt.UTC().Format(icalUTC))
+		} else {
+			out.line("STATUS", "NEEDS-ACTION")
+		}
+		if task.Progress > 0 {
+			out.line("PERCENT-COMPLETE", strconv.Itoa(task.Progress))
+		}
+	} else {
+		if task.HasTime {
+			out.line("DTSTART", deadline.UTC().Format(icalUTC))
+		} else {
+			out.line("DTSTART;VALUE=DATE", deadline.Format(icalDate))
+			out.line("DTEND;VALUE=DATE", deadline.AddDate(0, 0, 1).Format(icalDate))
+		}
+		out.line("TRANSP", "TRANSPARENT")
+	}
+
+	out.line("PRIORITY", strconv.Itoa(icalPriority(task.Priority)))
+	if len(task.Tags) > 0 {
+		names := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			names[i] = escapeText(tag.Name)
+		}
+		out.line("CATEGORIES", strings.Join(names, ","))
+	}
+	out.line("END", component)
+}
+
+// icalPriority переводит приоритет задачи в шкалу PRIORITY (1 - высший, 9 - низший)
+func icalPriority(priority string) int {
+	switch priority {
+	case models.PriorityHigh:
+		return 1
+	case models.PriorityLow:
+		return 9
+	}
+	return 5
+}
+
+// taskPriority переводит PRIORITY в приоритет задачи; 0 означает "не задан"
+func taskPriority(value string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	switch {
+	case err != nil || n == 0 || n == 5:
+		return models.PriorityMedium
+	case n < 5:
+		return models.PriorityHigh
+	}
+	return models.PriorityLow
+}
+
+// icalWriter пишет строки содержимого с переносом длинных строк.
+// Первая ошибка записи запоминается, следующие строки не пишутся.
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (out *icalWriter) line(name, value string) {
+	if out.err != nil {
+		return
+	}
+	s := name + ":" + value
+	// Продолжение начинается с пробела, он входит в длину строки
+	for limit := icalLineLimit; len(s) > limit; limit = icalLineLimit - 1 {
+		// Перенос не должен разрезать многобайтовый символ
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, out.err = out.w.WriteString(s[:cut] + "\r\n "); out.err != nil {
+			return
+		}
+		s = s[cut:]
+	}
+	_, out.err = out.w.WriteString(s + "\r\n")
+}
+
+// escapeText экранирует значение типа TEXT
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// unescapeText снимает экранирование значения типа TEXT
+func unescapeText(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r == 'n' || r == 'N' {
+				b.WriteRune('\n')
+			} else {
+				b.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitText делит список значений TEXT по неэкранированным запятым
+func splitText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, unescapeText(s[start:]))
+}
+
+// icalProperty - строка содержимого: NAME;PARAM=value:VALUE
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// parseProperty разбирает строку содержимого. Двоеточие и точка с
+// запятой внутри параметров в кавычках не считаются разделителями.
+func parseProperty(line string) (icalProperty, bool) {
+	prop := icalProperty{Params: map[string]string{}}
+	quoted := false
+	start := 0
+	var parts []string
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ';' || c == ':'):
+			parts = append(parts, line[start:i])
+			start = i + 1
+			if c == ':' {
+				prop.Value = line[start:]
+				if len(parts) == 0 || parts[0] == "" {
+					return prop, false
+				}
+				prop.Name = strings.ToUpper(parts[0])
+				for _, param := range parts[1:] {
+					key, value, _ := strings.Cut(param, "=")
+					prop.Params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+				}
+				return prop, true
+			}
+		}
+	}
+	return prop, false
+}
+
+// unfoldLines читает строки содержимого, склеивая перенесённые
+func unfoldLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseICalTime разбирает значение DATE или DATE-TIME. Время без TZID и
+// без "Z" ("плавающее"), как и TZID с неизвестным именем пояса,
+// считается в часовом поясе loc.
+func parseICalTime(prop icalProperty, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.Value)
+	if prop.Params["VALUE"] == "DATE" || len(value) == len(icalDate) {
+		t, err := time.ParseInLocation(icalDate, value, loc)
+		return t, false, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalUTC, value)
+		return t.In(loc), true, err
+	}
+	zone := loc
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			zone = l
+		}
+	}
+	t, err := time.ParseInLocation(icalDateTime, value, zone)
+	return t.In(loc), true, err
+}
+
+// ParseCalendar читает задачи из компонентов VTODO и VEVENT.
+// Срок берётся из DUE, а без него - из DTSTART; PRIORITY 1-4 означает
+// высокий приоритет, 6-9 - низкий, остальное - средний. Задача выполнена
+// при STATUS:COMPLETED, свойстве COMPLETED или PERCENT-COMPLETE:100.
+// CATEGORIES становятся тегами. Сроки читаются в часовом поясе loc.
+func ParseCalendar(r io.Reader, loc *time.Location) ([]ImportedTask, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+	if len(lines) > 0 {
+		lines[0] = strings.TrimPrefix(lines[0], "\ufeff")
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, ErrInvalidCalendar
+	}
+
+	var (
+		items   []ImportedTask
+		stack   []string
+		current *ImportedTask
+		due     *icalProperty
+		start   *icalProperty
+	)
+	for _, line := range lines {
+		prop, ok := parseProperty(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: bad line %q", ErrInvalidCalendar, truncateRunes(line, 40))
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			name := strings.ToUpper(prop.Value)
+			stack = append(stack, name)
+			if (name == "VTODO" || name == "VEVENT") && len(stack) == 2 {
+				current = &ImportedTask{}
+				due, start = nil, nil
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("%w: unexpected END:%s", ErrInvalidCalendar, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+			if current != nil && len(stack) == 1 {
+				deadline := due
+				if deadline == nil {
+					deadline = start
+				}
+				if deadline != nil {
+					t, hasTime, err := parseICalTime(*deadline, loc)
+					if err != nil {
+						return nil, fmt.Errorf("%w: bad date %q", ErrInvalidCalendar, deadline.Value)
+					}
+					current.Deadline, current.HasTime = &t, hasTime
+				}
+				items = append(items, *current)
+				current = nil
+				if len(items) > maxImportTasks {
+					return nil, ErrTooManyTasks
+				}
+			}
+			continue
+		}
+
+		// Свойства вложенных компонентов (VALARM) не относятся к задаче
+		if current == nil || len(stack) != 2 {
+			continue
+		}
+		switch prop.Name {
+		case "SUMMARY":
+			current.Title = unescapeText(prop.Value)
+		case "DESCRIPTION":
+			current.Description = unescapeText(prop.Value)
+		case "DUE":
+			p := prop
+			due = &p
+		case "DTSTART":
+			p := prop
+			start = &p
+		case "PRIORITY":
+			current.Priority = taskPriority(prop.Value)
+		case "STATUS":
+			if strings.EqualFold(prop.Value, "COMPLETED") {
+				current.Completed = true
+			}
+		case "COMPLETED":
+			current.Completed = true
+		case "PERCENT-COMPLETE":
+			if strings.TrimSpace(prop.Value) == "100" {
+				current.Completed = true
+			}
+		case "CATEGORIES":
+			current.Tags = append(current.Tags, splitText(prop.Value)...)
+		}
+	}
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("%w: unterminated %s", ErrInvalidCalendar, stack[len(stack)-1])
+	}
+	return items, nil
+}
@@ -0,0 +1,137 @@
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"portfolio/models"
+)
+
+// calendar собирает iCalendar из строк с переводами строк CRLF
+func calendar(lines ...string) string {
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestParseCalendar(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	data := "\ufeff" + calendar(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		`SUMMARY:Buy milk\, bread`,
+		`DESCRIPTION:line one\nline two`,
+		"DUE;TZID=America/New_York:20240310T090000",
+		"DTSTART:20240301T090000Z",
+		"PRIORITY:1",
+		`CATEGORIES:home,errands\,shops`,
+		"STATUS:COMPLETED",
+		"BEGIN:VALARM",
+		"SUMMARY:alarm text",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"SUMMARY:Long",
+		"  folded title",
+		"DTSTART;VALUE=DATE:20240331",
+		"PRIORITY:9",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		`DUE;TZID="Mars/Olympus Mons":20240310T090000`,
+		"PERCENT-COMPLETE:100",
+		"END:VTODO",
+		"END:VCALENDAR",
+	)
+
+	items, err := ParseCalendar(strings.NewReader(data), berlin)
+	if err != nil {
+		t.Fatalf("ParseCalendar: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+
+	todo := items[0]
+	if todo.Title != "Buy milk, bread" || todo.Description != "line one\nline two" {
+		t.Errorf("text = %q / %q", todo.Title, todo.Description)
+	}
+	// DUE важнее DTSTART; 9:00 в Нью-Йорке после перехода на летнее время
+	if want := time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC); todo.Deadline == nil || !todo.Deadline.Equal(want) || !todo.HasTime {
+		t.Errorf("deadline = %v (time %v), want %v", todo.Deadline, todo.HasTime, want)
+	}
+	if todo.Deadline != nil && todo.Deadline.Location() != berlin {
+		t.Errorf("deadline location = %v, want %v", todo.Deadline.Location(), berlin)
+	}
+	if todo.Priority != models.PriorityHigh || !todo.Completed {
+		t.Errorf("priority %q completed %v, want high and completed", todo.Priority, todo.Completed)
+	}
+	if want := []string{"home", "errands,shops"}; !reflect.DeepEqual(todo.Tags, want) {
+		t.Errorf("tags = %q, want %q", todo.Tags, want)
+	}
+
+	event := items[1]
+	if event.Title != "Long folded title" {
+		t.Errorf("folded title = %q", event.Title)
+	}
+	if want := time.Date(2024, 3, 31, 0, 0, 0, 0, berlin); event.Deadline == nil || !event.Deadline.Equal(want) || event.HasTime {
+		t.Errorf("all-day deadline = %v (time %v), want %v", event.Deadline, event.HasTime, want)
+	}
+	if event.Priority != models.PriorityLow || event.Completed {
+		t.Errorf("priority %q completed %v, want low and open", event.Priority, event.Completed)
+	}
+
+	// Неизвестный TZID читается в поясе пользователя
+	unknown := items[2]
+	if want := time.Date(2024, 3, 10, 9, 0, 0, 0, berlin); unknown.Deadline == nil || !unknown.Deadline.Equal(want) {
+		t.Errorf("deadline with unknown TZID = %v, want %v", unknown.Deadline, want)
+	}
+	if !unknown.Completed || unknown.Title != "" {
+		t.Errorf("item = %+v, want completed without title", unknown)
+	}
+}
+
+func TestParseCalendarMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"blank lines", "\r\n\r\n"},
+		{"not a calendar", calendar("BEGIN:VTODO", "SUMMARY:x", "END:VTODO")},
+		{"html", "<html><body>Not found</body></html>"},
+		{"unterminated calendar", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", "SUMMARY:x", "END:VTODO")},
+		{"unterminated component", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", "SUMMARY:x", "END:VCALENDAR")},
+		{"unterminated alarm", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", "BEGIN:VALARM", "END:VTODO", "END:VCALENDAR")},
+		{"end without begin", calendar("BEGIN:VCALENDAR", "END:VTODO", "END:VCALENDAR")},
+		{"content after end", calendar("BEGIN:VCALENDAR", "END:VCALENDAR", "END:VCALENDAR")},
+		{"line without colon", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", "SUMMARY", "END:VTODO", "END:VCALENDAR")},
+		{"line without name", calendar("BEGIN:VCALENDAR", ":value", "END:VCALENDAR")},
+		{"unterminated quoted parameter", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", `DUE;TZID="Europe/Berlin:20240101T090000`, "END:VTODO", "END:VCALENDAR")},
+		{"bad date", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", "DUE:2024-03-10", "END:VTODO", "END:VCALENDAR")},
+		{"bad date with TZID", calendar("BEGIN:VCALENDAR", "BEGIN:VTODO", "DUE;TZID=Europe/Berlin:20241340T090000", "END:VTODO", "END:VCALENDAR")},
+		{"bad UTC time", calendar("BEGIN:VCALENDAR", "BEGIN:VEVENT", "DTSTART:20240101T250000Z", "END:VEVENT", "END:VCALENDAR")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := ParseCalendar(strings.NewReader(tt.data), time.UTC)
+			if !errors.Is(err, ErrInvalidCalendar) {
+				t.Errorf("ParseCalendar = %d items, error %v; want ErrInvalidCalendar", len(items), err)
+			}
+		})
+	}
+}
+
+func TestParseCalendarTooManyTasks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	for i := 0; i <= maxImportTasks; i++ {
+		b.WriteString("BEGIN:VTODO\r\nSUMMARY:x\r\nEND:VTODO\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	if _, err := ParseCalendar(strings.NewReader(b.String()), time.UTC); !errors.Is(err, ErrTooManyTasks) {
+		t.Errorf("error = %v, want ErrTooManyTasks", err)
+	}
+}
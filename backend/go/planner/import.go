@@ -0,0 +1,307 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// maxImportTasks - наибольшее число задач в одном импорте
+const maxImportTasks = 1000
+
+// ErrTooManyTasks - в импортируемом файле слишком много задач
+var ErrTooManyTasks = fmt.Errorf("too many tasks to import, at most %d", maxImportTasks)
+
+// ImportedTask - задача, прочитанная из внешнего формата
+type ImportedTask struct {
+	Title       string
+	Description string
+	Folder      string // имя папки; пустое - models.DefaultTaskFolder
+	Deadline    *time.Time
+	HasTime     bool
+	Priority    string // пустой - medium
+	Completed   bool
+	Status      string // ключ или имя колонки доски; неизвестная колонка игнорируется
+	Tags        []string
+	Recurrence  string
+	Skip        string // причина, по которой запись не импортируется
+	Warning     string // что из записи не удалось перенести
+}
+
+// ImportedFolder - папка из файла экспорта
+type ImportedFolder struct {
+	Name  string
+	Color string
+	Icon  string
+}
+
+// ImportIssue - пропущенная запись или предупреждение импорта.
+// Index - номер записи в файле с 1.
+type ImportIssue struct {
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult - итог импорта
+type ImportResult struct {
+	Tasks          []models.Task `json:"tasks"`
+	Skipped        []ImportIssue `json:"skipped"`
+	Warnings       []ImportIssue `json:"warnings"`
+	FoldersCreated []string      `json:"folders_created"`
+}
+
+// ImportOptions - параметры импорта
+type ImportOptions struct {
+	Folder         *models.TaskFolder // если задана, все задачи попадают в неё, а не в папки из файла
+	SkipDuplicates bool               // пропускать задачи, которые уже есть (то же название, папка и срок)
+}
+
+// normalize обрезает поля до размеров колонок tasks и отбрасывает теги,
+// которые нельзя сохранить
+func (item *ImportedTask) normalize() {
+	item.Title = truncateRunes(strings.TrimSpace(item.Title), 255)
+	if item.Skip == "" && item.Title == "" {
+		item.Skip = "empty title"
+	}
+	if item.Priority == "" {
+		item.Priority = models.PriorityMedium
+	}
+	if !models.ValidPriority(item.Priority) {
+		item.Priority = models.PriorityMedium
+		item.warn("unknown priority, medium used")
+	}
+
+	tags := make([]string, 0, len(item.Tags))
+	for _, tag := range item.Tags {
+		tag = strings.Join(strings.Fields(tag), "-")
+		if tag == "" {
+			continue
+		}
+		if name, err := NormalizeTag(tag); err == nil {
+			tags = append(tags, name)
+		} else {
+			item.warn("tag " + tag + " skipped: " + err.Error())
+		}
+	}
+	item.Tags = tags
+}
+
+// warn добавляет предупреждение к записи
+func (item *ImportedTask) warn(message string) {
+	if item.Warning != "" {
+		item.Warning += "; "
+	}
+	item.Warning += message
+}
+
+// truncateRunes обрезает строку до n символов
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// importer создаёт задачи одного импорта, запоминая найденные папки
+type importer struct {
+	tx      *gorm.DB
+	userID  uint
+	opts    ImportOptions
+	columns []models.BoardColumn
+	folders map[string]*models.TaskFolder
+	result  *ImportResult
+}
+
+// ImportFolders создаёт недостающие папки из файла экспорта с их цветом
+// и иконкой; у существующих папок ничего не меняется. Возвращает имена
+// созданных папок.
+func ImportFolders(tx *gorm.DB, userID uint, folders []ImportedFolder) ([]string, error) {
+	created := []string{}
+	for _, f := range folders {
+		folder := models.TaskFolder{UserID: userID, Name: f.Name, Color: f.Color, Icon: truncateRunes(f.Icon, 50)}
+		if !ValidFolderColor(folder.Color) {
+			folder.Color = ""
+		}
+		err := CreateFolder(tx, &folder)
+		switch {
+		case err == nil:
+			created = append(created, folder.Name)
+		case errors.Is(err, ErrFolderExists), errors.Is(err, ErrFolderName):
+		default:
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+// ImportTasks создаёт задачи пользователя. Запись без названия или с
+// причиной Skip пропускается; задача ставится в колонку Status, если она
+// есть, иначе - в колонку по Completed. Невыполненные задачи получают
+// напоминания по умолчанию.
+func ImportTasks(tx *gorm.DB, userID uint, items []ImportedTask, opts ImportOptions) (*ImportResult, error) {
+	if len(items) > maxImportTasks {
+		return nil, ErrTooManyTasks
+	}
+	columns, err := Columns(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &importer{
+		tx:      tx,
+		userID:  userID,
+		opts:    opts,
+		columns: columns,
+		folders: map[string]*models.TaskFolder{},
+		result: &ImportResult{
+			Tasks:          []models.Task{},
+			Skipped:        []ImportIssue{},
+			Warnings:       []ImportIssue{},
+			FoldersCreated: []string{},
+		},
+	}
+	for i := range items {
+		item := items[i]
+		item.normalize()
+		if err := imp.add(i+1, &item); err != nil {
+			return nil, err
+		}
+	}
+	return imp.result, nil
+}
+
+// add создаёт задачу из записи index или записывает, почему она пропущена
+func (imp *importer) add(index int, item *ImportedTask) error {
+	skip := func(reason string) error {
+		imp.result.Skipped = append(imp.result.Skipped, ImportIssue{Index: index, Title: item.Title, Reason: reason})
+		return nil
+	}
+	if item.Skip != "" {
+		return skip(item.Skip)
+	}
+
+	folder, err := imp.folder(item.Folder)
+	if errors.Is(err, ErrFolderName) {
+		return skip(err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	task := models.Task{
+		UserID:      imp.userID,
+		Title:       item.Title,
+		Description: item.Description,
+		Deadline:    item.Deadline,
+		HasTime:     item.HasTime,
+		Priority:    item.Priority,
+	}
+	SetFolder(&task, folder)
+	if err := SetRecurrence(&task, item.Recurrence); err != nil {
+		return skip(err.Error())
+	}
+
+	if imp.opts.SkipDuplicates {
+		var count int64
+		if err := imp.tx.Model(&models.Task{}).
+			Where("user_id = ? AND title = ? AND folder_id = ? AND deadline IS NOT DISTINCT FROM ?",
+				imp.userID, task.Title, folder.ID, task.Deadline).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return skip("task already exists")
+		}
+	}
+
+	if err := imp.tx.Create(&task).Error; err != nil {
+		return err
+	}
+	column, err := imp.column(item)
+	if err != nil {
+		return err
+	}
+	if err := PlaceTask(imp.tx, &task, column, -1); err != nil {
+		return err
+	}
+	if len(item.Tags) > 0 {
+		if err := SetTags(imp.tx, &task, item.Tags); err != nil {
+			return err
+		}
+	}
+	if !task.Completed {
+		if err := DefaultReminders(imp.tx, &task); err != nil {
+			return err
+		}
+	}
+
+	if item.Warning != "" {
+		imp.result.Warnings = append(imp.result.Warnings, ImportIssue{Index: index, Title: item.Title, Reason: item.Warning})
+	}
+	imp.result.Tasks = append(imp.result.Tasks, task)
+	return nil
+}
+
+// folder возвращает папку записи, создавая её при необходимости
+func (imp *importer) folder(name string) (*models.TaskFolder, error) {
+	if imp.opts.Folder != nil {
+		return imp.opts.Folder, nil
+	}
+	if strings.TrimSpace(name) == "" {
+		name = models.DefaultTaskFolder
+	}
+	name, err := NormalizeFolderName(name)
+	if err != nil {
+		return nil, err
+	}
+	if folder, ok := imp.folders[name]; ok {
+		return folder, nil
+	}
+
+	var count int64
+	if err := imp.tx.Model(&models.TaskFolder{}).
+		Where("user_id = ? AND name = ?", imp.userID, name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	folder, err := EnsureFolder(imp.tx, imp.userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		imp.result.FoldersCreated = append(imp.result.FoldersCreated, name)
+	}
+	imp.folders[name] = folder
+	return folder, nil
+}
+
+// column выбирает колонку доски записи: по ключу или имени Status
+// (без учёта регистра, пробелов и знаков), иначе по Completed
+func (imp *importer) column(item *ImportedTask) (*models.BoardColumn, error) {
+	if wanted := columnMatchKey(item.Status); wanted != "" {
+		for i := range imp.columns {
+			column := &imp.columns[i]
+			if columnMatchKey(column.Key) == wanted || columnMatchKey(column.Name) == wanted {
+				return column, nil
+			}
+		}
+	}
+	return CompletionColumn(imp.columns, item.Completed)
+}
+
+// columnMatchKey оставляет от имени колонки буквы и цифры в нижнем регистре
+func columnMatchKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
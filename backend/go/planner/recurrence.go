@@ -0,0 +1,322 @@
+// Package planner содержит предметную логику задач: повторения, сроки,
+// зависимости и календарь.
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Частоты повторения (подмножество RFC 5545)
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxPeriods ограничивает перебор периодов, чтобы неудачное правило
+// (например BYMONTHDAY=31 с INTERVAL=12 в феврале) не зациклило сервер
+const maxPeriods = 10000
+
+// ErrInvalidRule - правило повторения не разобрано
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Rule - правило повторения задачи.
+// Поддерживаются FREQ, INTERVAL, BYDAY (без числовых префиксов),
+// BYMONTHDAY (в том числе отрицательные), COUNT и UNTIL.
+type Rule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// presets - короткие имена правил, которые принимает API
+var presets = map[string]string{
+	"daily":    "FREQ=DAILY",
+	"weekdays": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	"weekly":   "FREQ=WEEKLY",
+	"biweekly": "FREQ=WEEKLY;INTERVAL=2",
+	"monthly":  "FREQ=MONTHLY",
+	"yearly":   "FREQ=YEARLY",
+}
+
+// ParseRule разбирает правило: короткое имя (daily, weekly, ...) или
+// строку RRULE вида "FREQ=WEEKLY;BYDAY=MO,WE" (префикс "RRULE:" допустим)
+func ParseRule(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if preset, ok := presets[strings.ToLower(s)]; ok {
+		s = preset
+	}
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("%w: частота %s не поддерживается", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, fmt.Errorf("%w: INTERVAL", ErrInvalidRule)
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return nil, fmt.Errorf("%w: день недели %s", ErrInvalidRule, code)
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, raw := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(raw)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("%w: BYMONTHDAY", ErrInvalidRule)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: COUNT", ErrInvalidRule)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL", ErrInvalidRule)
+			}
+			rule.Until = &until
+		case "WKST":
+			// Неделя всегда начинается с понедельника
+		default:
+			return nil, fmt.Errorf("%w: параметр %s не поддерживается", ErrInvalidRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: не указан FREQ", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT и UNTIL несовместимы", ErrInvalidRule)
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != FreqWeekly && rule.Freq != FreqDaily {
+		return nil, fmt.Errorf("%w: BYDAY поддерживается только для DAILY и WEEKLY", ErrInvalidRule)
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != FreqMonthly {
+		return nil, fmt.Errorf("%w: BYMONTHDAY поддерживается только для MONTHLY", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+// parseUntil разбирает дату UNTIL (YYYYMMDD или YYYYMMDDTHHMMSSZ)
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" || layout == "2006-01-02" {
+				// Дата без времени включает весь день
+				t = t.Add(24*time.Hour - time.Nanosecond)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidRule
+}
+
+// String возвращает правило в каноническом виде RRULE
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+		for _, day := range sortedWeekdays(r.ByDay) {
+			for code, d := range weekdayCodes {
+				if d == day {
+					codes = append(codes, code)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences перебирает даты серии, начинающейся в start, по порядку.
+// fn получает дату и её номер в серии (с 1) и возвращает false, чтобы
+// остановить перебор. Время суток всех дат совпадает со временем start.
+func (r *Rule) Occurrences(start time.Time, fn func(t time.Time, n int) bool) {
+	n := 0
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if r.Until != nil && t.After(*r.Until) {
+			return false
+		}
+		n++
+		if r.Count > 0 && n > r.Count {
+			return false
+		}
+		return fn(t, n)
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, t := range r.period(start, period) {
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+// period возвращает даты одного периода правила по возрастанию
+func (r *Rule) period(start time.Time, index int) []time.Time {
+	step := index * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		t := start.AddDate(0, 0, step)
+		if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, t.Weekday()) {
+			return nil
+		}
+		return []time.Time{t}
+
+	case FreqWeekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		// Недели отсчитываются от понедельника недели start
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		var dates []time.Time
+		for _, day := range sortedWeekdays(days) {
+			dates = append(dates, monday.AddDate(0, 0, (int(day)+6)%7))
+		}
+		return dates
+
+	case FreqMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1,
+			start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		days := r.ByMonthDay
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+		last := first.AddDate(0, 1, -1).Day()
+		var dates []time.Time
+		for _, day := range days {
+			if day < 0 {
+				day = last + day + 1
+			}
+			// Несуществующие дни (31 апреля) пропускаются, как в RFC 5545
+			if day >= 1 && day <= last {
+				dates = append(dates, first.AddDate(0, 0, day-1))
+			}
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+		return dates
+
+	case FreqYearly:
+		t := time.Date(start.Year()+step, start.Month(), start.Day(),
+			start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+		if t.Day() != start.Day() {
+			return nil // 29 февраля в невисокосный год
+		}
+		return []time.Time{t}
+	}
+	return nil
+}
+
+// Next возвращает первую дату серии позже after и её номер в серии
+func (r *Rule) Next(start, after time.Time) (time.Time, int, bool) {
+	var next time.Time
+	var number int
+	r.Occurrences(start, func(t time.Time, n int) bool {
+		if t.After(after) {
+			next, number = t, n
+			return false
+		}
+		return true
+	})
+	return next, number, !next.IsZero()
+}
+
+// Between возвращает даты серии в интервале [from, to], не более limit
+func (r *Rule) Between(start, from, to time.Time, limit int) []time.Time {
+	var dates []time.Time
+	r.Occurrences(start, func(t time.Time, n int) bool {
+		if t.After(to) || len(dates) >= limit {
+			return false
+		}
+		if !t.Before(from) {
+			dates = append(dates, t)
+		}
+		return true
+	})
+	return dates
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedWeekdays упорядочивает дни с понедельника и убирает повторы
+func sortedWeekdays(days []time.Weekday) []time.Weekday {
+	seen := make(map[time.Weekday]bool)
+	var result []time.Weekday
+	for _, d := range days {
+		if !seen[d] {
+			seen[d] = true
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return (int(result[i])+6)%7 < (int(result[j])+6)%7
+	})
+	return result
+}
@@ -0,0 +1,367 @@
+package planner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"portfolio/models"
+)
+
+const occurrenceLayout = "2006-01-02 15:04 MST"
+
+// collect возвращает не более limit дат серии в виде строк occurrenceLayout
+func collect(t *testing.T, rrule string, start time.Time, limit int) []string {
+	t.Helper()
+	rule, err := ParseRule(rrule)
+	if err != nil {
+		t.Fatalf("ParseRule(%q): %v", rrule, err)
+	}
+	var dates []string
+	rule.Occurrences(start, func(d time.Time, n int) bool {
+		if n != len(dates)+1 {
+			t.Errorf("%s: occurrence %s numbered %d, want %d", rrule, d.Format(occurrenceLayout), n, len(dates)+1)
+		}
+		dates = append(dates, d.Format(occurrenceLayout))
+		return len(dates) < limit
+	})
+	return dates
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%q): %v", name, err)
+	}
+	return loc
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"daily", "FREQ=DAILY"},
+		{" Weekdays ", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"biweekly", "FREQ=WEEKLY;INTERVAL=2"},
+		{"RRULE:FREQ=WEEKLY;BYDAY=FR,MO", "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{"freq=monthly;bymonthday=-1,15", "FREQ=MONTHLY;BYMONTHDAY=-1,15"},
+		{"FREQ=DAILY;INTERVAL=1;WKST=SU;", "FREQ=DAILY"},
+		{"FREQ=DAILY;BYDAY=SA,SU,SA", "FREQ=DAILY;BYDAY=SA,SU"},
+		{"FREQ=YEARLY;COUNT=3", "FREQ=YEARLY;COUNT=3"},
+		{"FREQ=DAILY;UNTIL=20240131T235959Z", "FREQ=DAILY;UNTIL=20240131"},
+		{"FREQ=DAILY;UNTIL=2024-01-31", "FREQ=DAILY;UNTIL=20240131"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.in)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.in, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("ParseRule(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRuleInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"hourly",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ",
+		"FREQ=",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=1001",
+		"FREQ=DAILY;INTERVAL=x",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=MONTHLY;BYDAY=MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		if _, err := ParseRule(in); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("ParseRule(%q) error = %v, want ErrInvalidRule", in, err)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	newYork := mustLocation(t, "America/New_York")
+	utc := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		limit int
+		want  []string
+	}{
+		{
+			name:  "daily keeps wall clock across spring DST",
+			rule:  "FREQ=DAILY",
+			start: time.Date(2024, 3, 30, 9, 0, 0, 0, berlin),
+			limit: 3,
+			want:  []string{"2024-03-30 09:00 CET", "2024-03-31 09:00 CEST", "2024-04-01 09:00 CEST"},
+		},
+		{
+			name:  "daily keeps wall clock across autumn DST",
+			rule:  "FREQ=DAILY",
+			start: time.Date(2024, 10, 26, 9, 0, 0, 0, berlin),
+			limit: 3,
+			want:  []string{"2024-10-26 09:00 CEST", "2024-10-27 09:00 CET", "2024-10-28 09:00 CET"},
+		},
+		{
+			name:  "weekly across DST in another zone",
+			rule:  "FREQ=WEEKLY",
+			start: time.Date(2024, 3, 3, 18, 30, 0, 0, newYork),
+			limit: 2,
+			want:  []string{"2024-03-03 18:30 EST", "2024-03-10 18:30 EDT"},
+		},
+		{
+			name:  "weekly byday starting midweek",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			start: utc(2024, 1, 3, 9),
+			limit: 4,
+			want:  []string{"2024-01-03 09:00 UTC", "2024-01-05 09:00 UTC", "2024-01-08 09:00 UTC", "2024-01-10 09:00 UTC"},
+		},
+		{
+			name:  "weekly byday skips days before start",
+			rule:  "FREQ=WEEKLY;BYDAY=MO",
+			start: utc(2024, 1, 3, 9),
+			limit: 2,
+			want:  []string{"2024-01-08 09:00 UTC", "2024-01-15 09:00 UTC"},
+		},
+		{
+			name:  "biweekly byday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			start: utc(2024, 1, 1, 9),
+			limit: 4,
+			want:  []string{"2024-01-02 09:00 UTC", "2024-01-04 09:00 UTC", "2024-01-16 09:00 UTC", "2024-01-18 09:00 UTC"},
+		},
+		{
+			name:  "daily byday",
+			rule:  "FREQ=DAILY;BYDAY=SA,SU",
+			start: utc(2024, 1, 5, 9),
+			limit: 3,
+			want:  []string{"2024-01-06 09:00 UTC", "2024-01-07 09:00 UTC", "2024-01-13 09:00 UTC"},
+		},
+		{
+			name:  "bymonthday 31 skips short months",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31",
+			start: utc(2024, 1, 31, 9),
+			limit: 4,
+			want:  []string{"2024-01-31 09:00 UTC", "2024-03-31 09:00 UTC", "2024-05-31 09:00 UTC", "2024-07-31 09:00 UTC"},
+		},
+		{
+			name:  "monthly without bymonthday keeps start day",
+			rule:  "FREQ=MONTHLY",
+			start: utc(2024, 8, 31, 9),
+			limit: 2,
+			want:  []string{"2024-08-31 09:00 UTC", "2024-10-31 09:00 UTC"},
+		},
+		{
+			name:  "negative bymonthday is the last day",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: utc(2024, 1, 15, 9),
+			limit: 3,
+			want:  []string{"2024-01-31 09:00 UTC", "2024-02-29 09:00 UTC", "2024-03-31 09:00 UTC"},
+		},
+		{
+			name:  "several bymonthdays in order",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=15,1",
+			start: utc(2024, 1, 10, 9),
+			limit: 3,
+			want:  []string{"2024-01-15 09:00 UTC", "2024-02-01 09:00 UTC", "2024-02-15 09:00 UTC"},
+		},
+		{
+			name:  "yearly on february 29",
+			rule:  "FREQ=YEARLY",
+			start: utc(2024, 2, 29, 9),
+			limit: 2,
+			want:  []string{"2024-02-29 09:00 UTC", "2028-02-29 09:00 UTC"},
+		},
+		{
+			name:  "count stops the series",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: utc(2024, 1, 1, 9),
+			limit: 10,
+			want:  []string{"2024-01-01 09:00 UTC", "2024-01-02 09:00 UTC", "2024-01-03 09:00 UTC"},
+		},
+		{
+			name:  "count starts at the first date on or after start",
+			rule:  "FREQ=WEEKLY;BYDAY=MO;COUNT=2",
+			start: utc(2024, 1, 3, 9),
+			limit: 10,
+			want:  []string{"2024-01-08 09:00 UTC", "2024-01-15 09:00 UTC"},
+		},
+		{
+			name:  "until date includes the whole day",
+			rule:  "FREQ=DAILY;UNTIL=20240103",
+			start: utc(2024, 1, 1, 23),
+			limit: 10,
+			want:  []string{"2024-01-01 23:00 UTC", "2024-01-02 23:00 UTC", "2024-01-03 23:00 UTC"},
+		},
+		{
+			name:  "until time is inclusive",
+			rule:  "FREQ=DAILY;UNTIL=20240103T090000Z",
+			start: utc(2024, 1, 1, 9),
+			limit: 10,
+			want:  []string{"2024-01-01 09:00 UTC", "2024-01-02 09:00 UTC", "2024-01-03 09:00 UTC"},
+		},
+		{
+			name:  "until before the time of day excludes the day",
+			rule:  "FREQ=DAILY;UNTIL=20240103T085959Z",
+			start: utc(2024, 1, 1, 9),
+			limit: 10,
+			want:  []string{"2024-01-01 09:00 UTC", "2024-01-02 09:00 UTC"},
+		},
+		{
+			name:  "until before start gives nothing",
+			rule:  "FREQ=DAILY;UNTIL=20231231",
+			start: utc(2024, 1, 1, 9),
+			limit: 10,
+			want:  nil,
+		},
+		{
+			name:  "impossible rule stops after maxPeriods",
+			rule:  "FREQ=MONTHLY;INTERVAL=12;BYMONTHDAY=30",
+			start: utc(2024, 2, 10, 9),
+			limit: 10,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(t, tt.rule, tt.start, tt.limit)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("%s from %s:\n got  %q\n want %q", tt.rule, tt.start.Format(occurrenceLayout), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleNext(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	start := time.Date(2024, 3, 29, 9, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name   string
+		rule   string
+		after  time.Time
+		want   string
+		number int
+		ok     bool
+	}{
+		{"before start", "FREQ=DAILY", start.Add(-time.Hour), "2024-03-29 09:00 CET", 1, true},
+		{"at start", "FREQ=DAILY", start, "2024-03-30 09:00 CET", 2, true},
+		{"over spring DST", "FREQ=DAILY", time.Date(2024, 3, 30, 9, 0, 0, 0, berlin), "2024-03-31 09:00 CEST", 3, true},
+		{"weekday after friday", "weekdays", start, "2024-04-01 09:00 CEST", 2, true},
+		{"last of count", "FREQ=DAILY;COUNT=2", start, "2024-03-30 09:00 CET", 2, true},
+		{"count exhausted", "FREQ=DAILY;COUNT=2", start.AddDate(0, 0, 1), "", 0, false},
+		{"until reached", "FREQ=DAILY;UNTIL=20240330", start.AddDate(0, 0, 1), "", 0, false},
+		{"monthly last day", "FREQ=MONTHLY;BYMONTHDAY=-1", start, "2024-03-31 09:00 CEST", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRule(%q): %v", tt.rule, err)
+			}
+			next, number, ok := rule.Next(start, tt.after)
+			if ok != tt.ok {
+				t.Fatalf("Next ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got := next.Format(occurrenceLayout); got != tt.want || number != tt.number {
+				t.Errorf("Next = %s #%d, want %s #%d", got, number, tt.want, tt.number)
+			}
+		})
+	}
+
+	// Сутки перехода на летнее время короче: следующий повтор через 23 часа
+	rule, _ := ParseRule("FREQ=DAILY")
+	next, _, _ := rule.Next(start, time.Date(2024, 3, 30, 9, 0, 0, 0, berlin))
+	if gap := next.Sub(time.Date(2024, 3, 30, 9, 0, 0, 0, berlin)); gap != 23*time.Hour {
+		t.Errorf("gap over DST = %v, want 23h", gap)
+	}
+}
+
+func TestRuleBetweenLimit(t *testing.T) {
+	rule, _ := ParseRule("FREQ=DAILY")
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	dates := rule.Between(start, start.AddDate(0, 0, 5), start.AddDate(1, 0, 0), 3)
+	if len(dates) != 3 || !dates[0].Equal(start.AddDate(0, 0, 5)) {
+		t.Errorf("Between = %v, want 3 dates from %s", dates, start.AddDate(0, 0, 5))
+	}
+}
+
+func TestExpandSeries(t *testing.T) {
+	deadline := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	head := &models.Task{ID: 7, Recurrence: "daily", Deadline: &deadline}
+	lower := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("window", func(t *testing.T) {
+		got := expandSeries(nil, head, lower.AddDate(0, 0, 9), lower.AddDate(0, 0, 12), time.UTC, map[seriesDate]bool{})
+		want := []string{"2024-01-10", "2024-01-11", "2024-01-12"}
+		if len(got) != len(want) {
+			t.Fatalf("got %d occurrences, want %d", len(got), len(want))
+		}
+		for i, o := range got {
+			if o.Date != want[i] || o.Occurrence != 10+i || !o.Virtual || o.TaskID != head.ID {
+				t.Errorf("occurrence %d = %+v, want virtual #%d on %s", i, o, 10+i, want[i])
+			}
+		}
+	})
+
+	t.Run("skips the current deadline and seen dates", func(t *testing.T) {
+		seen := map[seriesDate]bool{{7, deadline.AddDate(0, 0, 1).Unix()}: true}
+		got := expandSeries(nil, head, lower, lower.AddDate(0, 0, 4), time.UTC, seen)
+		if len(got) != 2 || got[0].Date != "2024-01-03" || got[1].Date != "2024-01-04" {
+			t.Errorf("got %+v, want 2024-01-03 and 2024-01-04", got)
+		}
+	})
+
+	t.Run("maxExpand cutoff", func(t *testing.T) {
+		got := expandSeries(nil, head, lower, lower.AddDate(10, 0, 0), time.UTC, map[seriesDate]bool{})
+		if len(got) != maxExpand {
+			t.Fatalf("got %d occurrences, want maxExpand = %d", len(got), maxExpand)
+		}
+		if last := got[len(got)-1]; last.Occurrence != maxExpand+1 {
+			t.Errorf("last occurrence #%d, want #%d", last.Occurrence, maxExpand+1)
+		}
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		broken := *head
+		broken.Recurrence = "FREQ=HOURLY"
+		if got := expandSeries(nil, &broken, lower, lower.AddDate(0, 1, 0), time.UTC, map[seriesDate]bool{}); len(got) != 0 {
+			t.Errorf("got %d occurrences for an invalid rule", len(got))
+		}
+	})
+}
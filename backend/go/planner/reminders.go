@@ -0,0 +1,270 @@
+package planner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"portfolio/models"
+	"portfolio/notify"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxReminders - наибольшее число напоминаний у задачи
+	maxReminders = 5
+	// maxReminderMinutes - напоминание не раньше чем за 30 дней до срока
+	maxReminderMinutes = 30 * 24 * 60
+	// reminderStaleAfter - пропущенные напоминания (сервер был остановлен,
+	// напоминание добавлено задним числом) старше этого не отправляются
+	reminderStaleAfter = 24 * time.Hour
+	// reminderWorkers - сколько напоминаний доставляется одновременно
+	reminderWorkers = 8
+)
+
+// ErrInvalidReminder - смещение вне 0..maxReminderMinutes или слишком много напоминаний
+var ErrInvalidReminder = fmt.Errorf("reminders must be 0..%d minutes before the deadline, at most %d per task",
+	maxReminderMinutes, maxReminders)
+
+// NormalizeReminders проверяет смещения напоминаний (минуты до срока) и
+// возвращает их по возрастанию без повторов
+func NormalizeReminders(minutes []int) ([]int, error) {
+	seen := make(map[int]bool, len(minutes))
+	result := make([]int, 0, len(minutes))
+	for _, m := range minutes {
+		if m < 0 || m > maxReminderMinutes {
+			return nil, ErrInvalidReminder
+		}
+		if !seen[m] {
+			seen[m] = true
+			result = append(result, m)
+		}
+	}
+	if len(result) > maxReminders {
+		return nil, ErrInvalidReminder
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+// Preferences возвращает настройки уведомлений пользователя (по умолчанию,
+// если он их не менял)
+func Preferences(db *gorm.DB, userID uint) (models.NotificationPreference, error) {
+	var pref models.NotificationPreference
+	err := db.Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreference(userID), nil
+	}
+	return pref, err
+}
+
+// SetReminders заменяет напоминания задачи. Сохранённые смещения не
+// сбрасываются, чтобы уже отправленное напоминание не пришло повторно.
+func SetReminders(tx *gorm.DB, taskID uint, minutes []int) ([]models.TaskReminder, error) {
+	minutes, err := NormalizeReminders(minutes)
+	if err != nil {
+		return nil, err
+	}
+
+	remove := tx.Where("task_id = ?", taskID)
+	if len(minutes) > 0 {
+		remove = remove.Where("minutes_before NOT IN ?", minutes)
+	}
+	if err := remove.Delete(&models.TaskReminder{}).Error; err != nil {
+		return nil, err
+	}
+
+	if len(minutes) > 0 {
+		reminders := make([]models.TaskReminder, 0, len(minutes))
+		for _, m := range minutes {
+			reminders = append(reminders, models.TaskReminder{TaskID: taskID, MinutesBefore: m})
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reminders).Error; err != nil {
+			return nil, err
+		}
+	}
+	return Reminders(tx, taskID)
+}
+
+// Reminders возвращает напоминания задачи по возрастанию смещения
+func Reminders(db *gorm.DB, taskID uint) ([]models.TaskReminder, error) {
+	reminders := []models.TaskReminder{}
+	err := db.Where("task_id = ?", taskID).Order("minutes_before ASC").Find(&reminders).Error
+	return reminders, err
+}
+
+// DefaultReminders добавляет новой задаче со сроком напоминания из
+// настроек пользователя
+func DefaultReminders(tx *gorm.DB, task *models.Task) error {
+	if task.Deadline == nil {
+		return nil
+	}
+	pref, err := Preferences(tx, task.UserID)
+	if err != nil {
+		return err
+	}
+	minutes := pref.Offsets()
+	if len(minutes) > maxReminders {
+		minutes = minutes[:maxReminders]
+	}
+	_, err = SetReminders(tx, task.ID, minutes)
+	return err
+}
+
+// copyReminders переносит напоминания задачи на её повторение
+func copyReminders(tx *gorm.DB, fromTaskID, toTaskID uint) error {
+	return tx.Exec(`INSERT INTO task_reminders (task_id, minutes_before, created_at)
+		SELECT ?, minutes_before, NOW() FROM task_reminders WHERE task_id = ?`,
+		toTaskID, fromTaskID).Error
+}
+
+// dueReminder - напоминание, время которого наступило
+type dueReminder struct {
+	ID       uint
+	TaskID   uint
+	UserID   uint
+	Title    string
+	Deadline time.Time
+	HasTime  bool
+	Email    string
+	Timezone string
+}
+
+// SendDueReminders отправляет напоминания, время которых наступило к now.
+// Срок на весь день отсчитывается от AllDayTime пользователя. Каждое
+// напоминание сначала помечается отправленным, поэтому несколько
+// экземпляров сервера не продублируют его. Доставка идёт параллельно в
+// reminderWorkers потоков, чтобы медленный webhook одного пользователя не
+// задерживал напоминания остальных. Возвращает число отправленных.
+func SendDueReminders(ctx context.Context, db *gorm.DB, channels notify.Channels, now time.Time) (int, error) {
+	var due []dueReminder
+	err := db.WithContext(ctx).Raw(`SELECT * FROM (
+			SELECT r.id, r.task_id, t.user_id, t.title, t.deadline, t.has_time, u.email, u.timezone,
+				t.deadline
+					+ CASE WHEN t.has_time THEN interval '0' ELSE COALESCE(NULLIF(p.all_day_time, ''), '09:00')::interval END
+					- r.minutes_before * interval '1 minute' AS fire_at
+			FROM task_reminders r
+			JOIN tasks t ON t.id = r.task_id
+			JOIN users u ON u.id = t.user_id
+			LEFT JOIN notification_preferences p ON p.user_id = t.user_id
+			WHERE t.deleted_at IS NULL AND NOT t.completed AND t.deadline IS NOT NULL
+				AND (r.sent_for IS NULL OR r.sent_for <> t.deadline)
+		) due
+		WHERE fire_at <= @now AND fire_at > @stale
+		ORDER BY fire_at ASC`,
+		map[string]interface{}{"now": now, "stale": now.Add(-reminderStaleAfter)}).
+		Scan(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	prefs := map[uint]models.NotificationPreference{}
+	sent := 0
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, reminderWorkers)
+	defer wg.Wait()
+	for _, r := range due {
+		result := db.WithContext(ctx).Model(&models.TaskReminder{}).
+			Where("id = ? AND (sent_for IS NULL OR sent_for <> ?)", r.ID, r.Deadline).
+			UpdateColumn("sent_for", r.Deadline)
+		if result.Error != nil {
+			return sent, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		pref, ok := prefs[r.UserID]
+		if !ok {
+			if pref, err = Preferences(db, r.UserID); err != nil {
+				return sent, err
+			}
+			prefs[r.UserID] = pref
+		}
+		msg, email := reminderMessage(&r, now), r.Email
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			deliver(ctx, channels, &pref, msg, email)
+		}()
+		sent++
+	}
+	return sent, nil
+}
+
+// reminderMessage формирует текст напоминания в часовом поясе пользователя
+func reminderMessage(r *dueReminder, now time.Time) notify.Message {
+	user := models.User{Timezone: r.Timezone}
+	deadline := r.Deadline.In(user.Location())
+
+	body := "Срок: " + deadline.Format("02.01.2006")
+	if r.HasTime {
+		body += deadline.Format(" 15:04") + " (" + deadline.Location().String() + ")"
+	} else {
+		body += " (весь день)"
+	}
+
+	taskID := r.TaskID
+	return notify.Message{
+		UserID: r.UserID,
+		Kind:   models.NotificationTaskReminder,
+		Title:  "Напоминание: " + r.Title,
+		Body:   body,
+		TaskID: &taskID,
+		Time:   now,
+	}
+}
+
+// deliver отправляет сообщение по включённым у пользователя каналам.
+// Ошибка одного канала не мешает остальным и только пишется в лог.
+func deliver(ctx context.Context, channels notify.Channels, pref *models.NotificationPreference, msg notify.Message, userEmail string) {
+	to := notify.Recipient{
+		UserID:        pref.UserID,
+		Email:         pref.EmailAddress,
+		WebhookURL:    pref.WebhookURL,
+		WebhookSecret: pref.WebhookSecret,
+	}
+	if to.Email == "" {
+		to.Email = userEmail
+	}
+
+	enabled := map[string]bool{
+		notify.ChannelInApp:   pref.InApp,
+		notify.ChannelEmail:   pref.Email,
+		notify.ChannelWebhook: pref.Webhook,
+	}
+	for name, on := range enabled {
+		notifier, ok := channels[name]
+		if !on || !ok {
+			continue
+		}
+		if err := notifier.Notify(ctx, to, msg); err != nil {
+			log.Printf("⚠️ Не удалось отправить напоминание пользователю %d (%s): %v", pref.UserID, name, err)
+		}
+	}
+}
+
+// StartReminderScheduler раз в interval отправляет наступившие напоминания
+func StartReminderScheduler(db *gorm.DB, channels notify.Channels, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sent, err := SendDueReminders(context.Background(), db, channels, time.Now())
+			if err != nil {
+				log.Printf("⚠️ Ошибка отправки напоминаний: %v", err)
+			} else if sent > 0 {
+				log.Printf("⏰ Отправлено напоминаний: %d", sent)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,235 @@
+package planner
+
+import (
+	"errors"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// RoleOwner - права владельца задачи: всё, включая перенос в другую папку
+const RoleOwner = "owner"
+
+var (
+	// ErrReadOnly - участнику папки открыт только просмотр
+	ErrReadOnly = errors.New("you have view-only access to this folder")
+	// ErrOwnerOnly - перенести задачу в другую папку может только владелец
+	ErrOwnerOnly = errors.New("only the task owner can move it to another folder")
+	// ErrUserNotFound - пользователя, которому открывают папку, нет
+	ErrUserNotFound = errors.New("user not found")
+	// ErrMemberNotFound - пользователь не участник папки
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrShareOwner - папку нельзя открыть её владельцу
+	ErrShareOwner = errors.New("cannot share a folder with its owner")
+	// ErrFolderRole - права участника не view и не edit
+	ErrFolderRole = errors.New("role must be view or edit")
+	// ErrAssignee - исполнитель не видит задачу
+	ErrAssignee = errors.New("assignee must be the task owner or a member of its folder")
+)
+
+// SharedFolder - папка другого пользователя, открытая участнику
+type SharedFolder struct {
+	models.TaskFolder
+	Owner string `json:"owner"` // имя владельца
+	Role  string `json:"role"`
+}
+
+// memberFolders - подзапрос ID папок, открытых пользователю; edit -
+// только с правом изменения
+func memberFolders(db *gorm.DB, userID uint, edit bool) *gorm.DB {
+	query := db.Session(&gorm.Session{NewDB: true}).
+		Model(&models.TaskFolderMember{}).
+		Select("folder_id").
+		Where("user_id = ?", userID)
+	if edit {
+		query = query.Where("role = ?", models.FolderRoleEdit)
+	}
+	return query
+}
+
+// VisibleTo ограничивает запрос задач задачами пользователя и задачами
+// папок, открытых ему другими пользователями
+func VisibleTo(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(tasks.user_id = ? OR tasks.folder_id IN (?))", userID, memberFolders(db, userID, false))
+	}
+}
+
+// memberRole возвращает права участника папки; ErrMemberNotFound - папка
+// ему не открыта
+func memberRole(tx *gorm.DB, folderID, userID uint) (string, error) {
+	var member models.TaskFolderMember
+	err := tx.Select("role").Where("folder_id = ? AND user_id = ?", folderID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrMemberNotFound
+	}
+	return member.Role, err
+}
+
+// TaskRole возвращает права пользователя на задачу: RoleOwner,
+// models.FolderRoleEdit или models.FolderRoleView. Если задача ему не
+// видна - ErrTaskNotFound.
+func TaskRole(tx *gorm.DB, task *models.Task, userID uint) (string, error) {
+	if task.UserID == userID {
+		return RoleOwner, nil
+	}
+	if task.FolderID == nil {
+		return "", ErrTaskNotFound
+	}
+	role, err := memberRole(tx, *task.FolderID, userID)
+	if errors.Is(err, ErrMemberNotFound) {
+		return "", ErrTaskNotFound
+	}
+	return role, err
+}
+
+// CanEdit проверяет, что пользователь может изменять задачу
+func CanEdit(tx *gorm.DB, task *models.Task, userID uint) error {
+	role, err := TaskRole(tx, task, userID)
+	if err == nil && role == models.FolderRoleView {
+		return ErrReadOnly
+	}
+	return err
+}
+
+// WritableFolder находит папку для новой задачи пользователя: по id -
+// свою или открытую ему с правом изменения, по имени - только свою (как
+// ResolveFolder). Задача создаётся от имени владельца папки.
+func WritableFolder(tx *gorm.DB, userID uint, id *uint, name string) (*models.TaskFolder, error) {
+	if id == nil {
+		return ResolveFolder(tx, userID, nil, name)
+	}
+	var folder models.TaskFolder
+	err := tx.Where("id = ?", *id).First(&folder).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFolderNotFound
+	}
+	if err != nil || folder.UserID == userID {
+		return &folder, err
+	}
+
+	role, err := memberRole(tx, folder.ID, userID)
+	switch {
+	case errors.Is(err, ErrMemberNotFound):
+		return nil, ErrFolderNotFound
+	case err != nil:
+		return nil, err
+	case role != models.FolderRoleEdit:
+		return nil, ErrReadOnly
+	}
+	return &folder, nil
+}
+
+// SetAssignee назначает исполнителя задачи (nil снимает его) без
+// сохранения задачи. Исполнитель - владелец задачи или участник её папки;
+// назначенный другим пользователем получает уведомление.
+func SetAssignee(tx *gorm.DB, task *models.Task, assigneeID *uint, actorID uint) error {
+	if assigneeID == nil {
+		task.AssigneeID = nil
+		return nil
+	}
+	id := *assigneeID
+	if id != task.UserID {
+		if task.FolderID == nil {
+			return ErrAssignee
+		}
+		if _, err := memberRole(tx, *task.FolderID, id); err != nil {
+			if errors.Is(err, ErrMemberNotFound) {
+				return ErrAssignee
+			}
+			return err
+		}
+	}
+	if task.AssigneeID != nil && *task.AssigneeID == id {
+		return nil
+	}
+
+	task.AssigneeID = &id
+	if id == actorID {
+		return nil
+	}
+	return tx.Create(&models.Notification{
+		UserID:  id,
+		Kind:    models.NotificationTaskAssigned,
+		Title:   "Вам назначена задача: " + task.Title,
+		Message: "Папка: " + task.Folder,
+		TaskID:  &task.ID,
+	}).Error
+}
+
+// Members возвращает участников папки с именами пользователей
+func Members(db *gorm.DB, folderID uint) ([]models.TaskFolderMember, error) {
+	members := []models.TaskFolderMember{}
+	err := db.Model(&models.TaskFolderMember{}).
+		Select("task_folder_members.*, users.username").
+		Joins("JOIN users ON users.id = task_folder_members.user_id").
+		Where("task_folder_members.folder_id = ?", folderID).
+		Order("users.username ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// SharedFolders возвращает папки, открытые пользователю, с владельцами и
+// правами
+func SharedFolders(db *gorm.DB, userID uint) ([]SharedFolder, error) {
+	folders := []SharedFolder{}
+	err := db.Model(&models.TaskFolder{}).
+		Select("task_folders.*, users.username AS owner, task_folder_members.role").
+		Joins("JOIN task_folder_members ON task_folder_members.folder_id = task_folders.id").
+		Joins("JOIN users ON users.id = task_folders.user_id").
+		Where("task_folder_members.user_id = ?", userID).
+		Order("users.username ASC, task_folders.position ASC").
+		Scan(&folders).Error
+	return folders, err
+}
+
+// ShareFolder открывает папку пользователю username с правами role или
+// меняет права, если он уже участник
+func ShareFolder(tx *gorm.DB, folder *models.TaskFolder, username, role string) (*models.TaskFolderMember, error) {
+	if !models.ValidFolderRole(role) {
+		return nil, ErrFolderRole
+	}
+	var user models.User
+	err := tx.Select("id", "username").Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == folder.UserID {
+		return nil, ErrShareOwner
+	}
+
+	var member models.TaskFolderMember
+	err = tx.Where("folder_id = ? AND user_id = ?", folder.ID, user.ID).First(&member).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		member = models.TaskFolderMember{FolderID: folder.ID, UserID: user.ID, Role: role}
+		err = tx.Create(&member).Error
+	case err == nil && member.Role != role:
+		member.Role = role
+		err = tx.Model(&member).Update("role", role).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	member.Username = user.Username
+	return &member, nil
+}
+
+// RemoveMember закрывает папку участнику и снимает его с задач папки,
+// которых он больше не видит
+func RemoveMember(tx *gorm.DB, folderID, userID uint) error {
+	result := tx.Where("folder_id = ? AND user_id = ?", folderID, userID).Delete(&models.TaskFolderMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMemberNotFound
+	}
+	return tx.Model(&models.Task{}).
+		Where("folder_id = ? AND assignee_id = ?", folderID, userID).
+		Update("assignee_id", nil).Error
+}
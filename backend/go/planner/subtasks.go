@@ -0,0 +1,219 @@
+package planner
+
+import (
+	"errors"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// maxDepth ограничивает обход цепочки родителей на случай испорченных данных
+const maxDepth = 100
+
+var (
+	// ErrParentNotFound - родительская задача не найдена у пользователя
+	ErrParentNotFound = errors.New("parent task not found")
+	// ErrParentCycle - задача не может быть подзадачей самой себя или потомка
+	ErrParentCycle = errors.New("task cannot be nested into itself or its subtask")
+	// ErrChecklistOrder - порядок должен перечислять все пункты чек-листа ровно один раз
+	ErrChecklistOrder = errors.New("item_ids must list every checklist item exactly once")
+)
+
+// SetParent делает задачу подзадачей parentID (nil - задача верхнего уровня).
+// Проверяет владельца родителя и отсутствие цикла.
+func SetParent(tx *gorm.DB, task *models.Task, parentID *uint) error {
+	if parentID == nil {
+		task.ParentID = nil
+		return nil
+	}
+
+	id := *parentID
+	for depth := 0; id != 0 && depth < maxDepth; depth++ {
+		if task.ID != 0 && id == task.ID {
+			return ErrParentCycle
+		}
+		var ancestor models.Task
+		err := tx.Select("id", "parent_id").
+			Where("id = ? AND user_id = ?", id, task.UserID).
+			First(&ancestor).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if id == *parentID {
+				return ErrParentNotFound
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ancestor.ParentID == nil {
+			break
+		}
+		id = *ancestor.ParentID
+	}
+
+	task.ParentID = parentID
+	return nil
+}
+
+// Descendants возвращает ID всех подзадач задачи на любой глубине
+func Descendants(tx *gorm.DB, userID, taskID uint) ([]uint, error) {
+	var result []uint
+	level := []uint{taskID}
+	seen := map[uint]bool{taskID: true}
+
+	for depth := 0; len(level) > 0 && depth < maxDepth; depth++ {
+		var children []uint
+		if err := tx.Model(&models.Task{}).
+			Where("user_id = ? AND parent_id IN ?", userID, level).
+			Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+
+		level = level[:0]
+		for _, id := range children {
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+				level = append(level, id)
+			}
+		}
+	}
+	return result, nil
+}
+
+// RecalcProgress пересчитывает процент выполнения задачи и всех её предков.
+// Учитываются пункты чек-листа и прямые подзадачи (выполненная подзадача
+// засчитывается целиком). Задача без пунктов и подзадач имеет прогресс 0.
+func RecalcProgress(tx *gorm.DB, taskID uint) error {
+	id := taskID
+	for depth := 0; id != 0 && depth < maxDepth; depth++ {
+		var counts struct {
+			Total int64
+			Done  int64
+		}
+		err := tx.Raw(`SELECT
+				(SELECT COUNT(*) FROM checklist_items WHERE task_id = @id) +
+				(SELECT COUNT(*) FROM tasks WHERE parent_id = @id AND deleted_at IS NULL) AS total,
+				(SELECT COUNT(*) FROM checklist_items WHERE task_id = @id AND done) +
+				(SELECT COUNT(*) FROM tasks WHERE parent_id = @id AND completed AND deleted_at IS NULL) AS done`,
+			map[string]interface{}{"id": id}).
+			Scan(&counts).Error
+		if err != nil {
+			return err
+		}
+
+		progress := 0
+		if counts.Total > 0 {
+			progress = int(counts.Done * 100 / counts.Total)
+		}
+
+		var task models.Task
+		if err := tx.Select("id", "parent_id").First(&task, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if err := tx.Model(&task).UpdateColumn("progress", progress).Error; err != nil {
+			return err
+		}
+
+		id = 0
+		if task.ParentID != nil {
+			id = *task.ParentID
+		}
+	}
+	return nil
+}
+
+// RecalcParent пересчитывает прогресс родителя задачи, если он есть
+func RecalcParent(tx *gorm.DB, task *models.Task) error {
+	if task.ParentID == nil {
+		return nil
+	}
+	return RecalcProgress(tx, *task.ParentID)
+}
+
+// AddChecklistItem добавляет пункт в позицию position (nil - в конец),
+// сдвигая последующие пункты
+func AddChecklistItem(tx *gorm.DB, taskID uint, title string, position *int) (*models.ChecklistItem, error) {
+	var count int64
+	if err := tx.Model(&models.ChecklistItem{}).Where("task_id = ?", taskID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	pos := int(count)
+	if position != nil && *position >= 0 && *position < pos {
+		pos = *position
+		if err := tx.Model(&models.ChecklistItem{}).
+			Where("task_id = ? AND position >= ?", taskID, pos).
+			UpdateColumn("position", gorm.Expr("position + 1")).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	item := models.ChecklistItem{TaskID: taskID, Title: title, Position: pos}
+	if err := tx.Create(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, RecalcProgress(tx, taskID)
+}
+
+// ReorderChecklist задаёт порядок пунктов. itemIDs должен содержать все
+// пункты задачи ровно по одному разу.
+func ReorderChecklist(tx *gorm.DB, taskID uint, itemIDs []uint) error {
+	var existing []uint
+	if err := tx.Model(&models.ChecklistItem{}).Where("task_id = ?", taskID).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	if len(existing) != len(itemIDs) {
+		return ErrChecklistOrder
+	}
+
+	known := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+	for _, id := range itemIDs {
+		if !known[id] {
+			return ErrChecklistOrder
+		}
+		delete(known, id) // повтор ID не пройдёт эту проверку
+	}
+
+	for pos, id := range itemIDs {
+		if err := tx.Model(&models.ChecklistItem{}).Where("id = ?", id).UpdateColumn("position", pos).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChecklistItem удаляет пункт и сдвигает последующие
+func DeleteChecklistItem(tx *gorm.DB, item *models.ChecklistItem) error {
+	if err := tx.Delete(item).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.ChecklistItem{}).
+		Where("task_id = ? AND position > ?", item.TaskID, item.Position).
+		UpdateColumn("position", gorm.Expr("position - 1")).Error; err != nil {
+		return err
+	}
+	return RecalcProgress(tx, item.TaskID)
+}
+
+// copyChecklist копирует пункты чек-листа в другую задачу неотмеченными
+func copyChecklist(tx *gorm.DB, fromTaskID, toTaskID uint) error {
+	var items []models.ChecklistItem
+	if err := tx.Where("task_id = ?", fromTaskID).Order("position ASC").Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i] = models.ChecklistItem{TaskID: toTaskID, Title: items[i].Title, Position: items[i].Position}
+	}
+	return tx.Create(&items).Error
+}
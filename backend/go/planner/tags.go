@@ -0,0 +1,119 @@
+package planner
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTagName - имя тега пустое, длиннее 50 символов или содержит
+	// символы, кроме букв, цифр, "-" и "_"
+	ErrTagName = errors.New("tag name must be 1-50 letters, digits, '-' or '_'")
+	// ErrTagNotFound - тега нет у пользователя
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrTagExists - тег с таким именем уже есть
+	ErrTagExists = errors.New("tag already exists")
+)
+
+// tagPattern - допустимое имя тега: без пробелов и двоеточий, чтобы тег
+// записывался в языке запросов как tag:name
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,50}$`)
+
+// NormalizeTag приводит имя тега к нижнему регистру и проверяет его.
+// Ведущий "#" отбрасывается.
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if !tagPattern.MatchString(name) {
+		return "", ErrTagName
+	}
+	return name, nil
+}
+
+// EnsureTags возвращает теги пользователя с именами names, создавая
+// недостающие. Повторы имён схлопываются.
+func EnsureTags(tx *gorm.DB, userID uint, names []string) ([]models.Tag, error) {
+	seen := make(map[string]bool, len(names))
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) == 0 {
+		return []models.Tag{}, nil
+	}
+
+	missing := make([]models.Tag, 0, len(normalized))
+	for _, name := range normalized {
+		missing = append(missing, models.Tag{UserID: userID, Name: name})
+	}
+	// Существующие теги пропускаются, параллельно созданные - тоже
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+		return nil, err
+	}
+
+	var tags []models.Tag
+	err := tx.Where("user_id = ? AND name IN ?", userID, normalized).Order("name ASC").Find(&tags).Error
+	return tags, err
+}
+
+// SetTags заменяет теги задачи на names
+func SetTags(tx *gorm.DB, task *models.Task, names []string) error {
+	tags, err := EnsureTags(tx, task.UserID, names)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(task).Association("Tags").Replace(tags); err != nil {
+		return err
+	}
+	task.Tags = tags
+	return nil
+}
+
+// copyTags переносит теги задачи на её повторение
+func copyTags(tx *gorm.DB, fromTaskID, toTaskID uint) error {
+	return tx.Exec("INSERT INTO task_tags (task_id, tag_id) SELECT ?, tag_id FROM task_tags WHERE task_id = ?",
+		toTaskID, fromTaskID).Error
+}
+
+// RenameTag переименовывает тег
+func RenameTag(tx *gorm.DB, tag *models.Tag, name string) error {
+	name, err := NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+	if name == tag.Name {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.Tag{}).
+		Where("user_id = ? AND name = ? AND id <> ?", tag.UserID, name, tag.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTagExists
+	}
+	tag.Name = name
+	return tx.Model(tag).UpdateColumn("name", name).Error
+}
+
+// DeleteTag снимает тег со всех задач и удаляет его
+func DeleteTag(tx *gorm.DB, tag *models.Tag) error {
+	if err := tx.Exec("DELETE FROM task_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+		return err
+	}
+	return tx.Delete(tag).Error
+}
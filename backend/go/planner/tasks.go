@@ -0,0 +1,303 @@
+package planner
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrRecurrenceDeadline - повторяющейся задаче нужен дедлайн, от которого
+// отсчитываются повторения
+var ErrRecurrenceDeadline = errors.New("recurring task requires a deadline")
+
+// maxExpand ограничивает число повторений одной серии в календаре
+const maxExpand = 1000
+
+// SeriesKey возвращает идентификатор серии задачи
+func SeriesKey(task *models.Task) uint {
+	if task.SeriesID != nil {
+		return *task.SeriesID
+	}
+	return task.ID
+}
+
+// SetRecurrence задаёт задаче правило повторения (пустая строка снимает
+// повторение). Новое правило начинает новую серию с текущего дедлайна.
+func SetRecurrence(task *models.Task, recurrence string) error {
+	if recurrence == "" {
+		task.Recurrence = ""
+		task.SeriesID = nil
+		task.SeriesStart = nil
+		task.Occurrence = 0
+		return nil
+	}
+
+	if task.Deadline == nil {
+		return ErrRecurrenceDeadline
+	}
+	rule, err := ParseRule(recurrence)
+	if err != nil {
+		return err
+	}
+
+	if canonical := rule.String(); canonical != task.Recurrence {
+		start := *task.Deadline
+		task.Recurrence = canonical
+		task.SeriesID = nil
+		task.SeriesStart = &start
+		task.Occurrence = 1
+	}
+	return nil
+}
+
+// seriesStart возвращает начало серии в часовом поясе current со временем
+// суток текущего дедлайна: время повторений можно менять по ходу серии
+func seriesStart(task *models.Task, current time.Time) time.Time {
+	if task.SeriesStart == nil {
+		return current
+	}
+	t := task.SeriesStart.In(current.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), current.Hour(), current.Minute(), 0, 0, current.Location())
+}
+
+// SpawnNext создаёт следующее повторение выполненной задачи. Даты считаются
+// в часовом поясе владельца задачи loc, поэтому время суток сохраняется при
+// переходе на летнее время. Возвращает nil, если задача не повторяется,
+// серия закончилась или повторение уже создано.
+// Должна вызываться внутри транзакции после блокировки задачи.
+func SpawnNext(tx *gorm.DB, task *models.Task, loc *time.Location) (*models.Task, error) {
+	if task.Recurrence == "" || task.Deadline == nil {
+		return nil, nil
+	}
+	rule, err := ParseRule(task.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	current := task.Deadline.In(loc)
+	next, number, ok := rule.Next(seriesStart(task, current), current)
+	if !ok {
+		return nil, nil
+	}
+
+	// Первая задача серии становится её идентификатором
+	if task.SeriesID == nil {
+		if err := tx.Model(task).UpdateColumn("series_id", task.ID).Error; err != nil {
+			return nil, err
+		}
+		task.SeriesID = &task.ID
+	}
+
+	// Повторное выполнение (после снятия отметки) не плодит дубликаты
+	var exists int64
+	if err := tx.Model(&models.Task{}).
+		Where("user_id = ? AND series_id = ? AND occurrence = ?", task.UserID, *task.SeriesID, number).
+		Count(&exists).Error; err != nil {
+		return nil, err
+	}
+	if exists > 0 {
+		return nil, nil
+	}
+
+	child := models.Task{
+		UserID:      task.UserID,
+		Title:       task.Title,
+		Description: task.Description,
+		Folder:      task.Folder,
+		FolderID:    task.FolderID,
+		Deadline:    &next,
+		HasTime:     task.HasTime,
+		Priority:    task.Priority,
+		Recurrence:  task.Recurrence,
+		SeriesID:    task.SeriesID,
+		SeriesStart: task.SeriesStart,
+		Occurrence:  number,
+		ParentID:    task.ParentID,
+		AssigneeID:  task.AssigneeID,
+	}
+	if err := tx.Create(&child).Error; err != nil {
+		return nil, err
+	}
+	if err := AppendTask(tx, &child, ""); err != nil {
+		return nil, err
+	}
+
+	// Чек-лист повторяется неотмеченным, поэтому прогресс новой задачи - 0
+	if err := copyChecklist(tx, task.ID, child.ID); err != nil {
+		return nil, err
+	}
+	if err := copyTags(tx, task.ID, child.ID); err != nil {
+		return nil, err
+	}
+	if err := copyReminders(tx, task.ID, child.ID); err != nil {
+		return nil, err
+	}
+	if err := RecalcParent(tx, &child); err != nil {
+		return nil, err
+	}
+	return &child, nil
+}
+
+// DeleteTasks удаляет задачи ids вместе со всеми подзадачами и их
+// зависимостями и пересчитывает прогресс оставшихся родителей. Возвращает
+// число удалённых задач.
+func DeleteTasks(tx *gorm.DB, userID uint, ids []uint) (int64, error) {
+	seen := make(map[uint]bool, len(ids))
+	all := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		descendants, err := Descendants(tx, userID, id)
+		if err != nil {
+			return 0, err
+		}
+		for _, d := range append(descendants, id) {
+			if !seen[d] {
+				seen[d] = true
+				all = append(all, d)
+			}
+		}
+	}
+	if len(all) == 0 {
+		return 0, nil
+	}
+
+	var parents []uint
+	if err := tx.Model(&models.Task{}).
+		Where("id IN ? AND parent_id IS NOT NULL AND parent_id NOT IN ?", all, all).
+		Distinct("parent_id").Pluck("parent_id", &parents).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Where("id IN ? AND user_id = ?", all, userID).Delete(&models.Task{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	// Удалённые задачи больше никого не блокируют
+	if err := RemoveDependencies(tx, all); err != nil {
+		return 0, err
+	}
+	for _, id := range parents {
+		if err := RecalcProgress(tx, id); err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected, nil
+}
+
+// Occurrence - задача или будущее повторение в календаре
+type Occurrence struct {
+	TaskID     uint      `json:"task_id"`
+	SeriesID   *uint     `json:"series_id,omitempty"`
+	Title      string    `json:"title"`
+	Folder     string    `json:"folder"`
+	Priority   string    `json:"priority"`
+	Date       string    `json:"date"` // календарная дата в часовом поясе пользователя
+	Deadline   time.Time `json:"deadline"`
+	HasTime    bool      `json:"deadline_has_time"`
+	Completed  bool      `json:"completed"`
+	Occurrence int       `json:"occurrence,omitempty"`
+	// Virtual - повторение ещё не создано; TaskID указывает на текущую
+	// открытую задачу серии
+	Virtual bool `json:"virtual"`
+}
+
+// Expand возвращает задачи, видимые пользователю (свои и из общих папок),
+// со сроком в днях from..to (включительно, в часовом поясе loc) и
+// вычисленные будущие повторения открытых повторяющихся задач
+func Expand(db *gorm.DB, userID uint, from, to time.Time, loc *time.Location) ([]Occurrence, error) {
+	lower := DayStart(from, loc)
+	upper := DayStart(to, loc).AddDate(0, 0, 1)
+
+	var tasks []models.Task
+	if err := db.Scopes(VisibleTo(userID)).Where("deadline >= ? AND deadline < ?", lower, upper).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	occurrences := make([]Occurrence, 0, len(tasks))
+	seen := make(map[seriesDate]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		deadline := task.Deadline.In(loc)
+		occurrences = append(occurrences, occurrenceOf(task, deadline, task.Occurrence, false))
+		if task.Recurrence != "" {
+			seen[seriesDate{SeriesKey(task), deadline.Unix()}] = true
+		}
+	}
+
+	var heads []models.Task
+	if err := db.Scopes(VisibleTo(userID)).Where("recurrence <> '' AND completed = ? AND deadline < ?", false, upper).
+		Find(&heads).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range heads {
+		occurrences = expandSeries(occurrences, &heads[i], lower, upper, loc, seen)
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if !occurrences[i].Deadline.Equal(occurrences[j].Deadline) {
+			return occurrences[i].Deadline.Before(occurrences[j].Deadline)
+		}
+		return occurrences[i].TaskID < occurrences[j].TaskID
+	})
+	return occurrences, nil
+}
+
+// expandSeries добавляет к occurrences будущие повторения открытой задачи
+// head в интервале [lower, upper), не более maxExpand. Даты из seen уже
+// есть в календаре и пропускаются.
+func expandSeries(occurrences []Occurrence, head *models.Task, lower, upper time.Time, loc *time.Location, seen map[seriesDate]bool) []Occurrence {
+	rule, err := ParseRule(head.Recurrence)
+	if err != nil || head.Deadline == nil {
+		return occurrences
+	}
+	current := head.Deadline.In(loc)
+
+	added := 0
+	rule.Occurrences(seriesStart(head, current), func(t time.Time, n int) bool {
+		if !t.Before(upper) || added >= maxExpand {
+			return false
+		}
+		if !t.After(current) || t.Before(lower) {
+			return true
+		}
+		key := seriesDate{SeriesKey(head), t.Unix()}
+		if !seen[key] {
+			seen[key] = true
+			occurrences = append(occurrences, occurrenceOf(head, t, n, true))
+			added++
+		}
+		return true
+	})
+	return occurrences
+}
+
+// seriesDate - повторение серии на дату
+type seriesDate struct {
+	series uint
+	at     int64
+}
+
+func occurrenceOf(task *models.Task, deadline time.Time, number int, virtual bool) Occurrence {
+	return Occurrence{
+		TaskID:     task.ID,
+		SeriesID:   task.SeriesID,
+		Title:      task.Title,
+		Folder:     task.Folder,
+		Priority:   task.Priority,
+		Date:       deadline.Format(DateLayout),
+		Deadline:   deadline,
+		HasTime:    task.HasTime,
+		Completed:  task.Completed && !virtual,
+		Occurrence: number,
+		Virtual:    virtual,
+	}
+}
@@ -0,0 +1,319 @@
+package planner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio/models"
+)
+
+// Форматы импорта и экспорта задач
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatICal    = "ics"
+	FormatTodoist = "todoist" // CSV-экспорт проекта Todoist
+	FormatTrello  = "trello"  // JSON-экспорт доски Trello
+)
+
+// exportVersion - версия формата JSON-экспорта
+const exportVersion = 1
+
+// ErrInvalidImport - файл импорта не разобран
+var ErrInvalidImport = errors.New("invalid import file")
+
+// csvHeader - колонки CSV-экспорта; при импорте порядок колонок любой,
+// обязательна только title
+var csvHeader = []string{"title", "description", "folder", "deadline", "priority", "completed", "status", "tags", "recurrence", "created_at"}
+
+// Export - задачи и папки пользователя в формате JSON-экспорта
+type Export struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Timezone   string         `json:"timezone"`
+	Folders    []ExportFolder `json:"folders"`
+	Tasks      []ExportTask   `json:"tasks"`
+}
+
+// ExportFolder - папка в файле экспорта
+type ExportFolder struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+// ExportTask - задача в файле экспорта. Срок записывается так же, как его
+// принимает API: YYYY-MM-DD для срока на весь день, иначе RFC3339.
+type ExportTask struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Folder      string    `json:"folder"`
+	Deadline    string    `json:"deadline,omitempty"`
+	Priority    string    `json:"priority"`
+	Completed   bool      `json:"completed"`
+	Status      string    `json:"status"`
+	Tags        []string  `json:"tags,omitempty"`
+	Recurrence  string    `json:"recurrence,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewExport собирает экспорт из папок и задач пользователя (задачи с
+// загруженными Tags). Сроки записываются в часовом поясе loc.
+func NewExport(folders []models.TaskFolder, tasks []models.Task, loc *time.Location, now time.Time) *Export {
+	export := &Export{
+		Version:    exportVersion,
+		ExportedAt: now.In(loc),
+		Timezone:   loc.String(),
+		Folders:    make([]ExportFolder, 0, len(folders)),
+		Tasks:      make([]ExportTask, 0, len(tasks)),
+	}
+	for _, f := range folders {
+		export.Folders = append(export.Folders, ExportFolder{Name: f.Name, Color: f.Color, Icon: f.Icon})
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		item := ExportTask{
+			Title:       task.Title,
+			Description: task.Description,
+			Folder:      task.Folder,
+			Deadline:    formatDeadline(task, loc),
+			Priority:    task.Priority,
+			Completed:   task.Completed,
+			Status:      task.Status,
+			Recurrence:  task.Recurrence,
+			CreatedAt:   task.CreatedAt.In(loc),
+		}
+		for _, tag := range task.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		export.Tasks = append(export.Tasks, item)
+	}
+	return export
+}
+
+// formatDeadline записывает срок задачи в формате ввода API
+func formatDeadline(task *models.Task, loc *time.Location) string {
+	if task.Deadline == nil {
+		return ""
+	}
+	if !task.HasTime {
+		return task.Deadline.In(loc).Format(DateLayout)
+	}
+	return task.Deadline.In(loc).Format(time.RFC3339)
+}
+
+// WriteJSON записывает экспорт в JSON
+func (e *Export) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// WriteCSV записывает задачи экспорта в CSV (папки - колонкой folder,
+// теги - через запятую). Значения, которые табличный редактор принял бы
+// за формулу, записываются с апострофом (см. csvCell).
+func (e *Export) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, t := range e.Tasks {
+		record := []string{
+			t.Title,
+			t.Description,
+			t.Folder,
+			t.Deadline,
+			t.Priority,
+			strconv.FormatBool(t.Completed),
+			t.Status,
+			strings.Join(t.Tags, ","),
+			t.Recurrence,
+			t.CreatedAt.Format(time.RFC3339),
+		}
+		for i := range record {
+			record[i] = csvCell(record[i])
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvFormulaPrefixes - первые символы, с которых Excel и другие табличные
+// редакторы начинают формулу
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvCell защищает значение от выполнения как формулы: перед ним
+// ставится апостроф, который редактор не показывает
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvValue снимает апостроф, поставленный csvCell при экспорте
+func csvValue(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// skipBOM пропускает метку порядка байтов UTF-8 в начале r
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(3); err == nil && bytes.Equal(head, []byte("\ufeff")) {
+		br.Discard(3)
+	}
+	return br
+}
+
+// importTask переводит задачу файла экспорта в запись импорта
+func (t *ExportTask) importTask(loc *time.Location) ImportedTask {
+	item := ImportedTask{
+		Title:       t.Title,
+		Description: t.Description,
+		Folder:      t.Folder,
+		Priority:    strings.ToLower(strings.TrimSpace(t.Priority)),
+		Completed:   t.Completed,
+		Status:      t.Status,
+		Tags:        t.Tags,
+		Recurrence:  t.Recurrence,
+	}
+	if deadline := strings.TrimSpace(t.Deadline); deadline != "" {
+		d, hasTime, err := ParseDeadline(deadline, loc)
+		if err != nil {
+			item.Skip = err.Error()
+		} else {
+			item.Deadline, item.HasTime = &d, hasTime
+		}
+	}
+	return item
+}
+
+// ParseJSON читает файл JSON-экспорта. Принимается и голый массив задач.
+func ParseJSON(r io.Reader, loc *time.Location) ([]ImportedFolder, []ImportedTask, error) {
+	data, err := io.ReadAll(skipBOM(r))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var export Export
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &export.Tasks)
+	} else {
+		err = json.Unmarshal(data, &export)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if export.Version > exportVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidImport, export.Version)
+	}
+
+	folders := make([]ImportedFolder, 0, len(export.Folders))
+	for _, f := range export.Folders {
+		folders = append(folders, ImportedFolder{Name: f.Name, Color: f.Color, Icon: f.Icon})
+	}
+	items := make([]ImportedTask, 0, len(export.Tasks))
+	for i := range export.Tasks {
+		items = append(items, export.Tasks[i].importTask(loc))
+	}
+	return folders, items, nil
+}
+
+// readCSV читает CSV с заголовком и возвращает записи как словари
+// "имя колонки в нижнем регистре - значение". Файл без колонки required
+// не принимается, даже если в нём нет записей.
+func readCSV(r io.Reader, required string) ([]map[string]string, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.LazyQuotes = true
+
+	header, err := in.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: empty CSV", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	for i, name := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	}
+	if !slices.Contains(header, required) {
+		return nil, fmt.Errorf("%w: CSV must have a %s column", ErrInvalidImport, required)
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+		if len(rows) > maxImportTasks {
+			return nil, ErrTooManyTasks
+		}
+	}
+	return rows, nil
+}
+
+// ParseCSV читает CSV в формате экспорта (колонки csvHeader). Апостроф
+// перед формулой, добавленный WriteCSV, снимается.
+func ParseCSV(r io.Reader, loc *time.Location) ([]ImportedTask, error) {
+	rows, err := readCSV(r, "title")
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]ImportedTask, 0, len(rows))
+	for _, row := range rows {
+		for key, value := range row {
+			row[key] = csvValue(value)
+		}
+		task := ExportTask{
+			Title:       row["title"],
+			Description: row["description"],
+			Folder:      row["folder"],
+			Deadline:    row["deadline"],
+			Priority:    row["priority"],
+			Status:      row["status"],
+			Recurrence:  row["recurrence"],
+		}
+		if tags := strings.TrimSpace(row["tags"]); tags != "" {
+			task.Tags = strings.Split(tags, ",")
+		}
+		var completedErr error
+		if raw := strings.TrimSpace(row["completed"]); raw != "" {
+			task.Completed, completedErr = strconv.ParseBool(raw)
+		}
+
+		item := task.importTask(loc)
+		if completedErr != nil {
+			item.warn("completed must be true or false, task imported as open")
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
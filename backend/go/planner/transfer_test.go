@@ -0,0 +1,191 @@
+package planner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCSVFormulaCells(t *testing.T) {
+	export := &Export{Tasks: []ExportTask{{
+		Title:       "=HYPERLINK(\"http://evil\",\"click\")",
+		Description: "- first item\n- second item",
+		Folder:      "@home",
+		Priority:    "medium",
+		Status:      "+todo",
+		Tags:        []string{"-minus", "work"},
+		Recurrence:  "\tFREQ=DAILY",
+		CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, {
+		Title:       "'already quoted",
+		Description: "a = b + c",
+		Folder:      "Работа",
+		Priority:    "high",
+	}}}
+
+	var buf bytes.Buffer
+	if err := export.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header and 2 rows", len(records))
+	}
+
+	row := records[1]
+	for i, want := range map[int]string{
+		0: "'=HYPERLINK(\"http://evil\",\"click\")",
+		1: "'- first item\n- second item",
+		2: "'@home",
+		6: "'+todo",
+		7: "'-minus,work",
+		8: "'\tFREQ=DAILY",
+		9: "2024-01-02T03:04:05Z",
+	} {
+		if row[i] != want {
+			t.Errorf("%s = %q, want %q", csvHeader[i], row[i], want)
+		}
+	}
+	if row := records[2]; row[0] != "'already quoted" || row[1] != "a = b + c" {
+		t.Errorf("plain cells changed: %q", row[:2])
+	}
+
+	// Собственный экспорт читается обратно без апострофов
+	items, err := ParseCSV(bytes.NewReader(buf.Bytes()), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	first := export.Tasks[0]
+	if got := items[0]; got.Title != first.Title || got.Description != first.Description ||
+		got.Folder != first.Folder || got.Status != first.Status || got.Recurrence != first.Recurrence ||
+		!reflect.DeepEqual(got.Tags, first.Tags) {
+		t.Errorf("round trip = %+v, want %+v", got, first)
+	}
+	if items[1].Title != "'already quoted" {
+		t.Errorf("title = %q, want the apostrophe kept", items[1].Title)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	data := "\ufeffTitle, Deadline ,completed,tags,extra\r\n" +
+		"Only title\r\n" +
+		"Dated,2024-03-31,TRUE,\"work,home\",x,y,z\r\n" +
+		"Timed,2024-03-31T10:30,yes\r\n" +
+		"Broken date,31.03.2024\r\n" +
+		",2024-03-31\r\n"
+
+	items, err := ParseCSV(strings.NewReader(data), berlin)
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("got %d items, want 5", len(items))
+	}
+
+	if got := items[0]; got.Title != "Only title" || got.Deadline != nil || got.Completed || got.Skip != "" {
+		t.Errorf("short row = %+v", got)
+	}
+	if got := items[1]; got.Deadline == nil || !got.Deadline.Equal(time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)) ||
+		got.HasTime || !got.Completed || !reflect.DeepEqual(got.Tags, []string{"work", "home"}) {
+		t.Errorf("long row = %+v", got)
+	}
+	if got := items[2]; got.Deadline == nil || !got.Deadline.Equal(time.Date(2024, 3, 31, 10, 30, 0, 0, berlin)) ||
+		!got.HasTime || got.Completed || got.Warning == "" {
+		t.Errorf("timed row = %+v, want 10:30 CEST, open, with a warning", got)
+	}
+	if got := items[3]; got.Skip == "" {
+		t.Errorf("row with a bad deadline = %+v, want it skipped", got)
+	}
+	if got := items[4]; got.Title != "" {
+		t.Errorf("row without title = %+v", got)
+	}
+}
+
+func TestParseCSVMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":           "",
+		"no title column": "name,deadline\nx,2024-01-01\n",
+		"only BOM":        "\ufeff",
+	} {
+		if _, err := ParseCSV(strings.NewReader(data), time.UTC); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%s: error = %v, want ErrInvalidImport", name, err)
+		}
+	}
+
+	items, err := ParseCSV(strings.NewReader("title,deadline\r\n"), time.UTC)
+	if err != nil || len(items) != 0 {
+		t.Errorf("header only = %d items, %v; want none", len(items), err)
+	}
+
+	var b strings.Builder
+	b.WriteString("title\n")
+	for i := 0; i <= maxImportTasks; i++ {
+		b.WriteString("x\n")
+	}
+	if _, err := ParseCSV(strings.NewReader(b.String()), time.UTC); !errors.Is(err, ErrTooManyTasks) {
+		t.Errorf("error = %v, want ErrTooManyTasks", err)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+
+	export := "\ufeff" + `{
+		"version": 1,
+		"folders": [{"name": "Работа", "color": "#ff0000"}],
+		"tasks": [
+			{"title": "A", "folder": "Работа", "deadline": "2024-03-31", "priority": " HIGH ", "tags": ["x"]},
+			{"title": "B", "deadline": "2024-03-31T10:30:00Z", "completed": true},
+			{"title": "C", "deadline": "tomorrow"}
+		]
+	}`
+	folders, items, err := ParseJSON(strings.NewReader(export), berlin)
+	if err != nil {
+		t.Fatalf("ParseJSON with BOM: %v", err)
+	}
+	if len(folders) != 1 || folders[0].Name != "Работа" || folders[0].Color != "#ff0000" {
+		t.Errorf("folders = %+v", folders)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+	if got := items[0]; got.Priority != "high" || got.HasTime || got.Deadline == nil ||
+		!got.Deadline.Equal(time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)) {
+		t.Errorf("item A = %+v", got)
+	}
+	if got := items[1]; !got.HasTime || !got.Completed || got.Deadline.Location() != berlin {
+		t.Errorf("item B = %+v", got)
+	}
+	if got := items[2]; got.Skip == "" || got.Deadline != nil {
+		t.Errorf("item C = %+v, want it skipped for a bad deadline", got)
+	}
+
+	// Голый массив задач, тоже с BOM
+	_, items, err = ParseJSON(strings.NewReader("\ufeff  [{\"title\": \"only\"}]"), berlin)
+	if err != nil || len(items) != 1 || items[0].Title != "only" {
+		t.Errorf("bare array = %+v, %v", items, err)
+	}
+}
+
+func TestParseJSONMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":         "",
+		"truncated":     `{"version": 1, "tasks": [{"title": "a"`,
+		"wrong type":    `{"tasks": {"title": "a"}}`,
+		"newer version": `{"version": 2, "tasks": []}`,
+		"not json":      "title,deadline\na,2024-01-01\n",
+		"double BOM":    "\ufeff\ufeff[]",
+	} {
+		if _, _, err := ParseJSON(strings.NewReader(data), time.UTC); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%s: error = %v, want ErrInvalidImport", name, err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ CREATE TABLE IF NOT EXISTS users (
     password_hash VARCHAR(255) NOT NULL,
     storage_used BIGINT DEFAULT 0,
     storage_quota BIGINT DEFAULT 52428800, -- 50 МБ
+    timezone VARCHAR(64) DEFAULT 'UTC', -- IANA, для сроков задач
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -17,9 +18,13 @@ CREATE TABLE IF NOT EXISTS tasks (
     title VARCHAR(255) NOT NULL,
     description TEXT,
     folder VARCHAR(100) DEFAULT 'general',
-    deadline DATE,
-    priority VARCHAR(20) DEFAULT 'medium',
+    folder_id INTEGER, -- task_folders.id; folder дублирует имя папки
+    deadline TIMESTAMPTZ, -- срок на весь день хранится как полночь в поясе пользователя
+    has_time BOOLEAN DEFAULT FALSE,
+    priority VARCHAR(20) DEFAULT 'medium' CONSTRAINT chk_tasks_priority CHECK (priority IN ('low', 'medium', 'high')),
     completed BOOLEAN DEFAULT FALSE,
+    status VARCHAR(50) DEFAULT 'todo', -- ключ колонки доски (board_columns.key)
+    position INTEGER DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -34,6 +39,7 @@ CREATE TABLE IF NOT EXISTS files (
     file_size BIGINT NOT NULL,
     mime_type VARCHAR(100),
     folder VARCHAR(100) DEFAULT 'general',
+    folder_id INTEGER, -- task_folders.id; folder дублирует имя папки
     uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
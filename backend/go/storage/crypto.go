@@ -0,0 +1,464 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"portfolio/models"
+)
+
+// Шифрование файлов на диске.
+//
+// Каждый файл шифруется собственным ключом данных (AES-256-GCM), который
+// хранится в записи файла зашифрованным мастер-ключом из STORAGE_MASTER_KEY.
+// Прежние мастер-ключи перечисляются в STORAGE_OLD_MASTER_KEYS: ими
+// читаются ключи данных до их перешифрования командой rotate-keys.
+// Ключи задаются в base64 или hex и должны иметь длину 32 байта.
+//
+// GCM не умеет шифровать поток, поэтому файл делится на блоки по
+// encChunkSize байт, каждый со своим nonce (префикс файла + номер блока).
+// Последний блок помечается в дополнительных данных, чтобы обрезанный
+// файл не расшифровался. Блоки позволяют читать файл с произвольного
+// места, что нужно для Range-запросов.
+//
+// Миниатюры в cache/ шифруются ключом данных своего файла; извлечённый
+// для поиска текст не шифруется.
+const (
+	encMagic       = "PFE1"
+	encPrefixSize  = 8
+	encHeaderSize  = len(encMagic) + encPrefixSize
+	encChunkSize   = 64 * 1024
+	encTagSize     = 16
+	encSealedChunk = encChunkSize + encTagSize
+)
+
+var (
+	// ErrNoMasterKey - шифрование не настроено
+	ErrNoMasterKey = errors.New("storage master key is not configured")
+	// ErrUnknownMasterKey - ключ данных зашифрован неизвестным мастер-ключом
+	ErrUnknownMasterKey = errors.New("unknown storage master key")
+	// ErrCorrupted - зашифрованный файл повреждён или обрезан
+	ErrCorrupted = errors.New("encrypted file is corrupted")
+)
+
+// masterKey - мастер-ключ и его идентификатор, сохраняемый рядом с ключом данных
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var masterKeys struct {
+	once    sync.Once
+	current *masterKey
+	byID    map[string]*masterKey
+	err     error
+}
+
+// InitEncryption загружает мастер-ключи из окружения. Вызывается при
+// старте, чтобы ошибка в настройке обнаружилась сразу.
+func InitEncryption() error {
+	masterKeys.once.Do(func() {
+		masterKeys.byID = make(map[string]*masterKey)
+
+		if raw := os.Getenv("STORAGE_MASTER_KEY"); raw != "" {
+			key, err := parseMasterKey(raw)
+			if err != nil {
+				masterKeys.err = fmt.Errorf("STORAGE_MASTER_KEY: %w", err)
+				return
+			}
+			masterKeys.current = key
+			masterKeys.byID[key.id] = key
+		}
+
+		for _, raw := range strings.Split(os.Getenv("STORAGE_OLD_MASTER_KEYS"), ",") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			key, err := parseMasterKey(raw)
+			if err != nil {
+				masterKeys.err = fmt.Errorf("STORAGE_OLD_MASTER_KEYS: %w", err)
+				return
+			}
+			masterKeys.byID[key.id] = key
+		}
+	})
+	return masterKeys.err
+}
+
+// EncryptionEnabled сообщает, шифруются ли новые файлы
+func EncryptionEnabled() bool {
+	return InitEncryption() == nil && masterKeys.current != nil
+}
+
+// requireMasterKey проверяет, что текущий мастер-ключ задан
+func requireMasterKey() error {
+	if err := InitEncryption(); err != nil {
+		return err
+	}
+	if masterKeys.current == nil {
+		return ErrNoMasterKey
+	}
+	return nil
+}
+
+// CurrentKeyID возвращает идентификатор текущего мастер-ключа
+func CurrentKeyID() string {
+	if !EncryptionEnabled() {
+		return ""
+	}
+	return masterKeys.current.id
+}
+
+// parseMasterKey разбирает 32-байтовый ключ в base64 или hex
+func parseMasterKey(raw string) (*masterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(key) != 32 {
+		if key, err = hex.DecodeString(raw); err != nil || len(key) != 32 {
+			return nil, errors.New("ключ должен быть 32 байта в base64 или hex")
+		}
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// newAEAD создаёт AES-256-GCM для ключа
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newDataKey создаёт ключ данных файла и возвращает его вместе с
+// зашифрованным представлением для записи в базу
+func newDataKey() (key []byte, wrapped, keyID string, err error) {
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", "", err
+	}
+	wrapped, keyID, err = wrapKey(key)
+	return key, wrapped, keyID, err
+}
+
+// wrapKey шифрует ключ данных текущим мастер-ключом
+func wrapKey(key []byte) (wrapped, keyID string, err error) {
+	if err := requireMasterKey(); err != nil {
+		return "", "", err
+	}
+
+	master := masterKeys.current
+	nonce := make([]byte, master.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+	sealed := master.aead.Seal(nonce, nonce, key, []byte(master.id))
+	return base64.StdEncoding.EncodeToString(sealed), master.id, nil
+}
+
+// unwrapKey расшифровывает ключ данных мастер-ключом keyID
+func unwrapKey(wrapped, keyID string) ([]byte, error) {
+	if err := InitEncryption(); err != nil {
+		return nil, err
+	}
+	master, ok := masterKeys.byID[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(sealed) < master.aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+	nonceSize := master.aead.NonceSize()
+	key, err := master.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(master.id))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return key, nil
+}
+
+// EncryptedSize - размер на диске зашифрованного файла из plain байт
+func EncryptedSize(plain int64) int64 {
+	chunks := plain / encChunkSize
+	if plain%encChunkSize != 0 || plain == 0 {
+		chunks++
+	}
+	return int64(encHeaderSize) + plain + chunks*encTagSize
+}
+
+// chunkNonce - nonce блока: префикс файла и номер блока
+func chunkNonce(prefix []byte, index uint64) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix[:encPrefixSize])
+	binary.BigEndian.PutUint32(nonce[encPrefixSize:], uint32(index))
+	return nonce
+}
+
+// chunkAD - дополнительные данные блока: признак последнего блока
+func chunkAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// encryptWriter шифрует поток блоками. Close записывает последний блок,
+// но не закрывает нижележащий writer.
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	buf    []byte
+	index  uint64
+	closed bool
+}
+
+// newEncryptWriter пишет заголовок и возвращает шифрующий writer
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, encPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Полный блок отправляется, только когда есть следующие данные:
+		// последний блок должен дождаться Close
+		if len(e.buf) == encChunkSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):encChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.index), e.buf, chunkAD(final))
+	e.index++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// nopWriteCloser - writer без шифрования
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// encryptingWriter оборачивает w шифрованием, если оно включено.
+// Возвращает данные для полей WrappedKey и KeyID записи.
+func encryptingWriter(w io.Writer) (io.WriteCloser, string, string, error) {
+	if !EncryptionEnabled() {
+		return nopWriteCloser{w}, "", "", InitEncryption()
+	}
+	key, wrapped, keyID, err := newDataKey()
+	if err != nil {
+		return nil, "", "", err
+	}
+	ew, err := newEncryptWriter(w, key)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return ew, wrapped, keyID, nil
+}
+
+// StoredFile - открытый на чтение файл хранилища. Для зашифрованных
+// файлов расшифровывает содержимое на лету и поддерживает Seek и ReadAt.
+type StoredFile struct {
+	f       *os.File
+	size    int64
+	modTime time.Time
+
+	aead   cipher.AEAD
+	prefix []byte
+	chunks int64
+	pos    int64
+	cur    int64
+	plain  []byte
+}
+
+// Open открывает содержимое файла, расшифровывая его при необходимости
+func Open(file *models.File) (*StoredFile, error) {
+	return openStored(file.FilePath, file.WrappedKey, file.KeyID)
+}
+
+// OpenVersion открывает содержимое сохранённой версии файла
+func OpenVersion(version *models.FileVersion) (*StoredFile, error) {
+	return openStored(version.FilePath, version.WrappedKey, version.KeyID)
+}
+
+func openStored(path, wrapped, keyID string) (*StoredFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	sf := &StoredFile{f: f, size: info.Size(), modTime: info.ModTime(), cur: -1}
+	if wrapped == "" {
+		return sf, nil
+	}
+
+	fail := func(err error) (*StoredFile, error) {
+		f.Close()
+		return nil, err
+	}
+
+	key, err := unwrapKey(wrapped, keyID)
+	if err != nil {
+		return fail(err)
+	}
+	if sf.aead, err = newAEAD(key); err != nil {
+		return fail(err)
+	}
+
+	header := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:len(encMagic)]) != encMagic {
+		return fail(ErrCorrupted)
+	}
+	sf.prefix = header[len(encMagic):]
+
+	body := info.Size() - int64(encHeaderSize)
+	sf.chunks = (body + encSealedChunk - 1) / encSealedChunk
+	sf.size = body - sf.chunks*encTagSize
+	// Последний блок пуст только у пустого файла. Иначе обрезанный внутри
+	// тега блок не попал бы в размер и не проверялся бы при чтении.
+	last := body - (sf.chunks-1)*encSealedChunk
+	if sf.chunks == 0 || last < encTagSize || (last == encTagSize && sf.chunks > 1) {
+		return fail(ErrCorrupted)
+	}
+	return sf, nil
+}
+
+// Size - размер расшифрованного содержимого
+func (s *StoredFile) Size() int64 { return s.size }
+
+// ModTime - время изменения файла на диске
+func (s *StoredFile) ModTime() time.Time { return s.modTime }
+
+// Encrypted сообщает, зашифрован ли файл на диске
+func (s *StoredFile) Encrypted() bool { return s.aead != nil }
+
+func (s *StoredFile) Close() error { return s.f.Close() }
+
+func (s *StoredFile) Read(p []byte) (int, error) {
+	if s.aead == nil {
+		return s.f.Read(p)
+	}
+	n, err := s.ReadAt(p, s.pos)
+	s.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (s *StoredFile) Seek(offset int64, whence int) (int64, error) {
+	if s.aead == nil {
+		return s.f.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func (s *StoredFile) ReadAt(p []byte, off int64) (int, error) {
+	if s.aead == nil {
+		return s.f.ReadAt(p, off)
+	}
+
+	read := 0
+	for read < len(p) {
+		if off >= s.size {
+			return read, io.EOF
+		}
+		index := off / encChunkSize
+		if err := s.load(index); err != nil {
+			return read, err
+		}
+		n := copy(p[read:], s.plain[off-index*encChunkSize:])
+		read += n
+		off += int64(n)
+	}
+	return read, nil
+}
+
+// load расшифровывает блок index в s.plain
+func (s *StoredFile) load(index int64) error {
+	if s.cur == index {
+		return nil
+	}
+
+	sealed := make([]byte, encSealedChunk)
+	n, err := s.f.ReadAt(sealed, int64(encHeaderSize)+index*encSealedChunk)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	plain, err := s.aead.Open(s.plain[:0], chunkNonce(s.prefix, uint64(index)), sealed[:n], chunkAD(index == s.chunks-1))
+	if err != nil {
+		s.cur = -1
+		return ErrCorrupted
+	}
+	s.plain, s.cur = plain, index
+	return nil
+}
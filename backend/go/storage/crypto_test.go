@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	os.Setenv("STORAGE_MASTER_KEY", base64.StdEncoding.EncodeToString(key))
+	os.Unsetenv("STORAGE_OLD_MASTER_KEYS")
+	os.Exit(m.Run())
+}
+
+// encryptedFile - зашифрованный файл во временном каталоге теста
+type encryptedFile struct {
+	path    string
+	wrapped string
+	keyID   string
+}
+
+func (e *encryptedFile) open() (*StoredFile, error) {
+	return openStored(e.path, e.wrapped, e.keyID)
+}
+
+// writeEncrypted шифрует data в файл, записывая её кусками разной длины
+func writeEncrypted(t *testing.T, data []byte) *encryptedFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blob")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w, wrapped, keyID, err := encryptingWriter(f)
+	if err != nil {
+		t.Fatalf("encryptingWriter: %v", err)
+	}
+	if wrapped == "" || keyID != CurrentKeyID() {
+		t.Fatalf("encryption is not enabled in tests (key %q)", keyID)
+	}
+	for rest, step := data, 1; len(rest) > 0; step = step*7 + 3 {
+		n := min(step, len(rest))
+		if _, err := w.Write(rest[:n]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		rest = rest[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return &encryptedFile{path: path, wrapped: wrapped, keyID: keyID}
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 2 * encChunkSize, 3*encChunkSize + 123} {
+		data := randomBytes(t, size)
+		enc := writeEncrypted(t, data)
+
+		info, err := os.Stat(enc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != EncryptedSize(int64(size)) {
+			t.Errorf("size %d: on disk %d bytes, EncryptedSize = %d", size, info.Size(), EncryptedSize(int64(size)))
+		}
+		if raw, _ := os.ReadFile(enc.path); size > 32 && bytes.Contains(raw, data[:32]) {
+			t.Errorf("size %d: plaintext found on disk", size)
+		}
+
+		f, err := enc.open()
+		if err != nil {
+			t.Fatalf("size %d: open: %v", size, err)
+		}
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("size %d: read: %v", size, err)
+		}
+		if !f.Encrypted() || f.Size() != int64(size) || !bytes.Equal(got, data) {
+			t.Errorf("size %d: got %d bytes (Size %d), content equal %v", size, len(got), f.Size(), bytes.Equal(got, data))
+		}
+	}
+}
+
+func TestOpenPlainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain")
+	data := []byte("not encrypted")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openStored(path, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := io.ReadAll(f)
+	if err != nil || f.Encrypted() || f.Size() != int64(len(data)) || !bytes.Equal(got, data) {
+		t.Errorf("plain file = %q (Size %d), %v", got, f.Size(), err)
+	}
+}
+
+// readTampered читает весь файл после изменения его содержимого на диске
+func readTampered(t *testing.T, enc *encryptedFile, tamper func([]byte) []byte) error {
+	t.Helper()
+	raw, err := os.ReadFile(enc.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(enc.path, tamper(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := enc.open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.ReadAll(f)
+	return err
+}
+
+func TestEncryptedTampering(t *testing.T) {
+	const size = 3*encChunkSize + 100
+	sealed := func(raw []byte, i int) []byte {
+		start := encHeaderSize + i*encSealedChunk
+		return raw[start:min(start+encSealedChunk, len(raw))]
+	}
+
+	tests := []struct {
+		name   string
+		tamper func([]byte) []byte
+	}{
+		{"truncated at a chunk boundary", func(raw []byte) []byte {
+			return raw[:encHeaderSize+3*encSealedChunk]
+		}},
+		{"truncated inside the last chunk", func(raw []byte) []byte {
+			return raw[:len(raw)-5]
+		}},
+		{"truncated inside a middle chunk", func(raw []byte) []byte {
+			return raw[:encHeaderSize+encSealedChunk+100]
+		}},
+		{"truncated inside a tag", func(raw []byte) []byte {
+			return raw[:encHeaderSize+encSealedChunk+10]
+		}},
+		{"truncated to a tag", func(raw []byte) []byte {
+			return raw[:encHeaderSize+encSealedChunk+encTagSize]
+		}},
+		{"truncated to the header", func(raw []byte) []byte {
+			return raw[:encHeaderSize]
+		}},
+		{"shorter than a tag", func(raw []byte) []byte {
+			return raw[:encHeaderSize+encTagSize-1]
+		}},
+		{"truncated header", func(raw []byte) []byte {
+			return raw[:encHeaderSize-1]
+		}},
+		{"reordered chunks", func(raw []byte) []byte {
+			out := append([]byte{}, raw[:encHeaderSize]...)
+			out = append(out, sealed(raw, 1)...)
+			out = append(out, sealed(raw, 0)...)
+			return append(out, raw[encHeaderSize+2*encSealedChunk:]...)
+		}},
+		{"final chunk moved forward", func(raw []byte) []byte {
+			out := append([]byte{}, raw[:encHeaderSize+3*encSealedChunk]...)
+			out = append(out[:encHeaderSize+encSealedChunk], sealed(raw, 3)...)
+			return out
+		}},
+		{"chunk duplicated", func(raw []byte) []byte {
+			return append(append([]byte{}, raw...), sealed(raw, 3)...)
+		}},
+		{"flipped bit", func(raw []byte) []byte {
+			raw[encHeaderSize+2*encSealedChunk+7] ^= 1
+			return raw
+		}},
+		{"changed nonce prefix", func(raw []byte) []byte {
+			raw[len(encMagic)] ^= 1
+			return raw
+		}},
+		{"bad magic", func(raw []byte) []byte {
+			raw[0] = 'X'
+			return raw
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := writeEncrypted(t, randomBytes(t, size))
+			if err := readTampered(t, enc, tt.tamper); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("error = %v, want ErrCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedWrongKey(t *testing.T) {
+	enc := writeEncrypted(t, []byte("secret"))
+
+	if _, err := openStored(enc.path, enc.wrapped, "00000000"); !errors.Is(err, ErrUnknownMasterKey) {
+		t.Errorf("unknown key id: error = %v, want ErrUnknownMasterKey", err)
+	}
+	// Чужой ключ данных обнаруживается при чтении первого блока
+	other := writeEncrypted(t, []byte("other"))
+	f, err := openStored(enc.path, other.wrapped, other.keyID)
+	if err == nil {
+		_, err = io.ReadAll(f)
+		f.Close()
+	}
+	if !errors.Is(err, ErrCorrupted) {
+		t.Errorf("another data key: error = %v, want ErrCorrupted", err)
+	}
+	if _, err := openStored(enc.path, "not base64!", enc.keyID); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("bad wrapped key: error = %v, want ErrCorrupted", err)
+	}
+}
+
+func TestEncryptedReadAt(t *testing.T) {
+	data := randomBytes(t, 3*encChunkSize+100)
+	size := int64(len(data))
+	f, err := writeEncrypted(t, data).open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		off, n int64
+	}{
+		{0, 10},
+		{encChunkSize - 10, 20},
+		{encChunkSize, 1},
+		{2*encChunkSize - 1, encChunkSize + 2},
+		{10, 3 * encChunkSize},
+		{0, size},
+		{size - 100, 100},
+		{encChunkSize / 2, 10},
+	}
+	for _, tt := range tests {
+		buf := make([]byte, tt.n)
+		n, err := f.ReadAt(buf, tt.off)
+		if err != nil || int64(n) != tt.n || !bytes.Equal(buf, data[tt.off:tt.off+tt.n]) {
+			t.Errorf("ReadAt(%d, %d) = %d, %v; content equal %v", tt.off, tt.n, n, err, bytes.Equal(buf[:n], data[tt.off:tt.off+int64(n)]))
+		}
+	}
+
+	// Чтение за концом файла возвращает остаток и io.EOF
+	buf := make([]byte, 10)
+	if n, err := f.ReadAt(buf, size-4); n != 4 || err != io.EOF || !bytes.Equal(buf[:4], data[size-4:]) {
+		t.Errorf("ReadAt over the end = %d, %v", n, err)
+	}
+	if n, err := f.ReadAt(buf, size); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt at the end = %d, %v", n, err)
+	}
+}
+
+func TestEncryptedSeek(t *testing.T) {
+	data := randomBytes(t, 2*encChunkSize+50)
+	size := int64(len(data))
+	f, err := writeEncrypted(t, data).open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	readAt := func(name string, want int64, n int) {
+		t.Helper()
+		buf := make([]byte, n)
+		got, err := io.ReadFull(f, buf)
+		end := min(want+int64(n), size)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: read: %v", name, err)
+		}
+		if !bytes.Equal(buf[:got], data[want:end]) {
+			t.Errorf("%s: read %d bytes not matching offset %d", name, got, want)
+		}
+	}
+
+	steps := []struct {
+		name   string
+		offset int64
+		whence int
+		want   int64
+		read   int
+	}{
+		{"start before a boundary", encChunkSize - 3, io.SeekStart, encChunkSize - 3, 6},
+		{"back from current", -6, io.SeekCurrent, encChunkSize - 3, 10},
+		{"forward over a chunk", encChunkSize, io.SeekCurrent, 2*encChunkSize + 7, 20},
+		{"from end", -30, io.SeekEnd, size - 30, 30},
+		{"back to start", 0, io.SeekStart, 0, encChunkSize + 1},
+		{"past end", 10, io.SeekEnd, size + 10, 1},
+	}
+	for _, s := range steps {
+		pos, err := f.Seek(s.offset, s.whence)
+		if err != nil || pos != s.want {
+			t.Fatalf("%s: Seek = %d, %v; want %d", s.name, pos, err, s.want)
+		}
+		if s.want >= size {
+			if n, err := f.Read(make([]byte, s.read)); n != 0 || err != io.EOF {
+				t.Errorf("%s: Read = %d, %v; want io.EOF", s.name, n, err)
+			}
+			continue
+		}
+		readAt(s.name, s.want, s.read)
+	}
+
+	if _, err := f.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek to a negative position succeeded")
+	}
+	if _, err := f.Seek(0, 42); err == nil {
+		t.Error("Seek with an invalid whence succeeded")
+	}
+}
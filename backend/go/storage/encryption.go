@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// encryptPendingSuffix - суффикс зашифрованной копии, ключ которой уже
+// записан в базу, а сама копия ещё не заменила исходный файл
+const encryptPendingSuffix = ".encrypted"
+
+// EncryptionReport - результат шифрования или перешифрования хранилища
+type EncryptionReport struct {
+	Files    int      `json:"files"`
+	Versions int      `json:"versions"`
+	Resumed  int      `json:"resumed"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// EncryptExisting шифрует на месте файлы и версии, сохранённые до включения
+// шифрования. Зашифрованная копия пишется рядом с исходным файлом; после
+// фиксации ключа в базе она заменяет исходный файл переименованием.
+// Команда возобновляема: сначала завершаются замены, прерванные после
+// фиксации (см. resumeEncryption), а исходный файл до фиксации не меняется.
+// Миниатюры зашифрованных файлов удаляются и строятся заново уже
+// зашифрованными.
+func EncryptExisting(db *gorm.DB) (*EncryptionReport, error) {
+	if err := requireMasterKey(); err != nil {
+		return nil, err
+	}
+
+	report := &EncryptionReport{}
+	if err := resumeEncryption(db, report); err != nil {
+		return nil, err
+	}
+
+	var files []models.File
+	if err := db.Unscoped().Where("wrapped_key = '' OR wrapped_key IS NULL").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		err := encryptInPlace(db, file.FilePath, func(tx *gorm.DB, wrapped, keyID string) *gorm.DB {
+			return tx.Unscoped().Model(&models.File{}).
+				Where("id = ? AND (wrapped_key = '' OR wrapped_key IS NULL)", file.ID).
+				UpdateColumns(map[string]interface{}{"wrapped_key": wrapped, "key_id": keyID})
+		})
+		if err == nil {
+			RemoveThumbnails(&file)
+		}
+		report.count(err, &report.Files, file.FilePath)
+	}
+
+	var versions []models.FileVersion
+	if err := db.Where("wrapped_key = '' OR wrapped_key IS NULL").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		err := encryptInPlace(db, version.FilePath, func(tx *gorm.DB, wrapped, keyID string) *gorm.DB {
+			return tx.Model(&models.FileVersion{}).
+				Where("id = ? AND (wrapped_key = '' OR wrapped_key IS NULL)", version.ID).
+				UpdateColumns(map[string]interface{}{"wrapped_key": wrapped, "key_id": keyID})
+		})
+		report.count(err, &report.Versions, version.FilePath)
+	}
+
+	return report, nil
+}
+
+// RotateKeys перешифровывает текущим мастер-ключом ключи данных, зашифрованные
+// прежними ключами. Сами файлы не переписываются.
+func RotateKeys(db *gorm.DB) (*EncryptionReport, error) {
+	if err := requireMasterKey(); err != nil {
+		return nil, err
+	}
+	current := CurrentKeyID()
+
+	report := &EncryptionReport{}
+
+	var files []models.File
+	if err := db.Unscoped().Where("wrapped_key <> '' AND key_id <> ?", current).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		err := rewrapKey(db.Unscoped().Model(&models.File{}), file.ID, file.WrappedKey, file.KeyID)
+		report.count(err, &report.Files, file.FilePath)
+	}
+
+	var versions []models.FileVersion
+	if err := db.Where("wrapped_key <> '' AND key_id <> ?", current).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		err := rewrapKey(db.Model(&models.FileVersion{}), version.ID, version.WrappedKey, version.KeyID)
+		report.count(err, &report.Versions, version.FilePath)
+	}
+
+	return report, nil
+}
+
+// resumeEncryption завершает замену файлов, ключ которых уже записан в
+// базу, а зашифрованная копия осталась рядом с исходным файлом (процесс
+// был остановлен между фиксацией и переименованием)
+func resumeEncryption(db *gorm.DB, report *EncryptionReport) error {
+	var files []models.File
+	if err := db.Unscoped().Where("wrapped_key <> ''").Find(&files).Error; err != nil {
+		return err
+	}
+	var versions []models.FileVersion
+	if err := db.Where("wrapped_key <> ''").Find(&versions).Error; err != nil {
+		return err
+	}
+
+	finish := func(path string) bool {
+		err := os.Rename(path+encryptPendingSuffix, path)
+		if err != nil && !os.IsNotExist(err) {
+			report.Errors = append(report.Errors, path+": "+err.Error())
+			log.Printf("❌ %s: %v", path, err)
+		}
+		return err == nil
+	}
+	for _, file := range files {
+		if finish(file.FilePath) {
+			RemoveThumbnails(&file)
+			report.Resumed++
+		}
+	}
+	for _, version := range versions {
+		if finish(version.FilePath) {
+			report.Resumed++
+		}
+	}
+	return nil
+}
+
+// count учитывает результат обработки одного файла
+func (r *EncryptionReport) count(err error, counter *int, path string) {
+	switch {
+	case err == nil:
+		*counter++
+	case os.IsNotExist(err):
+		r.Skipped++
+		log.Printf("⚠️ Файл %s отсутствует на диске, пропущен", path)
+	default:
+		r.Errors = append(r.Errors, path+": "+err.Error())
+		log.Printf("❌ %s: %v", path, err)
+	}
+}
+
+// rewrapKey перешифровывает один ключ данных
+func rewrapKey(model *gorm.DB, id uint, wrapped, keyID string) error {
+	key, err := unwrapKey(wrapped, keyID)
+	if err != nil {
+		return err
+	}
+	newWrapped, newKeyID, err := wrapKey(key)
+	if err != nil {
+		return err
+	}
+	return model.Where("id = ? AND key_id = ?", id, keyID).
+		UpdateColumns(map[string]interface{}{"wrapped_key": newWrapped, "key_id": newKeyID}).Error
+}
+
+// encryptInPlace шифрует файл path новым ключом данных. update записывает
+// ключ в базу; если запись уже зашифрована параллельно, файл не трогается.
+// До фиксации транзакции копия лежит под именем path+encryptPendingSuffix,
+// поэтому исходный файл цел при любой ошибке базы, а после сбоя между
+// фиксацией и переименованием замену завершит resumeEncryption.
+func encryptInPlace(db *gorm.DB, path string, update func(tx *gorm.DB, wrapped, keyID string) *gorm.DB) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".encrypt-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	enc, wrapped, keyID, err := encryptingWriter(tmp)
+	if err == nil {
+		_, err = io.Copy(enc, src)
+	}
+	if err == nil {
+		err = enc.Close()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	pending := path + encryptPendingSuffix
+	updated := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := update(tx, wrapped, keyID)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		// Копия от прерванной попытки с незафиксированным ключом заменяется
+		return os.Rename(tmp.Name(), pending)
+	})
+	if err != nil || !updated {
+		return err
+	}
+	// Переименование атомарно: открытые на чтение копии дочитают старый файл
+	return os.Rename(pending, path)
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"portfolio/models"
+)
+
+// MaxIndexedBytes - предельный объём извлечённого текста одного файла
+const MaxIndexedBytes = 1 << 20
+
+// SupportsExtraction сообщает, извлекается ли из файла текст для поиска
+func SupportsExtraction(file *models.File) bool {
+	switch strings.ToLower(filepath.Ext(file.OriginalFilename)) {
+	case ".txt", ".go", ".pdf", ".docx":
+		return true
+	}
+	return false
+}
+
+// ExtractText извлекает текст из .txt, .go, .pdf и .docx файлов.
+// Для остальных типов возвращается пустая строка.
+func ExtractText(file *models.File) (string, error) {
+	var text string
+	var err error
+
+	if !SupportsExtraction(file) {
+		return "", nil
+	}
+
+	f, err := Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(file.OriginalFilename)) {
+	case ".txt", ".go":
+		text, err = extractPlain(f)
+	case ".docx":
+		text, err = extractDocx(f)
+	case ".pdf":
+		text, err = extractPDF(f)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// PostgreSQL не принимает NUL и невалидный UTF-8 в text
+	text = strings.ReplaceAll(text, "\x00", "")
+	text = strings.ToValidUTF8(text, "")
+	if len(text) > MaxIndexedBytes {
+		text = strings.ToValidUTF8(text[:MaxIndexedBytes], "")
+	}
+	return text, nil
+}
+
+// extractPlain читает начало текстового файла
+func extractPlain(f *StoredFile) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(f, MaxIndexedBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// extractDocx достаёт текст из word/document.xml
+func extractDocx(f *StoredFile) (string, error) {
+	archive, err := zip.NewReader(f, f.Size())
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range archive.File {
+		if entry.Name != "word/document.xml" {
+			continue
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		var sb strings.Builder
+		decoder := xml.NewDecoder(io.LimitReader(rc, 16*MaxIndexedBytes))
+		inText := false
+		for sb.Len() < MaxIndexedBytes {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return sb.String(), nil
+			}
+
+			switch t := token.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "t":
+					inText = true
+				case "tab":
+					sb.WriteString("\t")
+				case "br":
+					sb.WriteString("\n")
+				}
+			case xml.EndElement:
+				switch t.Name.Local {
+				case "t":
+					inText = false
+				case "p":
+					sb.WriteString("\n")
+				}
+			case xml.CharData:
+				if inText {
+					sb.Write(t)
+				}
+			}
+		}
+		return sb.String(), nil
+	}
+	return "", nil
+}
+
+var (
+	pdfStreamRe = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTextRe   = regexp.MustCompile(`(?s)\[((?:\\.|[^\]])*)\]\s*TJ|\(((?:\\.|[^\\)])*)\)\s*(?:Tj|'|")|\bET\b`)
+	pdfStringRe = regexp.MustCompile(`\(((?:\\.|[^\\)])*)\)`)
+)
+
+// extractPDF - упрощённое извлечение текста из PDF без внешних зависимостей:
+// распаковывает FlateDecode-потоки и собирает строки операторов Tj/TJ.
+// Шрифты со своей кодировкой (CID) таким способом не читаются.
+func extractPDF(f *StoredFile) (string, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, loc := range pdfStreamRe.FindAllSubmatchIndex(data, -1) {
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		if bytes.Contains(data[loc[2]:loc[3]], []byte("/FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			stream, err = io.ReadAll(io.LimitReader(zr, 16*MaxIndexedBytes))
+			zr.Close()
+			if err != nil && len(stream) == 0 {
+				continue
+			}
+		}
+
+		for _, m := range pdfTextRe.FindAllSubmatch(stream, -1) {
+			switch {
+			case m[1] != nil:
+				for _, s := range pdfStringRe.FindAllSubmatch(m[1], -1) {
+					sb.WriteString(unescapePDFString(s[1]))
+				}
+			case m[2] != nil:
+				sb.WriteString(unescapePDFString(m[2]))
+			default:
+				sb.WriteString("\n")
+			}
+		}
+
+		if sb.Len() >= MaxIndexedBytes {
+			break
+		}
+	}
+	return sb.String(), nil
+}
+
+// unescapePDFString раскрывает escape-последовательности строки PDF
+func unescapePDFString(s []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'b', 'f':
+			// пропускаем
+		case '\r', '\n':
+			// перенос строки внутри строки PDF
+		default:
+			if c >= '0' && c <= '7' {
+				n := 0
+				for j := 0; j < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; j++ {
+					n = n*8 + int(s[i]-'0')
+					i++
+				}
+				i--
+				sb.WriteByte(byte(n))
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
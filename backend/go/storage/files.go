@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"portfolio/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxFileSize - максимальный размер одного файла (50MB)
+const MaxFileSize = int64(50 * 1024 * 1024)
+
+// AllowedExtensions - разрешённые расширения загружаемых файлов
+var AllowedExtensions = []string{".pdf", ".jpg", ".jpeg", ".png", ".doc", ".docx", ".txt", ".go", ".zip"}
+
+var (
+	// ErrFileTooLarge - файл больше MaxFileSize
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrFileType - расширение файла не разрешено
+	ErrFileType = errors.New("file type not allowed")
+	// ErrFolderExists - папка с таким путём уже существует
+	ErrFolderExists = errors.New("folder already exists")
+)
+
+// AllowedFile сообщает, разрешено ли загружать файл с таким именем
+func AllowedFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range AllowedExtensions {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Upload описывает сохраняемый файл
+type Upload struct {
+	UserID   uint
+	Folder   string // нормализованный путь папки
+	Name     string // исходное имя файла
+	MimeType string
+	// Versioning сохраняет прежнее содержимое файла с тем же именем как версию
+	Versioning bool
+	// Replace заменяет содержимое файла с тем же именем без сохранения версии
+	Replace bool
+}
+
+// SaveUpload записывает поток на диск, резервирует место в квоте и
+// создаёт (или обновляет) запись файла. При ошибке байты удаляются.
+// Индексация и миниатюры запускаются в фоне.
+func SaveUpload(db *gorm.DB, r io.Reader, upload Upload) (*models.File, error) {
+	if !AllowedFile(upload.Name) {
+		return nil, ErrFileType
+	}
+
+	ext := strings.ToLower(filepath.Ext(upload.Name))
+	filename := uuid.New().String() + ext
+	dir := filepath.Join(UploadsDir, strconv.Itoa(int(upload.UserID)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	filePath := filepath.Join(dir, filename)
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Содержимое шифруется, если задан мастер-ключ. Хеш считается по
+	// исходным байтам и используется как ETag.
+	enc, wrappedKey, keyID, err := encryptingWriter(dst)
+	if err != nil {
+		dst.Close()
+		os.Remove(filePath)
+		return nil, err
+	}
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(enc, hasher), io.LimitReader(r, MaxFileSize+1))
+	if closeErr := enc.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > MaxFileSize {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	file := models.File{
+		UserID:           upload.UserID,
+		Filename:         filename,
+		OriginalFilename: upload.Name,
+		FilePath:         filePath,
+		FileSize:         written,
+		MimeType:         upload.MimeType,
+		ContentHash:      hex.EncodeToString(hasher.Sum(nil)),
+		WrappedKey:       wrappedKey,
+		KeyID:            keyID,
+		Version:          1,
+		Folder:           upload.Folder,
+		UploadedAt:       time.Now(),
+	}
+
+	// Заменённое без сохранения версии содержимое удаляется после фиксации
+	var replaced *models.File
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing *models.File
+		if upload.Versioning || upload.Replace {
+			found, err := FindForVersioning(tx, upload.UserID, upload.Folder, upload.Name)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			existing = found
+		}
+
+		if existing != nil && !upload.Versioning {
+			if err := Release(tx, upload.UserID, existing.FileSize); err != nil {
+				return err
+			}
+		}
+		if err := Reserve(tx, upload.UserID, written); err != nil {
+			return err
+		}
+
+		folder, err := EnsureFolderPath(tx, upload.UserID, upload.Folder)
+		if err != nil {
+			return err
+		}
+		file.FolderID = &folder.ID
+
+		if existing == nil {
+			return tx.Create(&file).Error
+		}
+
+		// Повторная загрузка того же имени в ту же папку обновляет запись
+		if upload.Versioning {
+			if err := SnapshotVersion(tx, existing); err != nil {
+				return err
+			}
+		} else {
+			replaced = existing
+		}
+		file.ID = existing.ID
+		file.Version = max(existing.Version, 1) + 1
+		return tx.Save(&file).Error
+	})
+	if err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	if replaced != nil {
+		if err := os.Remove(replaced.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Не удалось удалить заменённый файл %s: %v", replaced.FilePath, err)
+		}
+	}
+
+	// Текст для поиска извлекается в фоне
+	EnqueueIndex(file.ID)
+
+	// Миниатюру строим в фоне, чтобы не задерживать ответ
+	go func(file models.File) {
+		RemoveThumbnails(&file) // миниатюры прошлой версии
+		if err := GenerateThumbnails(&file); err != nil {
+			log.Printf("⚠️ Не удалось создать миниатюру для файла %d: %v", file.ID, err)
+		}
+	}(file)
+
+	return &file, nil
+}
+
+// MoveFile переносит файл в папку folder (создаётся при необходимости)
+// и задаёт ему имя name. Должна вызываться внутри транзакции.
+func MoveFile(tx *gorm.DB, file *models.File, folder, name string) error {
+	dir, err := EnsureFolderPath(tx, file.UserID, folder)
+	if err != nil {
+		return err
+	}
+	file.Folder = dir.Path
+	file.FolderID = &dir.ID
+	file.OriginalFilename = name
+	return tx.Model(file).UpdateColumns(map[string]interface{}{
+		"folder":            file.Folder,
+		"folder_id":         file.FolderID,
+		"original_filename": file.OriginalFilename,
+	}).Error
+}
+
+// MoveFolder переносит папку на новый путь вместе с поддеревом
+func MoveFolder(db *gorm.DB, folder *models.FileFolder, parentID *uint, name, newPath string) error {
+	if newPath == folder.Path {
+		return nil
+	}
+
+	if _, err := FindFolder(db, folder.UserID, newPath); err == nil {
+		return ErrFolderExists
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := RenameSubtree(tx, folder.UserID, folder.Path, newPath); err != nil {
+			return err
+		}
+		return tx.Model(folder).Updates(map[string]interface{}{
+			"name":      name,
+			"parent_id": parentID,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrFolderExists
+		}
+		return err
+	}
+
+	folder.Name = name
+	folder.ParentID = parentID
+	folder.Path = newPath
+	return nil
+}
+
+// TrashFolder перемещает в корзину файлы папки и её потомков и удаляет
+// сами папки. Должна вызываться внутри транзакции.
+func TrashFolder(tx *gorm.DB, folder *models.FileFolder) ([]models.File, error) {
+	var files []models.File
+	if err := tx.Where("user_id = ?", folder.UserID).
+		Scopes(SubtreeScope("folder", folder.Path)).
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	if err := TrashFiles(tx, folder.UserID, files); err != nil {
+		return nil, err
+	}
+
+	return files, tx.Where("user_id = ?", folder.UserID).
+		Scopes(SubtreeScope("path", folder.Path)).
+		Delete(&models.FileFolder{}).Error
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultFolder - папка, в которую попадают файлы без явно указанной папки
+const DefaultFolder = "general"
+
+// ErrInvalidFolder - недопустимое имя или путь папки
+var ErrInvalidFolder = errors.New("invalid folder path")
+
+// NormalizeFolderPath приводит путь папки к виду "a/b/c".
+// Пустой путь превращается в DefaultFolder.
+func NormalizeFolderPath(path string) (string, error) {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		if segment == "." || segment == ".." || strings.ContainsAny(segment, "\\\x00") {
+			return "", ErrInvalidFolder
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return DefaultFolder, nil
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// EnsureFolderPath возвращает папку по пути, создавая недостающие
+// папки цепочки. Путь должен быть нормализован.
+func EnsureFolderPath(tx *gorm.DB, userID uint, path string) (*models.FileFolder, error) {
+	var parent *models.FileFolder
+	current := ""
+	for _, name := range strings.Split(path, "/") {
+		if current == "" {
+			current = name
+		} else {
+			current += "/" + name
+		}
+
+		folder := models.FileFolder{UserID: userID, Name: name, Path: current}
+		if parent != nil {
+			folder.ParentID = &parent.ID
+		}
+		if err := tx.Where("user_id = ? AND path = ?", userID, current).
+			FirstOrCreate(&folder).Error; err != nil {
+			return nil, err
+		}
+		parent = &folder
+	}
+	return parent, nil
+}
+
+// FindFolder ищет папку пользователя по нормализованному пути
+func FindFolder(db *gorm.DB, userID uint, path string) (*models.FileFolder, error) {
+	var folder models.FileFolder
+	if err := db.Where("user_id = ? AND path = ?", userID, path).First(&folder).Error; err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// Breadcrumbs возвращает цепочку папок от корня до path включительно.
+// Отсутствующие в таблице звенья пропускаются.
+func Breadcrumbs(db *gorm.DB, userID uint, path string) ([]models.FileFolder, error) {
+	var paths []string
+	current := ""
+	for _, name := range strings.Split(path, "/") {
+		if current == "" {
+			current = name
+		} else {
+			current += "/" + name
+		}
+		paths = append(paths, current)
+	}
+
+	var folders []models.FileFolder
+	if err := db.Where("user_id = ? AND path IN ?", userID, paths).
+		Order("LENGTH(path) ASC").
+		Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// SubtreeScope ограничивает запрос папкой path и всеми её потомками.
+// column - имя колонки с путём ("path" для file_folders, "folder" для files).
+func SubtreeScope(column, path string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("("+column+" = ? OR "+column+" LIKE ?)", path, models.EscapeLike(path)+"/%")
+	}
+}
+
+// RenameSubtree меняет префикс пути oldPath на newPath у папки, всех её
+// потомков и файлов внутри них. Должна вызываться внутри транзакции.
+func RenameSubtree(tx *gorm.DB, userID uint, oldPath, newPath string) error {
+	// substr в PostgreSQL считает символы, а не байты
+	tail := utf8.RuneCountInString(oldPath) + 1
+
+	if err := tx.Model(&models.FileFolder{}).
+		Where("user_id = ?", userID).
+		Scopes(SubtreeScope("path", oldPath)).
+		UpdateColumn("path", gorm.Expr("? || substr(path, ?)", newPath, tail)).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Model(&models.File{}).
+		Where("user_id = ?", userID).
+		Scopes(SubtreeScope("folder", oldPath)).
+		UpdateColumn("folder", gorm.Expr("? || substr(folder, ?)", newPath, tail)).Error
+}
+
+// ParentPath возвращает путь родительской папки ("" для корневых папок)
+func ParentPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// JoinFolderPath склеивает путь родителя и имя папки
+func JoinFolderPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"errors"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// UploadsDir - корневая директория загруженных файлов
+const UploadsDir = "uploads"
+
+// ErrQuotaExceeded - в хранилище пользователя недостаточно места
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// Reserve атомарно увеличивает StorageUsed пользователя на size байт.
+// Обновление выполняется одним условным UPDATE, поэтому параллельные
+// загрузки не теряют изменения и не могут превысить StorageQuota.
+func Reserve(tx *gorm.DB, userID uint, size int64) error {
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND storage_used + ? <= storage_quota", userID, size).
+		UpdateColumn("storage_used", gorm.Expr("storage_used + ?", size))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuotaExceeded
+	}
+	return checkQuotaWarning(tx, userID)
+}
+
+// Release атомарно уменьшает StorageUsed пользователя на size байт (не ниже нуля)
+func Release(tx *gorm.DB, userID uint, size int64) error {
+	if err := tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("storage_used", gorm.Expr("GREATEST(storage_used - ?, 0)", size)).
+		Error; err != nil {
+		return err
+	}
+	return checkQuotaWarning(tx, userID)
+}
+
+// Usage возвращает текущие StorageUsed и StorageQuota пользователя
+func Usage(db *gorm.DB, userID uint) (used, quota int64, err error) {
+	var user models.User
+	if err := db.Select("storage_used", "storage_quota").First(&user, userID).Error; err != nil {
+		return 0, 0, err
+	}
+	return user.StorageUsed, user.StorageQuota, nil
+}
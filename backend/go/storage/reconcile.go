@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// FileIssue - расхождение между записью в таблице files и файлом на диске
+type FileIssue struct {
+	FileID     uint   `json:"file_id"`
+	FilePath   string `json:"file_path"`
+	RecordSize int64  `json:"record_size"`
+	DiskSize   int64  `json:"disk_size"`
+}
+
+// UserReport - результат сверки хранилища одного пользователя
+type UserReport struct {
+	UserID       uint        `json:"user_id"`
+	Username     string      `json:"username"`
+	StorageUsed  int64       `json:"storage_used"` // значение в users до сверки
+	FilesTotal   int64       `json:"files_total"`  // сумма размеров файлов (включая корзину и версии)
+	DiskTotal    int64       `json:"disk_total"`   // фактический размер файлов на диске
+	Missing      []FileIssue `json:"missing,omitempty"`
+	SizeMismatch []FileIssue `json:"size_mismatch,omitempty"`
+	Fixed        bool        `json:"fixed"`
+}
+
+// Report - результат сверки всего хранилища
+type Report struct {
+	Users   []UserReport `json:"users"`
+	Orphans []string     `json:"orphans"` // файлы на диске без записи в базе
+}
+
+// diskSize - ожидаемый размер файла на диске с учётом шифрования
+func diskSize(size int64, wrappedKey string) int64 {
+	if wrappedKey != "" {
+		return EncryptedSize(size)
+	}
+	return size
+}
+
+// Reconcile пересчитывает использование хранилища по таблице files и по
+// фактическим байтам на диске. Если fix = true, StorageUsed каждого
+// пользователя перезаписывается суммой размеров его файлов.
+func Reconcile(db *gorm.DB, fix bool) (*Report, error) {
+	var users []models.User
+	if err := db.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	// Пути, на которые ссылаются записи (включая мягко удалённые)
+	referenced := make(map[string]bool)
+	report := &Report{}
+
+	for _, user := range users {
+		var files []models.File
+		if err := db.Unscoped().Where("user_id = ?", user.ID).Find(&files).Error; err != nil {
+			return nil, err
+		}
+
+		ur := UserReport{
+			UserID:      user.ID,
+			Username:    user.Username,
+			StorageUsed: user.StorageUsed,
+		}
+
+		for _, file := range files {
+			referenced[filepath.Clean(file.FilePath)] = true
+			// Файлы в корзине учитываются, пока за ними числится место
+			if file.DeletedAt.Valid && file.QuotaReleased {
+				continue
+			}
+
+			ur.FilesTotal += file.FileSize
+
+			info, err := os.Stat(file.FilePath)
+			if err != nil {
+				ur.Missing = append(ur.Missing, FileIssue{
+					FileID:     file.ID,
+					FilePath:   file.FilePath,
+					RecordSize: file.FileSize,
+				})
+				continue
+			}
+
+			ur.DiskTotal += info.Size()
+			if info.Size() != diskSize(file.FileSize, file.WrappedKey) {
+				ur.SizeMismatch = append(ur.SizeMismatch, FileIssue{
+					FileID:     file.ID,
+					FilePath:   file.FilePath,
+					RecordSize: file.FileSize,
+					DiskSize:   info.Size(),
+				})
+			}
+		}
+
+		// Предыдущие версии файлов тоже занимают место
+		var versions []models.FileVersion
+		if err := db.Where("user_id = ?", user.ID).Find(&versions).Error; err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			referenced[filepath.Clean(version.FilePath)] = true
+			ur.FilesTotal += version.FileSize
+
+			info, err := os.Stat(version.FilePath)
+			if err != nil {
+				ur.Missing = append(ur.Missing, FileIssue{
+					FileID:     version.FileID,
+					FilePath:   version.FilePath,
+					RecordSize: version.FileSize,
+				})
+				continue
+			}
+			ur.DiskTotal += info.Size()
+		}
+
+		if fix && ur.StorageUsed != ur.FilesTotal {
+			if err := db.Model(&models.User{}).
+				Where("id = ?", user.ID).
+				UpdateColumn("storage_used", ur.FilesTotal).Error; err != nil {
+				return nil, err
+			}
+			if err := checkQuotaWarning(db, user.ID); err != nil {
+				return nil, err
+			}
+			ur.Fixed = true
+		}
+
+		report.Users = append(report.Users, ur)
+	}
+
+	// Ищем файлы на диске, которых нет в базе
+	err := filepath.WalkDir(UploadsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !referenced[filepath.Clean(path)] {
+			report.Orphans = append(report.Orphans, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// SearchConfig - конфигурация полнотекстового поиска PostgreSQL
+const SearchConfig = "russian"
+
+// Маркеры подсветки, которые ts_headline вставляет вокруг совпадений.
+// После HTML-экранирования они заменяются на <mark>.
+const (
+	highlightStart = "⟦"
+	highlightStop  = "⟧"
+)
+
+// maxVectorBytes - объём начала текста, по которому строится поисковый
+// вектор. tsvector в PostgreSQL ограничен 1 МБ, а вектор текста из
+// коротких разных слов бывает больше самого текста, поэтому предел взят с
+// запасом. Текст целиком сохраняется для фрагментов в результатах.
+const maxVectorBytes = 256 << 10
+
+// indexQueue - очередь файлов на индексацию
+var indexQueue = make(chan uint, 1024)
+
+// SearchResult - найденный файл с подсвеченными фрагментами
+type SearchResult struct {
+	ID                uint      `json:"id"`
+	OriginalFilename  string    `json:"filename"`
+	Folder            string    `json:"folder"`
+	FileSize          int64     `json:"size"`
+	MimeType          string    `json:"mime_type"`
+	UploadedAt        time.Time `json:"uploaded_at"`
+	Rank              float64   `json:"rank"`
+	FilenameHighlight string    `json:"filename_highlight"`
+	Snippet           string    `json:"snippet"`
+}
+
+// EnqueueIndex ставит файл в очередь на извлечение текста и индексацию
+func EnqueueIndex(fileID uint) {
+	select {
+	case indexQueue <- fileID:
+	default:
+		// Очередь переполнена: файл будет проиндексирован при следующем запуске
+		log.Printf("⚠️ Очередь индексации переполнена, файл %d пропущен", fileID)
+	}
+}
+
+// StartIndexer запускает фоновую индексацию: сначала файлы без индекса,
+// затем файлы из очереди по мере загрузки
+func StartIndexer(db *gorm.DB) {
+	go func() {
+		var ids []uint
+		if err := db.Model(&models.File{}).
+			Joins("LEFT JOIN file_contents ON file_contents.file_id = files.id").
+			Where("file_contents.file_id IS NULL").
+			Pluck("files.id", &ids).Error; err != nil {
+			log.Printf("⚠️ Ошибка поиска неиндексированных файлов: %v", err)
+		}
+		for _, id := range ids {
+			if err := IndexFile(db, id); err != nil {
+				log.Printf("⚠️ Ошибка индексации файла %d: %v", id, err)
+			}
+		}
+		if len(ids) > 0 {
+			log.Printf("🔎 Проиндексировано файлов: %d", len(ids))
+		}
+
+		for id := range indexQueue {
+			if err := IndexFile(db, id); err != nil {
+				log.Printf("⚠️ Ошибка индексации файла %d: %v", id, err)
+			}
+		}
+	}()
+}
+
+// IndexFile извлекает текст файла и обновляет его поисковый вектор
+func IndexFile(db *gorm.DB, fileID uint) error {
+	var file models.File
+	if err := db.First(&file, fileID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil // файл уже удалён
+		}
+		return err
+	}
+
+	content, err := ExtractText(&file)
+	if err != nil {
+		// Повреждённый файл индексируем только по имени
+		log.Printf("⚠️ Не удалось извлечь текст из файла %d: %v", file.ID, err)
+		content = ""
+	}
+
+	vectorText := content
+	if len(vectorText) > maxVectorBytes {
+		vectorText = strings.ToValidUTF8(vectorText[:maxVectorBytes], "")
+	}
+
+	err = saveContent(db, file.ID, content, vectorText)
+	if err != nil {
+		// Без записи файл пытались бы индексировать при каждом запуске,
+		// поэтому он остаётся доступен для поиска только по имени
+		log.Printf("⚠️ Не удалось построить поисковый вектор файла %d: %v", file.ID, err)
+		err = saveContent(db, file.ID, content, "")
+	}
+	return err
+}
+
+// saveContent сохраняет текст файла и вектор, построенный по vectorText
+func saveContent(db *gorm.DB, fileID uint, content, vectorText string) error {
+	return db.Exec(`
+		INSERT INTO file_contents (file_id, content, search_vector, indexed_at)
+		VALUES (?, ?, to_tsvector('`+SearchConfig+`', ?), ?)
+		ON CONFLICT (file_id) DO UPDATE SET
+			content = EXCLUDED.content,
+			search_vector = EXCLUDED.search_vector,
+			indexed_at = EXCLUDED.indexed_at`,
+		fileID, content, vectorText, time.Now(),
+	).Error
+}
+
+// Search ищет файлы пользователя по имени, папке и содержимому.
+// Запрос понимает синтаксис websearch: "точная фраза", -исключение, OR.
+func Search(db *gorm.DB, userID uint, query string, limit, offset int) ([]SearchResult, int64, error) {
+	const where = `
+		f.user_id = @user AND f.deleted_at IS NULL AND (
+			fc.search_vector @@ websearch_to_tsquery('` + SearchConfig + `', @q)
+			OR to_tsvector('` + SearchConfig + `', f.original_filename || ' ' || coalesce(f.folder, '')) @@ websearch_to_tsquery('` + SearchConfig + `', @q)
+			OR f.original_filename ILIKE @like
+			OR f.folder ILIKE @like
+		)`
+
+	args := map[string]interface{}{
+		"user":   userID,
+		"q":      query,
+		"like":   "%" + models.EscapeLike(query) + "%",
+		"limit":  limit,
+		"offset": offset,
+		"opts":   "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxWords=35, MinWords=15, MaxFragments=2",
+	}
+
+	var total int64
+	if err := db.Raw(`
+		SELECT COUNT(*) FROM files f
+		LEFT JOIN file_contents fc ON fc.file_id = f.id
+		WHERE `+where, args).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Фрагменты строим только для страницы результатов: ts_headline дорогой
+	var results []SearchResult
+	err := db.Raw(`
+		SELECT page.*,
+			ts_headline('`+SearchConfig+`', page.original_filename, websearch_to_tsquery('`+SearchConfig+`', @q), @opts) AS filename_highlight,
+			ts_headline('`+SearchConfig+`', coalesce(fc.content, ''), websearch_to_tsquery('`+SearchConfig+`', @q), @opts) AS snippet
+		FROM (
+			SELECT f.id, f.original_filename, f.folder, f.file_size, f.mime_type, f.uploaded_at,
+				ts_rank(
+					setweight(to_tsvector('`+SearchConfig+`', f.original_filename), 'A') ||
+					setweight(to_tsvector('`+SearchConfig+`', coalesce(f.folder, '')), 'B') ||
+					coalesce(fc.search_vector, ''::tsvector),
+					websearch_to_tsquery('`+SearchConfig+`', @q)
+				) AS rank
+			FROM files f
+			LEFT JOIN file_contents fc ON fc.file_id = f.id
+			WHERE `+where+`
+			ORDER BY rank DESC, f.uploaded_at DESC
+			LIMIT @limit OFFSET @offset
+		) page
+		LEFT JOIN file_contents fc ON fc.file_id = page.id
+		ORDER BY page.rank DESC, page.uploaded_at DESC`, args).Scan(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range results {
+		results[i].FilenameHighlight = renderHighlight(results[i].FilenameHighlight)
+		results[i].Snippet = renderHighlight(results[i].Snippet)
+	}
+	return results, total, nil
+}
+
+// renderHighlight экранирует HTML и превращает маркеры подсветки в <mark>
+func renderHighlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightStart, "<mark>")
+	return strings.ReplaceAll(s, highlightStop, "</mark>")
+}
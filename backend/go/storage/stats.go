@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+)
+
+// defaultWarningThresholds - пороги заполнения квоты (в процентах) по умолчанию
+var defaultWarningThresholds = []int{80, 95}
+
+// UsageBucket - объём файлов в одной группе статистики
+type UsageBucket struct {
+	Key   string `json:"key"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// UploadedPoint - объём текущих файлов, загруженных до конца месяца
+// включительно. Удалённые файлы и версии не учитываются, поэтому это не
+// история занятого места.
+type UploadedPoint struct {
+	Month string `json:"month"`
+	Added int64  `json:"added"`
+	Total int64  `json:"total"`
+}
+
+// LargestFile - файл из списка самых больших
+type LargestFile struct {
+	ID               uint   `json:"id"`
+	OriginalFilename string `json:"filename"`
+	Folder           string `json:"folder"`
+	FileSize         int64  `json:"size"`
+	MimeType         string `json:"mime_type"`
+}
+
+// UsageStats - аналитика использования хранилища
+type UsageStats struct {
+	Used          int64           `json:"storage_used"`
+	Quota         int64           `json:"storage_quota"`
+	Percent       float64         `json:"percent"`
+	Warning       int             `json:"warning"`
+	Thresholds    []int           `json:"thresholds"`
+	Files         int64           `json:"files"`
+	FilesBytes    int64           `json:"files_bytes"`
+	VersionsBytes int64           `json:"versions_bytes"`
+	TrashBytes    int64           `json:"trash_bytes"`
+	ByFolder      []UsageBucket   `json:"by_folder"`
+	ByMimeType    []UsageBucket   `json:"by_mime_type"`
+	ByMonth       []UsageBucket   `json:"by_month"`
+	Uploaded      []UploadedPoint `json:"uploaded_cumulative"`
+	Largest       []LargestFile   `json:"largest"`
+}
+
+// WarningThresholds возвращает пороги предупреждений о заполнении квоты.
+// Управляется переменной STORAGE_WARNING_THRESHOLDS (например "80,95").
+func WarningThresholds() []int {
+	raw := os.Getenv("STORAGE_WARNING_THRESHOLDS")
+	if raw == "" {
+		return defaultWarningThresholds
+	}
+
+	var thresholds []int
+	for _, part := range strings.Split(raw, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || value <= 0 || value > 100 {
+			continue
+		}
+		thresholds = append(thresholds, value)
+	}
+	if len(thresholds) == 0 {
+		return defaultWarningThresholds
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+// WarningLevel возвращает наибольший достигнутый порог или 0
+func WarningLevel(used, quota int64) int {
+	if quota <= 0 {
+		return 0
+	}
+	level := 0
+	for _, threshold := range WarningThresholds() {
+		if used*100 >= int64(threshold)*quota {
+			level = threshold
+		}
+	}
+	return level
+}
+
+// checkQuotaWarning сравнивает заполнение квоты с последним порогом, о котором
+// пользователь уже знает, и создаёт уведомление при переходе через новый порог.
+// При освобождении места уровень снижается, чтобы следующий рост снова уведомил.
+func checkQuotaWarning(tx *gorm.DB, userID uint) error {
+	var user models.User
+	if err := tx.Select("id", "storage_used", "storage_quota", "storage_warned").First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	level := WarningLevel(user.StorageUsed, user.StorageQuota)
+	if level == user.StorageWarned {
+		return nil
+	}
+
+	// Условие по старому значению не даёт параллельным запросам уведомить дважды
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND storage_warned = ?", userID, user.StorageWarned).
+		UpdateColumn("storage_warned", level)
+	if result.Error != nil || result.RowsAffected == 0 || level < user.StorageWarned {
+		return result.Error
+	}
+
+	return tx.Create(&models.Notification{
+		UserID: userID,
+		Kind:   models.NotificationStorageWarning,
+		Title:  fmt.Sprintf("Хранилище заполнено на %d%%", level),
+		Message: fmt.Sprintf("Занято %s из %s. Удалите ненужные файлы или очистите корзину.",
+			FormatBytes(user.StorageUsed), FormatBytes(user.StorageQuota)),
+	}).Error
+}
+
+// Stats собирает аналитику хранилища пользователя. top - размер списка
+// самых больших файлов.
+func Stats(db *gorm.DB, userID uint, top int) (*UsageStats, error) {
+	used, quota, err := Usage(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &UsageStats{
+		Used:       used,
+		Quota:      quota,
+		Warning:    WarningLevel(used, quota),
+		Thresholds: WarningThresholds(),
+	}
+	if quota > 0 {
+		stats.Percent = float64(used) * 100 / float64(quota)
+	}
+
+	files := db.Model(&models.File{}).Where("user_id = ?", userID)
+
+	var totals struct {
+		Files int64
+		Bytes int64
+	}
+	if err := files.Session(&gorm.Session{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	stats.Files, stats.FilesBytes = totals.Files, totals.Bytes
+
+	if err := db.Model(&models.FileVersion{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(file_size), 0)").
+		Scan(&stats.VersionsBytes).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Unscoped().Model(&models.File{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Select("COALESCE(SUM(file_size), 0)").
+		Scan(&stats.TrashBytes).Error; err != nil {
+		return nil, err
+	}
+
+	groups := []struct {
+		dest *[]UsageBucket
+		key  string
+	}{
+		{&stats.ByFolder, "folder"},
+		{&stats.ByMimeType, "COALESCE(NULLIF(mime_type, ''), 'unknown')"},
+		{&stats.ByMonth, "to_char(date_trunc('month', uploaded_at), 'YYYY-MM')"},
+	}
+	for _, group := range groups {
+		order := "bytes DESC, key ASC"
+		if group.dest == &stats.ByMonth {
+			order = "key ASC"
+		}
+		if err := files.Session(&gorm.Session{}).
+			Select(group.key + " AS key, COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+			Group("key").
+			Order(order).
+			Scan(group.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var total int64
+	for _, month := range stats.ByMonth {
+		total += month.Bytes
+		stats.Uploaded = append(stats.Uploaded, UploadedPoint{Month: month.Key, Added: month.Bytes, Total: total})
+	}
+
+	if err := files.Session(&gorm.Session{}).
+		Select("id", "original_filename", "folder", "file_size", "mime_type").
+		Order("file_size DESC, id ASC").
+		Limit(top).
+		Scan(&stats.Largest).Error; err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// FormatBytes форматирует размер в байтах в читаемый вид
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
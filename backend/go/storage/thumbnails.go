@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"portfolio/models"
+)
+
+// ThumbnailsDir - директория кеша миниатюр (вне UploadsDir, чтобы сверка
+// не считала миниатюры файлами-сиротами)
+const ThumbnailsDir = "cache/thumbnails"
+
+// ThumbnailSizes - допустимые размеры миниатюр (длина большей стороны)
+var ThumbnailSizes = []int{64, 128, 256, 512}
+
+// DefaultThumbnailSize - размер миниатюры по умолчанию
+const DefaultThumbnailSize = 256
+
+// ErrNoThumbnail - для файла такого типа миниатюра не строится
+var ErrNoThumbnail = errors.New("thumbnail is not supported for this file type")
+
+// ErrImageTooLarge - размеры изображения превышают maxThumbnailPixels
+var ErrImageTooLarge = errors.New("image is too large for a thumbnail")
+
+// maxThumbnailPixels - наибольшее число пикселей изображения, для которого
+// строится миниатюра: декодированная картинка занимает до 8 байт на пиксель
+const maxThumbnailPixels = 40_000_000
+
+// ErrNoPreview - для файла такого типа текстовый просмотр недоступен
+var ErrNoPreview = errors.New("text preview is not supported for this file type")
+
+// MaxPreviewBytes - максимальный размер текстового просмотра
+const MaxPreviewBytes = 64 * 1024
+
+// SupportsThumbnail сообщает, можно ли построить миниатюру файла
+func SupportsThumbnail(file *models.File) bool {
+	switch strings.ToLower(filepath.Ext(file.OriginalFilename)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// Thumbnail открывает миниатюру файла, создавая её при первом обращении.
+// Миниатюра зашифрованного файла шифруется его ключом данных и
+// расшифровывается при чтении. Возвращает открытую миниатюру и её тип.
+func Thumbnail(file *models.File, size int) (*StoredFile, string, error) {
+	if !SupportsThumbnail(file) {
+		return nil, "", ErrNoThumbnail
+	}
+
+	// PNG сохраняем в PNG, чтобы не потерять прозрачность
+	ext, contentType := ".jpg", "image/jpeg"
+	if strings.ToLower(filepath.Ext(file.OriginalFilename)) == ".png" {
+		ext, contentType = ".png", "image/png"
+	}
+
+	thumbPath := filepath.Join(ThumbnailsDir, fmt.Sprint(file.UserID), fmt.Sprintf("%d_%d%s", file.ID, size, ext))
+	f, err := openStored(thumbPath, file.WrappedKey, file.KeyID)
+	// Незашифрованная миниатюра файла, зашифрованного позже, строится заново
+	if err != nil && (os.IsNotExist(err) || errors.Is(err, ErrCorrupted)) {
+		if err := generateThumbnail(file, thumbPath, size, ext); err != nil {
+			return nil, "", err
+		}
+		f, err = openStored(thumbPath, file.WrappedKey, file.KeyID)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return f, contentType, nil
+}
+
+// ThumbnailSize подбирает ближайший допустимый размер не меньше запрошенного
+func ThumbnailSize(requested int) int {
+	for _, size := range ThumbnailSizes {
+		if requested <= size {
+			return size
+		}
+	}
+	return ThumbnailSizes[len(ThumbnailSizes)-1]
+}
+
+// SupportsPreview сообщает, доступен ли текстовый просмотр файла
+func SupportsPreview(file *models.File) bool {
+	switch strings.ToLower(filepath.Ext(file.OriginalFilename)) {
+	case ".txt", ".go":
+		return true
+	}
+	return false
+}
+
+// TextPreview читает начало текстового файла (не более limit байт).
+// Второе значение сообщает, был ли текст обрезан.
+func TextPreview(file *models.File, limit int) (string, bool, error) {
+	if !SupportsPreview(file) {
+		return "", false, ErrNoPreview
+	}
+
+	f, err := Open(file)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, limit+1)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", false, err
+	}
+
+	truncated := n > limit
+	if truncated {
+		n = limit
+	}
+	data := buf[:n]
+
+	// Не обрываем многобайтовый символ на границе
+	if truncated {
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+	}
+	return strings.ToValidUTF8(string(data), "\uFFFD"), truncated, nil
+}
+
+// GenerateThumbnails строит миниатюру размера по умолчанию сразу после загрузки
+func GenerateThumbnails(file *models.File) error {
+	if !SupportsThumbnail(file) {
+		return nil
+	}
+	f, _, err := Thumbnail(file, DefaultThumbnailSize)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// RemoveThumbnails удаляет все закешированные миниатюры файла
+func RemoveThumbnails(file *models.File) {
+	pattern := filepath.Join(ThumbnailsDir, fmt.Sprint(file.UserID), fmt.Sprintf("%d_*", file.ID))
+	matches, _ := filepath.Glob(pattern)
+	for _, match := range matches {
+		os.Remove(match)
+	}
+}
+
+// generateThumbnail уменьшает изображение и атомарно сохраняет результат
+func generateThumbnail(file *models.File, dstPath string, size int, ext string) error {
+	src, err := Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Размеры из заголовка проверяются до декодирования: маленький файл
+	// может объявлять огромную картинку
+	config, _, err := image.DecodeConfig(src)
+	if err != nil {
+		return err
+	}
+	if config.Width <= 0 || config.Height <= 0 ||
+		int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return ErrImageTooLarge
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return err
+	}
+
+	thumb := scaleDown(img, size)
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return err
+	}
+
+	// Пишем во временный файл, чтобы параллельный запрос не прочитал недописанную миниатюру
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	out, err := thumbnailWriter(tmp, file)
+	if err == nil {
+		if ext == ".png" {
+			err = png.Encode(out, thumb)
+		} else {
+			err = jpeg.Encode(out, thumb, &jpeg.Options{Quality: 85})
+		}
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dstPath)
+}
+
+// thumbnailWriter шифрует миниатюру ключом данных файла, если файл
+// зашифрован
+func thumbnailWriter(w io.Writer, file *models.File) (io.WriteCloser, error) {
+	if file.WrappedKey == "" {
+		return nopWriteCloser{w}, nil
+	}
+	key, err := unwrapKey(file.WrappedKey, file.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptWriter(w, key)
+}
+
+// scaleDown уменьшает изображение так, чтобы большая сторона не превышала
+// size, усредняя пиксели исходника (box filter) в premultiplied-цветах.
+// Меньшие изображения возвращаются как есть. Пиксели читаются через
+// RGBA64At, который есть у всех типов image и не выделяет память.
+func scaleDown(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= size && srcH <= size {
+		return img
+	}
+
+	dstW, dstH := size, size
+	if srcW > srcH {
+		dstH = max(1, srcH*size/srcW)
+	} else {
+		dstW = max(1, srcW*size/srcH)
+	}
+
+	pixel := rgba64At(img)
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := pixel(sx, sy)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// rgba64At возвращает функцию чтения пикселя в premultiplied RGBA64
+func rgba64At(img image.Image) func(x, y int) color.RGBA64 {
+	if src, ok := img.(image.RGBA64Image); ok {
+		return src.RGBA64At
+	}
+	return func(x, y int) color.RGBA64 {
+		r, g, b, a := img.At(x, y).RGBA()
+		return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(a)}
+	}
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TrashCountsQuota сообщает, учитываются ли файлы в корзине в квоте.
+// Управляется переменной TRASH_COUNTS_QUOTA (по умолчанию true).
+func TrashCountsQuota() bool {
+	return os.Getenv("TRASH_COUNTS_QUOTA") != "false"
+}
+
+// TrashRetention - срок хранения файлов в корзине.
+// Управляется переменной TRASH_RETENTION_DAYS (по умолчанию 30 дней).
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// TrashFiles перемещает файлы в корзину: байты остаются на диске,
+// запись помечается удалённой. Должна вызываться внутри транзакции.
+func TrashFiles(tx *gorm.DB, userID uint, files []models.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	counts := TrashCountsQuota()
+	var ids []uint
+	var total int64
+	for _, file := range files {
+		ids = append(ids, file.ID)
+		total += file.FileSize
+	}
+
+	if !counts {
+		if err := tx.Model(&models.File{}).
+			Where("user_id = ? AND id IN ?", userID, ids).
+			UpdateColumn("quota_released", true).Error; err != nil {
+			return err
+		}
+	}
+
+	result := tx.Where("user_id = ? AND id IN ?", userID, ids).Delete(&models.File{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		// Часть файлов уже удалена параллельным запросом
+		return gorm.ErrRecordNotFound
+	}
+
+	if !counts {
+		return Release(tx, userID, total)
+	}
+	return nil
+}
+
+// RestoreFile возвращает файл из корзины, заново занимая место в квоте,
+// если оно было освобождено. Папка файла создаётся, если её уже нет.
+// Запись заново выбирается с блокировкой, поэтому файл, уже восстановленный
+// или удалённый параллельным запросом, даёт gorm.ErrRecordNotFound, а квота
+// занимается один раз. Должна вызываться внутри транзакции.
+func RestoreFile(tx *gorm.DB, file *models.File) error {
+	if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND deleted_at IS NOT NULL", file.ID).
+		First(file).Error; err != nil {
+		return err
+	}
+
+	if file.QuotaReleased {
+		if err := Reserve(tx, file.UserID, file.FileSize); err != nil {
+			return err
+		}
+	}
+
+	folder, err := EnsureFolderPath(tx, file.UserID, file.Folder)
+	if err != nil {
+		return err
+	}
+
+	file.FolderID = &folder.ID
+	file.QuotaReleased = false
+	file.DeletedAt = gorm.DeletedAt{}
+	return tx.Unscoped().Model(file).UpdateColumns(map[string]interface{}{
+		"deleted_at":     nil,
+		"quota_released": false,
+		"folder_id":      folder.ID,
+	}).Error
+}
+
+// PurgeFiles окончательно удаляет файлы из корзины: записи и квота - в
+// транзакции, байты на диске - после её фиксации. Файлы заново выбираются
+// с блокировкой, поэтому восстановленные или уже удалённые параллельным
+// запросом пропускаются, а квота освобождается один раз. Возвращает число
+// удалённых файлов.
+func PurgeFiles(db *gorm.DB, files []models.File) (int, error) {
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	var ids []uint
+	for _, file := range files {
+		ids = append(ids, file.ID)
+	}
+
+	var purged []models.File
+	var versions []models.FileVersion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND deleted_at IS NOT NULL", ids).
+			Find(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) == 0 {
+			return nil
+		}
+
+		released := make(map[uint]int64)
+		ids = ids[:0]
+		for _, file := range purged {
+			ids = append(ids, file.ID)
+			if !file.QuotaReleased {
+				released[file.UserID] += file.FileSize
+			}
+		}
+
+		// Старые версии всегда учитываются в квоте и удаляются вместе с файлом
+		var err error
+		if versions, err = versionsOf(tx, ids); err != nil {
+			return err
+		}
+		for _, version := range versions {
+			released[version.UserID] += version.FileSize
+		}
+
+		if err := tx.Where("file_id IN ?", ids).Delete(&models.FileVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.File{}).Error; err != nil {
+			return err
+		}
+		for userID, size := range released {
+			if err := Release(tx, userID, size); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Если удаление не удалось, файл останется сиротой и будет найден сверкой
+	for _, file := range purged {
+		if err := os.Remove(file.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Не удалось удалить файл %s: %v", file.FilePath, err)
+		}
+		RemoveThumbnails(&file)
+	}
+	removeVersionFiles(versions)
+	return len(purged), nil
+}
+
+// PurgeTrash окончательно удаляет файлы, находящиеся в корзине дольше срока хранения
+func PurgeTrash(db *gorm.DB, before time.Time) (int, error) {
+	var files []models.File
+	if err := db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Find(&files).Error; err != nil {
+		return 0, err
+	}
+	return PurgeFiles(db, files)
+}
+
+// StartTrashPurger запускает фоновую очистку корзины раз в interval
+func StartTrashPurger(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			retention := TrashRetention()
+			purged, err := PurgeTrash(db, time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("⚠️ Ошибка очистки корзины: %v", err)
+			} else if purged > 0 {
+				log.Printf("🗑️ Из корзины удалено файлов: %d", purged)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"log"
+	"os"
+
+	"portfolio/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FindForVersioning ищет файл с тем же именем в той же папке и блокирует
+// его строку до конца транзакции, чтобы параллельные загрузки не создали
+// одинаковые номера версий.
+func FindForVersioning(tx *gorm.DB, userID uint, folder, originalFilename string) (*models.File, error) {
+	var file models.File
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND folder = ? AND original_filename = ?", userID, folder, originalFilename).
+		Order("id ASC").
+		First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// SnapshotVersion сохраняет текущее содержимое файла как предыдущую версию.
+// Должна вызываться внутри транзакции.
+func SnapshotVersion(tx *gorm.DB, file *models.File) error {
+	hash := file.ContentHash
+	if hash == "" {
+		// Версия должна иметь собственный ETag
+		hash, _ = HashFile(file)
+	}
+
+	version := models.FileVersion{
+		FileID:      file.ID,
+		UserID:      file.UserID,
+		Version:     max(file.Version, 1),
+		Filename:    file.Filename,
+		FilePath:    file.FilePath,
+		FileSize:    file.FileSize,
+		MimeType:    file.MimeType,
+		ContentHash: hash,
+		WrappedKey:  file.WrappedKey,
+		KeyID:       file.KeyID,
+		UploadedAt:  file.UploadedAt,
+	}
+	return tx.Create(&version).Error
+}
+
+// RestoreVersion делает выбранную версию текущей, а текущее содержимое
+// сохраняет новой версией. Объём хранилища при этом не меняется. Файл и
+// версия заново выбираются с блокировкой, поэтому версия, уже
+// восстановленная или удалённая параллельным запросом, даёт
+// gorm.ErrRecordNotFound. Миниатюры и индекс обновляются после фиксации.
+func RestoreVersion(db *gorm.DB, file *models.File, version *models.FileVersion) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", file.ID, file.UserID).
+			First(file).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND file_id = ?", version.ID, file.ID).
+			First(version).Error; err != nil {
+			return err
+		}
+
+		if err := SnapshotVersion(tx, file); err != nil {
+			return err
+		}
+		if err := tx.Delete(version).Error; err != nil {
+			return err
+		}
+
+		file.Version = max(file.Version, 1) + 1
+		file.Filename = version.Filename
+		file.FilePath = version.FilePath
+		file.FileSize = version.FileSize
+		file.MimeType = version.MimeType
+		file.ContentHash = version.ContentHash
+		file.WrappedKey = version.WrappedKey
+		file.KeyID = version.KeyID
+		file.UploadedAt = version.UploadedAt
+
+		return tx.Model(file).UpdateColumns(map[string]interface{}{
+			"version":      file.Version,
+			"filename":     file.Filename,
+			"file_path":    file.FilePath,
+			"file_size":    file.FileSize,
+			"mime_type":    file.MimeType,
+			"content_hash": file.ContentHash,
+			"wrapped_key":  file.WrappedKey,
+			"key_id":       file.KeyID,
+			"uploaded_at":  file.UploadedAt,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	RemoveThumbnails(file)
+	EnqueueIndex(file.ID)
+	return nil
+}
+
+// PruneVersions удаляет старые версии файла, оставляя keep самых новых.
+// Версии выбираются с блокировкой внутри транзакции, поэтому при
+// параллельной очистке место каждой версии освобождается один раз. Байты
+// удаляются с диска после фиксации.
+func PruneVersions(db *gorm.DB, file *models.File, keep int) (int, error) {
+	var versions []models.FileVersion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("file_id = ?", file.ID).
+			Order("version DESC").
+			Offset(keep).
+			Find(&versions).Error; err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+
+		var ids []uint
+		var total int64
+		for _, version := range versions {
+			ids = append(ids, version.ID)
+			total += version.FileSize
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.FileVersion{}).Error; err != nil {
+			return err
+		}
+		return Release(tx, file.UserID, total)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removeVersionFiles(versions)
+	return len(versions), nil
+}
+
+// versionsOf возвращает все сохранённые версии файлов, блокируя их до
+// конца транзакции
+func versionsOf(tx *gorm.DB, fileIDs []uint) ([]models.FileVersion, error) {
+	var versions []models.FileVersion
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("file_id IN ?", fileIDs).Find(&versions).Error
+	return versions, err
+}
+
+// removeVersionFiles удаляет байты версий с диска
+func removeVersionFiles(versions []models.FileVersion) {
+	for _, version := range versions {
+		if err := os.Remove(version.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️ Не удалось удалить версию %s: %v", version.FilePath, err)
+		}
+	}
+}
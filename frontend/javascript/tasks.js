@@ -454,7 +454,7 @@ function getNearestDeadline() {
 function checkDeadlineForDate(dateStr) {
     return tasks.some(task => 
         !task.completed && 
-        task.deadline === dateStr
+        (task.deadline || '').slice(0, 10) === dateStr
     );
 }
 